@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/x1unix/docusearch/internal/config"
+	"github.com/x1unix/docusearch/internal/services/lock"
+	"github.com/x1unix/docusearch/internal/services/store"
+	"go.uber.org/zap"
+)
+
+const usage = `Usage: docusearchctl [-config file] [-offline] <command>
+
+Commands:
+  reencrypt   Re-encrypt stored documents using the first key from encryption config.
+  migrate-layout
+              Move documents stored in flat layout into sharded layout set by "shard_levels".
+
+Commands require Redis lock backend to run while service is running.
+Use -offline flag to run them with other lock backends when service is stopped.
+`
+
+func main() {
+	var (
+		cfgFile string
+		offline bool
+	)
+	flag.StringVar(&cfgFile, "config", config.DefaultFileName, "Config file name")
+	flag.BoolVar(&offline, "offline", false, "Allow to run command without Redis lock backend, service should be stopped")
+	flag.Usage = func() {
+		_, _ = fmt.Fprint(flag.CommandLine.Output(), usage, "\nFlags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	cfg, err := config.FromFile(cfgFile)
+	if err != nil {
+		fatal(err)
+	}
+
+	log, err := cfg.Logger()
+	if err != nil {
+		fatal(err)
+	}
+	defer log.Sync() //nolint:errcheck
+
+	switch cmd := flag.Arg(0); cmd {
+	case "reencrypt":
+		err = reencrypt(log, cfg, offline)
+	case "migrate-layout":
+		err = migrateLayout(log, cfg, offline)
+	case "":
+		flag.Usage()
+		os.Exit(2)
+	default:
+		fatal(fmt.Errorf("unknown command %q", cmd))
+	}
+
+	if err != nil {
+		log.Fatal("command failed", zap.Error(err))
+	}
+}
+
+// reencrypt rewrites all documents, revisions and trash items which are not encrypted using the active key.
+func reencrypt(log *zap.Logger, cfg *config.Config, offline bool) error {
+	keys, err := cfg.EncryptionKeys()
+	if err != nil {
+		return err
+	}
+
+	if keys == nil {
+		return errors.New("encryption keys are not configured")
+	}
+
+	if err := requireFileBackend(cfg, "re-encryption"); err != nil {
+		return err
+	}
+
+	locker, closeFn, err := newLocker(log, cfg, offline)
+	if err != nil {
+		return err
+	}
+
+	defer closeFn()
+	ctx := context.Background()
+	for dir, lockPrefix := range storageDirs(cfg) {
+		fileStore := store.NewFileDocumentStore(dir).WithSharding(cfg.Storage.ShardLevels)
+		encStore := store.NewEncryptedDocumentStore(fileStore, keys)
+		rewritten, err := encStore.ReencryptAll(ctx, locker, lockPrefix)
+		if err != nil {
+			return fmt.Errorf("failed to re-encrypt documents in %q: %w", dir, err)
+		}
+
+		log.Info("re-encrypted documents", zap.String("dir", dir),
+			zap.String("key", keys.ActiveKey()), zap.Int("revisions", rewritten))
+	}
+
+	return nil
+}
+
+// migrateLayout moves all documents, revisions and trash items into sharded storage layout.
+func migrateLayout(log *zap.Logger, cfg *config.Config, offline bool) error {
+	if cfg.Storage.ShardLevels <= 0 || cfg.Storage.ShardLevels > store.MaxShardLevels {
+		return fmt.Errorf("shard levels should be between 1 and %d", store.MaxShardLevels)
+	}
+
+	if err := requireFileBackend(cfg, "layout migration"); err != nil {
+		return err
+	}
+
+	locker, closeFn, err := newLocker(log, cfg, offline)
+	if err != nil {
+		return err
+	}
+
+	defer closeFn()
+	ctx := context.Background()
+	for dir, lockPrefix := range storageDirs(cfg) {
+		fileStore := store.NewFileDocumentStore(dir).WithSharding(cfg.Storage.ShardLevels)
+		moved, err := fileStore.MigrateToShards(ctx, locker, lockPrefix)
+		if err != nil {
+			return fmt.Errorf("failed to migrate documents in %q: %w", dir, err)
+		}
+
+		log.Info("migrated documents", zap.String("dir", dir), zap.Int("documents", moved))
+	}
+
+	return nil
+}
+
+func requireFileBackend(cfg *config.Config, operation string) error {
+	if backend := cfg.Storage.Backend; backend != "" && backend != config.StorageBackendFile {
+		return fmt.Errorf("%s is not supported by %q storage backend", operation, backend)
+	}
+
+	return nil
+}
+
+// newLocker returns document locker configured by config and a function to release its connection.
+//
+// Locks of other lock backends than Redis are not shared with running service,
+// so they are allowed only in offline mode.
+func newLocker(log *zap.Logger, cfg *config.Config, offline bool) (lock.Locker, func(), error) {
+	backend := cfg.Lock.Backend
+	if backend == "" {
+		backend = config.LockBackendMemory
+	}
+
+	if backend != config.LockBackendRedis && !offline {
+		return nil, nil, fmt.Errorf("%q lock backend can't be used while service is running, "+
+			"use %q lock backend or stop the service and pass -offline flag", backend, config.LockBackendRedis)
+	}
+
+	redisConn, err := cfg.RedisClient()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	locker, err := cfg.Locker(log, redisConn)
+	if err != nil {
+		_ = redisConn.Close()
+		return nil, nil, err
+	}
+
+	return locker, func() { _ = redisConn.Close() }, nil
+}
+
+// storageDirs returns file storage directories and their document lock key prefixes.
+//
+// Lock keys should match the ones used by service for the same storage.
+func storageDirs(cfg *config.Config) map[string]string {
+	uploadsDir := cfg.Storage.UploadsDirectory
+	dirs := map[string]string{
+		uploadsDir: "",
+		filepath.Join(uploadsDir, store.TrashDirName): "",
+	}
+	if cfg.Storage.Dedup {
+		delete(dirs, uploadsDir)
+		dirs[filepath.Join(uploadsDir, store.BlobsDirName)] = store.BlobLockPrefix
+	}
+
+	return dirs
+}
+
+func fatal(err error) {
+	_, _ = fmt.Fprintln(os.Stderr, "fatal error:", err)
+	os.Exit(2)
+}
@@ -39,11 +39,17 @@ func start(log *zap.Logger, cfg *config.Config) error {
 	}
 
 	defer redisConn.Close()
-	if err := redisConn.Ping(context.Background()).Err(); err != nil {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := redisConn.Ping(ctx).Err(); err != nil {
 		return fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
-	svc := web.NewService(log, cfg, redisConn)
+	svc, err := web.NewService(ctx, log, cfg, redisConn)
+	if err != nil {
+		return err
+	}
+
 	srv := &http.Server{
 		Addr:    cfg.HTTP.Listen,
 		Handler: svc,
@@ -0,0 +1,209 @@
+package e2e
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/x1unix/docusearch/internal/models"
+	"github.com/x1unix/docusearch/pkg/api"
+)
+
+func gzipData(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buff bytes.Buffer
+	w := gzip.NewWriter(&buff)
+	_, err := w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buff.Bytes()
+}
+
+func TestUploadGzipDocument(t *testing.T) {
+	cleanData(t)
+	data := readTestData(t, "pangram1.txt")
+	require.NoError(t, client.AddDocument("pangram", bytes.NewReader(gzipData(t, data)), api.WithContentEncoding("gzip")))
+
+	got, err := client.GetDocument("pangram")
+	require.NoError(t, err)
+	require.Equal(t, data, got, "document should be stored decompressed")
+
+	gotIds, err := client.SearchByWord("fox")
+	require.NoError(t, err)
+	require.Equal(t, []string{"pangram"}, gotIds)
+
+	err = client.ReplaceDocument("pangram", bytes.NewReader(data[:10]), api.WithContentEncoding("gzip"))
+	assertResponseError(t, err, api.ErrorResponse{
+		StatusCode: http.StatusBadRequest,
+		Message:    "malformed gzip request body: gzip: invalid header",
+	})
+
+	err = client.ReplaceDocument("pangram", bytes.NewReader(data), api.WithContentEncoding("br"))
+	assertResponseError(t, err, api.ErrorResponse{
+		StatusCode: http.StatusUnsupportedMediaType,
+		Message:    `unsupported content encoding "br"`,
+	})
+}
+
+func TestUploadArchive(t *testing.T) {
+	cleanData(t)
+	var buff bytes.Buffer
+	tw := tar.NewWriter(&buff)
+	entries := []struct {
+		name string
+		data string
+	}{
+		{name: "guide/intro.md", data: "# Introduction\n\nArchived handbook"},
+		{name: "../escape.txt", data: "handbook outside"},
+		{name: "notes.txt", data: "handbook notes"},
+	}
+	for _, e := range entries {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.data))}))
+		_, err := tw.Write([]byte(e.data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	rsp, err := client.UploadArchive("docs", bytes.NewReader(gzipData(t, buff.Bytes())), false,
+		api.WithLabels(map[string]string{"source": "archive"}))
+	require.NoError(t, err)
+	require.Equal(t, []models.ArchiveEntryResult{
+		{Path: "guide/intro.md", ID: "docs:guide:intro.md", Status: models.ArchiveEntryStored},
+		{Path: "../escape.txt", Status: models.ArchiveEntryFailed, Error: "unsafe archive entry path"},
+		{Path: "notes.txt", ID: "docs:notes.txt", Status: models.ArchiveEntryStored},
+	}, rsp.Items)
+
+	got, err := client.GetDocument("docs:guide:intro.md")
+	require.NoError(t, err)
+	require.Equal(t, entries[0].data, string(got))
+
+	meta, err := client.GetMetadata("docs:guide:intro.md")
+	require.NoError(t, err)
+	require.Equal(t, "text/markdown; charset=utf-8", meta.ContentType)
+	require.Equal(t, map[string]string{"source": "archive"}, meta.Labels)
+
+	gotIds, err := client.SearchByWord("handbook")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"docs:guide:intro.md", "docs:notes.txt"}, gotIds)
+
+	// Existing documents are reported as failed unless archive is uploaded with PUT
+	var zipBuff bytes.Buffer
+	zw := zip.NewWriter(&zipBuff)
+	f, err := zw.Create("notes.txt")
+	require.NoError(t, err)
+	_, err = f.Write([]byte("updated notes"))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	rsp, err = client.UploadArchive("docs", bytes.NewReader(zipBuff.Bytes()), false)
+	require.NoError(t, err)
+	require.Equal(t, []models.ArchiveEntryResult{
+		{Path: "notes.txt", ID: "docs:notes.txt", Status: models.ArchiveEntryFailed, Error: "document already exists"},
+	}, rsp.Items)
+
+	rsp, err = client.UploadArchive("docs", bytes.NewReader(zipBuff.Bytes()), true)
+	require.NoError(t, err)
+	require.Equal(t, models.ArchiveEntryStored, rsp.Items[0].Status)
+	got, err = client.GetDocument("docs:notes.txt")
+	require.NoError(t, err)
+	require.Equal(t, "updated notes", string(got))
+
+	// Entries with the same document ID are reported as failed
+	zipBuff.Reset()
+	zw = zip.NewWriter(&zipBuff)
+	for _, name := range []string{"a/b.txt", "a:b.txt"} {
+		f, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(name))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	rsp, err = client.UploadArchive("docs", bytes.NewReader(zipBuff.Bytes()), true)
+	require.NoError(t, err)
+	require.Equal(t, []models.ArchiveEntryResult{
+		{Path: "a/b.txt", ID: "docs:a:b.txt", Status: models.ArchiveEntryStored},
+		{Path: "a:b.txt", ID: "docs:a:b.txt", Status: models.ArchiveEntryFailed, Error: `document ID collides with "a/b.txt" entry`},
+	}, rsp.Items)
+	got, err = client.GetDocument("docs:a:b.txt")
+	require.NoError(t, err)
+	require.Equal(t, "a/b.txt", string(got))
+
+	_, err = client.UploadArchive("docs", bytes.NewReader([]byte("plain text")), false)
+	assertResponseError(t, err, api.ErrorResponse{
+		StatusCode: http.StatusUnsupportedMediaType,
+		Message:    "unsupported archive format, expected zip, tar, tar.gz or mbox",
+	})
+}
+
+func TestUploadArchiveSizeLimit(t *testing.T) {
+	if maxDocumentSize <= 0 {
+		t.Skip("max_document_size is not set in config")
+	}
+
+	cleanData(t)
+	var buff bytes.Buffer
+	zw := zip.NewWriter(&buff)
+	for name, size := range map[string]int64{"large.txt": maxDocumentSize + 1, "small.txt": 1} {
+		f, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write(bytes.Repeat([]byte{'a'}, int(size)))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	rsp, err := client.UploadArchive("bomb", bytes.NewReader(buff.Bytes()), false)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []models.ArchiveEntryResult{
+		{Path: "large.txt", Status: models.ArchiveEntryFailed, Error: "archive entry size exceeds limit"},
+		{Path: "small.txt", ID: "bomb:small.txt", Status: models.ArchiveEntryStored},
+	}, rsp.Items)
+
+	_, err = client.GetDocument("bomb:large.txt")
+	require.Error(t, err)
+}
+
+func TestUploadMbox(t *testing.T) {
+	cleanData(t)
+	mbox := "From alice@example.com Tue Mar 14 09:30:00 2023\n" +
+		"From: alice@example.com\nSubject: Release plan\nDate: Tue, 14 Mar 2023 09:30:00 +0000\n\nShipping on Friday\n\n" +
+		"From bob@example.com Wed Mar 15 10:00:00 2023\n" +
+		"From: bob@example.com\nSubject: Re: Release plan\nDate: Wed, 15 Mar 2023 10:00:00 +0000\n\n>From now on, Friday it is\n"
+
+	rsp, err := client.UploadArchive("inbox", bytes.NewReader(gzipData(t, []byte(mbox))), false)
+	require.NoError(t, err)
+	require.Equal(t, []models.ArchiveEntryResult{
+		{Path: "1.eml", ID: "inbox:1.eml", Status: models.ArchiveEntryStored},
+		{Path: "2.eml", ID: "inbox:2.eml", Status: models.ArchiveEntryStored},
+	}, rsp.Items)
+
+	got, err := client.GetDocument("inbox:2.eml")
+	require.NoError(t, err)
+	require.Equal(t, "From: bob@example.com\nSubject: Re: Release plan\nDate: Wed, 15 Mar 2023 10:00:00 +0000\n\nFrom now on, Friday it is\n", string(got))
+
+	meta, err := client.GetMetadata("inbox:1.eml")
+	require.NoError(t, err)
+	require.Equal(t, "message/rfc822", meta.ContentType)
+
+	gotIds, err := client.SearchByWord("friday")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"inbox:1.eml", "inbox:2.eml"}, gotIds)
+
+	gotIds, err = client.SearchByWord("from:bob")
+	require.NoError(t, err)
+	require.Equal(t, []string{"inbox:2.eml"}, gotIds)
+
+	// Mailbox can't be stored as a single document
+	for _, opts := range [][]api.RequestOption{nil, {api.WithContentType("application/mbox")}} {
+		err = client.AddDocument("inbox", strings.NewReader(mbox), opts...)
+		assertResponseError(t, err, api.ErrorResponse{
+			StatusCode: http.StatusUnsupportedMediaType,
+			Message:    "mbox mailbox should be uploaded to /archive/inbox to store each message as a separate document",
+		})
+	}
+}
@@ -18,9 +18,12 @@ import (
 )
 
 var (
-	client      *api.Client
-	redisClient redis.Cmdable
-	storageDir  string
+	client          *api.Client
+	serverURL       string
+	redisClient     redis.Cmdable
+	storageDir      string
+	maxDocumentSize int64
+	quotasEnabled   bool
 )
 
 func TestMain(m *testing.M) {
@@ -48,9 +51,11 @@ func TestMain(m *testing.M) {
 
 	redisClient = redisConn
 	storageDir = cfg.Storage.UploadsDirectory
+	maxDocumentSize = cfg.Storage.MaxDocumentSize
+	quotasEnabled = cfg.Storage.Quotas.Enabled
 
 	log.Println("cleaning up Redis...")
-	if err := redisConn.Del(context.Background(), "*").Err(); err != nil {
+	if err := redisConn.FlushDB(context.Background()).Err(); err != nil {
 		log.Fatalln("failed to clean redis data:", err)
 	}
 
@@ -59,11 +64,18 @@ func TestMain(m *testing.M) {
 		log.Fatalln("failed to remove uploads directory:", err)
 	}
 
-	svc := web.NewService(zap.NewNop(), cfg, redisConn)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	svc, err := web.NewService(ctx, zap.NewNop(), cfg, redisConn)
+	if err != nil {
+		log.Fatalln("failed to create service:", err)
+	}
+
 	srv := httptest.NewServer(svc)
 	defer srv.Close()
 
 	log.Println("started HTTP server at:", srv.URL)
+	serverURL = srv.URL
 	client = api.NewClient(srv.URL)
 	os.Exit(m.Run())
 }
@@ -76,7 +88,7 @@ func readTestData(t *testing.T, fname string) []byte {
 
 func cleanData(t *testing.T) {
 	t.Log("cleaning up Redis...")
-	if err := redisClient.Del(context.Background(), "*").Err(); err != nil {
+	if err := redisClient.FlushDB(context.Background()).Err(); err != nil {
 		t.Fatal("failed to clean redis data:", err)
 	}
 
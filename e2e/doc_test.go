@@ -2,10 +2,15 @@ package e2e
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
 	"net/http"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"github.com/x1unix/docusearch/pkg/api"
@@ -59,3 +64,432 @@ func TestUploadDocument(t *testing.T) {
 		})
 	}
 }
+
+func TestUploadDocumentSizeLimit(t *testing.T) {
+	if maxDocumentSize <= 0 {
+		t.Skip("max_document_size is not set in config")
+	}
+
+	cleanData(t)
+	wantErr := api.ErrorResponse{
+		StatusCode: http.StatusRequestEntityTooLarge,
+		Message:    fmt.Sprintf("document size exceeds limit of %d bytes", maxDocumentSize),
+	}
+
+	data := bytes.Repeat([]byte{'a'}, int(maxDocumentSize)+1)
+	t.Run("content_length", func(t *testing.T) {
+		assertResponseError(t, client.AddDocument("large", bytes.NewReader(data)), wantErr)
+	})
+
+	t.Run("streaming", func(t *testing.T) {
+		// io.MultiReader hides body size, so request is sent without Content-Length.
+		assertResponseError(t, client.AddDocument("large", io.MultiReader(bytes.NewReader(data))), wantErr)
+		_, err := client.GetDocument("large")
+		assertResponseError(t, err, api.ErrorResponse{
+			StatusCode: http.StatusNotFound,
+			Message:    "document not found",
+		})
+	})
+
+	t.Run("gzip", func(t *testing.T) {
+		// Limit applies to decompressed document.
+		assertResponseError(t, client.AddDocument("large", bytes.NewReader(gzipData(t, data)),
+			api.WithContentEncoding("gzip")), wantErr)
+	})
+
+	t.Run("within_limit", func(t *testing.T) {
+		require.NoError(t, client.AddDocument("large", bytes.NewReader(data[1:])))
+		require.NoError(t, client.RemoveDocument("large"))
+	})
+}
+
+func TestListDocuments(t *testing.T) {
+	cleanData(t)
+	files := []string{"kafka1.txt", "kafka2.txt", "pangram1.txt"}
+	for _, file := range files {
+		data := readTestData(t, file)
+		fileID := strings.TrimSuffix(file, filepath.Ext(file))
+		require.NoErrorf(t, client.AddDocument(fileID, bytes.NewReader(data)), "failed to upload %q", file)
+	}
+
+	t.Run("paginate", func(t *testing.T) {
+		var got []string
+		q := api.ListQuery{Limit: 2}
+		for {
+			page, err := client.ListDocuments(q)
+			require.NoError(t, err)
+			for _, item := range page.Items {
+				got = append(got, item.ID)
+			}
+
+			if page.NextCursor == "" {
+				break
+			}
+			q.Cursor = page.NextCursor
+		}
+
+		require.Equal(t, []string{"kafka1", "kafka2", "pangram1"}, got)
+	})
+
+	t.Run("prefix", func(t *testing.T) {
+		page, err := client.ListDocuments(api.ListQuery{Prefix: "kafka", SortBy: "size", Desc: true})
+		require.NoError(t, err)
+		require.Len(t, page.Items, 2)
+		require.GreaterOrEqual(t, page.Items[0].Size, page.Items[1].Size)
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		_, err := client.ListDocuments(api.ListQuery{Cursor: "foo"})
+		assertResponseError(t, err, api.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Message:    "invalid cursor",
+		})
+	})
+}
+
+func TestDocumentMetadata(t *testing.T) {
+	cleanData(t)
+	data := readTestData(t, "pangram1.txt")
+	require.NoError(t, client.AddDocument("pangram1", bytes.NewReader(data),
+		api.WithLabels(map[string]string{"kind": "pangram", "lang": "en"})))
+
+	sum := sha256.Sum256(data)
+	meta, err := client.GetMetadata("pangram1")
+	require.NoError(t, err)
+	require.Equal(t, "pangram1", meta.ID)
+	require.Equal(t, int64(len(data)), meta.Size)
+	require.Equal(t, hex.EncodeToString(sum[:]), meta.SHA256)
+	require.Equal(t, "text/plain; charset=utf-8", meta.ContentType)
+	require.Equal(t, map[string]string{"kind": "pangram", "lang": "en"}, meta.Labels)
+	require.False(t, meta.CreatedAt.IsZero())
+	require.Equal(t, meta.CreatedAt, meta.UpdatedAt)
+
+	t.Run("head", func(t *testing.T) {
+		rsp, err := http.Head(serverURL + "/document/pangram1")
+		require.NoError(t, err)
+		defer rsp.Body.Close()
+		require.Equal(t, http.StatusOK, rsp.StatusCode)
+		require.Equal(t, int64(len(data)), rsp.ContentLength)
+		require.Equal(t, "text/plain; charset=utf-8", rsp.Header.Get("Content-Type"))
+		require.ElementsMatch(t, []string{"kind=pangram", "lang=en"}, rsp.Header.Values("X-Document-Label"))
+
+		rsp, err = http.Head(serverURL + "/document/not-exists")
+		require.NoError(t, err)
+		defer rsp.Body.Close()
+		require.Equal(t, http.StatusNotFound, rsp.StatusCode)
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		require.NoError(t, client.ReplaceDocument("pangram1", strings.NewReader("# Title"),
+			api.WithContentType("text/markdown")))
+		got, err := client.GetMetadata("pangram1")
+		require.NoError(t, err)
+		require.Equal(t, "text/markdown; charset=utf-8", got.ContentType, "detected charset should be added")
+		require.Equal(t, int64(7), got.Size)
+		require.Equal(t, meta.Labels, got.Labels, "labels should be preserved")
+		require.True(t, meta.CreatedAt.Equal(got.CreatedAt), "creation time should be preserved")
+		require.True(t, got.UpdatedAt.After(meta.UpdatedAt))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, client.RemoveDocument("pangram1"))
+		_, err := client.GetMetadata("pangram1")
+		assertResponseError(t, err, api.ErrorResponse{
+			StatusCode: http.StatusNotFound,
+			Message:    "document not found",
+		})
+	})
+
+	t.Run("invalid label", func(t *testing.T) {
+		err := client.AddDocument("bad", strings.NewReader("foo"), api.WithLabels(map[string]string{"": "foo"}))
+		assertResponseError(t, err, api.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Message:    `invalid label "=foo", label should be in key=value format`,
+		})
+
+		err = client.AddDocument("bad", strings.NewReader("foo"), api.WithLabels(map[string]string{"a=b": "c"}))
+		assertResponseError(t, err, api.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Message:    `invalid label "a%3Db=c", label key can't contain "="`,
+		})
+	})
+}
+
+func TestGetDocumentCaching(t *testing.T) {
+	cleanData(t)
+	data := readTestData(t, "kafka1.txt")
+	require.NoError(t, client.AddDocument("kafka1", bytes.NewReader(data)))
+
+	sum := sha256.Sum256(data)
+	wantETag := `"` + hex.EncodeToString(sum[:]) + `"`
+	docURL := serverURL + "/document/kafka1"
+
+	rsp, err := http.Get(docURL)
+	require.NoError(t, err)
+	_ = rsp.Body.Close()
+	require.Equal(t, http.StatusOK, rsp.StatusCode)
+	require.Equal(t, wantETag, rsp.Header.Get("ETag"))
+	require.Equal(t, "text/plain; charset=utf-8", rsp.Header.Get("Content-Type"))
+	require.Equal(t, int64(len(data)), rsp.ContentLength)
+	lastModified := rsp.Header.Get("Last-Modified")
+	require.NotEmpty(t, lastModified)
+
+	cases := map[string]struct {
+		headers    map[string]string
+		wantStatus int
+		wantBody   []byte
+	}{
+		"if-none-match": {
+			headers:    map[string]string{"If-None-Match": wantETag},
+			wantStatus: http.StatusNotModified,
+		},
+		"if-none-match mismatch": {
+			headers:    map[string]string{"If-None-Match": `"foo"`},
+			wantStatus: http.StatusOK,
+			wantBody:   data,
+		},
+		"if-modified-since": {
+			headers:    map[string]string{"If-Modified-Since": lastModified},
+			wantStatus: http.StatusNotModified,
+		},
+		"range": {
+			headers:    map[string]string{"Range": "bytes=4-11"},
+			wantStatus: http.StatusPartialContent,
+			wantBody:   data[4:12],
+		},
+	}
+
+	for n, c := range cases {
+		t.Run(n, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, docURL, nil)
+			require.NoError(t, err)
+			for k, v := range c.headers {
+				req.Header.Set(k, v)
+			}
+
+			rsp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer rsp.Body.Close()
+			require.Equal(t, c.wantStatus, rsp.StatusCode)
+
+			got, err := io.ReadAll(rsp.Body)
+			require.NoError(t, err)
+			if c.wantBody != nil {
+				require.Equal(t, c.wantBody, got)
+			}
+		})
+	}
+}
+
+func TestConditionalWrites(t *testing.T) {
+	cleanData(t)
+	preconditionErr := api.ErrorResponse{
+		StatusCode: http.StatusPreconditionFailed,
+		Message:    "precondition failed",
+	}
+
+	// If-Match requires document to exist
+	assertResponseError(t, client.ReplaceDocument("doc", strings.NewReader("v1"), api.IfMatch("*")), preconditionErr)
+
+	// If-None-Match: * allows creation only
+	require.NoError(t, client.ReplaceDocument("doc", strings.NewReader("v1"), api.IfNoneMatch("*")))
+	assertResponseError(t, client.ReplaceDocument("doc", strings.NewReader("v2"), api.IfNoneMatch("*")), preconditionErr)
+
+	meta, err := client.GetMetadata("doc")
+	require.NoError(t, err)
+
+	// Read-modify-write with stale checksum should fail
+	require.NoError(t, client.ReplaceDocument("doc", strings.NewReader("v2"), api.IfMatch(meta.SHA256)))
+	assertResponseError(t, client.ReplaceDocument("doc", strings.NewReader("v3"), api.IfMatch(meta.SHA256)), preconditionErr)
+	assertResponseError(t, client.RemoveDocument("doc", api.IfMatch(meta.SHA256)), preconditionErr)
+
+	got, err := client.GetDocument("doc")
+	require.NoError(t, err)
+	require.Equal(t, "v2", string(got))
+
+	meta, err = client.GetMetadata("doc")
+	require.NoError(t, err)
+	require.NoError(t, client.RemoveDocument("doc", api.IfMatch(meta.SHA256)))
+
+	t.Run("etag in response", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, serverURL+"/document/etag", strings.NewReader("foo"))
+		require.NoError(t, err)
+		rsp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		_ = rsp.Body.Close()
+
+		sum := sha256.Sum256([]byte("foo"))
+		require.Equal(t, `"`+hex.EncodeToString(sum[:])+`"`, rsp.Header.Get("ETag"))
+	})
+}
+
+func TestTrash(t *testing.T) {
+	cleanData(t)
+	notFoundErr := api.ErrorResponse{
+		StatusCode: http.StatusNotFound,
+		Message:    "document not found",
+	}
+
+	data := readTestData(t, "pangram1.txt")
+	require.NoError(t, client.AddDocument("pangram", bytes.NewReader(data), api.WithLabels(map[string]string{"kind": "test"})))
+	require.NoError(t, client.RemoveDocument("pangram"))
+
+	// Removed document should be hidden from reads and search
+	_, err := client.GetDocument("pangram")
+	assertResponseError(t, err, notFoundErr)
+	ids, err := client.SearchByWord("fox")
+	require.NoError(t, err)
+	require.NotContains(t, ids, "pangram")
+
+	// Restored document should be available again
+	require.NoError(t, client.RestoreDocument("pangram"))
+	got, err := client.GetDocument("pangram")
+	require.NoError(t, err)
+	require.Equal(t, data, got)
+	ids, err = client.SearchByWord("fox")
+	require.NoError(t, err)
+	require.Contains(t, ids, "pangram")
+	meta, err := client.GetMetadata("pangram")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"kind": "test"}, meta.Labels)
+
+	assertResponseError(t, client.RestoreDocument("pangram"), api.ErrorResponse{
+		StatusCode: http.StatusNotFound,
+		Message:    "document not found in trash",
+	})
+
+	// Restore shouldn't overwrite a newer document
+	require.NoError(t, client.RemoveDocument("pangram"))
+	require.NoError(t, client.AddDocument("pangram", strings.NewReader("foo")))
+	assertResponseError(t, client.RestoreDocument("pangram"), api.ErrorResponse{
+		StatusCode: http.StatusConflict,
+		Message:    "document with the same id already exists",
+	})
+}
+
+func TestDocumentVersions(t *testing.T) {
+	cleanData(t)
+	revisions := []string{"first apple", "second banana", "third cherry"}
+	require.NoError(t, client.AddDocument("fruits", strings.NewReader(revisions[0])))
+	for _, data := range revisions[1:] {
+		require.NoError(t, client.ReplaceDocument("fruits", strings.NewReader(data)))
+	}
+
+	versions, err := client.ListVersions("fruits")
+	require.NoError(t, err)
+	require.Len(t, versions.Items, len(revisions))
+	for i, v := range versions.Items {
+		require.Equal(t, i+1, v.Version)
+		require.Equal(t, int64(len(revisions[i])), v.Size)
+
+		got, err := client.GetDocumentVersion("fruits", v.Version)
+		require.NoError(t, err)
+		require.Equal(t, revisions[i], string(got))
+	}
+
+	// Content type is known only for the current version
+	for version, want := range map[int]string{1: "application/octet-stream", 3: "text/plain; charset=utf-8"} {
+		rsp, err := http.Get(fmt.Sprintf("%s/document/fruits?version=%d", serverURL, version))
+		require.NoError(t, err)
+		_ = rsp.Body.Close()
+		require.Equal(t, http.StatusOK, rsp.StatusCode)
+		require.Equal(t, want, rsp.Header.Get("Content-Type"), "version %d", version)
+	}
+
+	// Only the latest version should be searchable
+	ids, err := client.SearchByWord("apple")
+	require.NoError(t, err)
+	require.Empty(t, ids)
+	ids, err = client.SearchByWord("cherry")
+	require.NoError(t, err)
+	require.Equal(t, []string{"fruits"}, ids)
+
+	_, err = client.GetDocumentVersion("fruits", 10)
+	assertResponseError(t, err, api.ErrorResponse{
+		StatusCode: http.StatusNotFound,
+		Message:    "document version not found",
+	})
+	_, err = client.ListVersions("unknown")
+	assertResponseError(t, err, api.ErrorResponse{
+		StatusCode: http.StatusNotFound,
+		Message:    "document not found",
+	})
+}
+
+func TestDocumentExpiry(t *testing.T) {
+	cleanData(t)
+	require.NoError(t, client.AddDocument("build.log", strings.NewReader("build passed"), api.ExpiresIn(time.Second)))
+	require.NoError(t, client.AddDocument("release.log", strings.NewReader("release passed"), api.ExpiresIn(time.Hour)))
+
+	meta, err := client.GetMetadata("build.log")
+	require.NoError(t, err)
+	require.NotNil(t, meta.ExpiresAt)
+
+	// Expired document should be unavailable before reaper removes it
+	time.Sleep(time.Second)
+	notFoundErr := api.ErrorResponse{
+		StatusCode: http.StatusNotFound,
+		Message:    "document not found",
+	}
+	_, err = client.GetDocument("build.log")
+	assertResponseError(t, err, notFoundErr)
+	_, err = client.GetMetadata("build.log")
+	assertResponseError(t, err, notFoundErr)
+
+	got, err := client.GetDocument("release.log")
+	require.NoError(t, err)
+	require.Equal(t, "release passed", string(got))
+
+	// Expired document can be uploaded again
+	require.NoError(t, client.AddDocument("build.log", strings.NewReader("build failed")))
+	got, err = client.GetDocument("build.log")
+	require.NoError(t, err)
+	require.Equal(t, "build failed", string(got))
+	ids, err := client.SearchByWord("passed")
+	require.NoError(t, err)
+	require.Equal(t, []string{"release.log"}, ids)
+
+	assertResponseError(t, client.AddDocument("past", strings.NewReader("foo"), api.ExpiresAt(time.Now().Add(-time.Hour))),
+		api.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Message:    "document expiration time is in the past",
+		})
+}
+
+func TestNamespaceQuota(t *testing.T) {
+	if !quotasEnabled {
+		t.Skip("quotas are not enabled in config")
+	}
+
+	// Test expects "quota-test" namespace quota of 2 documents and 10 bytes.
+	cleanData(t)
+	require.NoError(t, client.AddDocument("quota-test:a", strings.NewReader("12345")))
+
+	// io.MultiReader hides body size, so quota is checked while document is streamed.
+	assertResponseError(t, client.AddDocument("quota-test:b", io.MultiReader(strings.NewReader("123456"))),
+		api.ErrorResponse{
+			StatusCode: http.StatusInsufficientStorage,
+			Message:    `namespace "quota-test" exceeds bytes quota`,
+		})
+
+	require.NoError(t, client.AddDocument("quota-test:b", strings.NewReader("123")))
+	assertResponseError(t, client.AddDocument("quota-test:c", strings.NewReader("")),
+		api.ErrorResponse{
+			StatusCode: http.StatusForbidden,
+			Message:    `namespace "quota-test" exceeds documents quota`,
+		})
+
+	usage, err := client.GetUsage("quota-test")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), usage.Documents)
+	require.Equal(t, int64(8), usage.Bytes)
+	require.Equal(t, int64(2), usage.MaxDocuments)
+	require.Equal(t, int64(10), usage.MaxBytes)
+
+	// Removed documents shouldn't be counted
+	require.NoError(t, client.RemoveDocument("quota-test:a"))
+	require.NoError(t, client.AddDocument("quota-test:c", strings.NewReader("1234567")))
+	usage, err = client.GetUsage("quota-test")
+	require.NoError(t, err)
+	require.Equal(t, int64(10), usage.Bytes)
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/x1unix/docusearch/internal/services/search"
 	"github.com/x1unix/docusearch/pkg/api"
+	"golang.org/x/text/encoding/charmap"
 )
 
 func TestSearch(t *testing.T) {
@@ -20,6 +21,12 @@ func TestSearch(t *testing.T) {
 			StatusCode: http.StatusBadRequest,
 			Message:    "empty search query",
 		})
+
+		_, err = client.SearchByWord("title:")
+		assertResponseError(t, err, api.ErrorResponse{
+			StatusCode: http.StatusBadRequest,
+			Message:    "invalid search query, expected word or field:word",
+		})
 	})
 
 	t.Run("search after upload", func(t *testing.T) {
@@ -69,3 +76,324 @@ func TestSearch(t *testing.T) {
 		require.Empty(t, gotIds)
 	})
 }
+
+func TestSearchAfterReplace(t *testing.T) {
+	cleanData(t)
+	require.NoError(t, client.AddDocument("replaced", strings.NewReader("Gregor Samsa woke from troubled dreams")))
+	require.NoError(t, client.ReplaceDocument("replaced", strings.NewReader("Gregor Samsa found himself transformed")))
+
+	got, err := client.GetDocument("replaced")
+	require.NoError(t, err)
+	require.Equal(t, "Gregor Samsa found himself transformed", string(got))
+
+	expect := map[string][]string{
+		"gregor":      {"replaced"},
+		"transformed": {"replaced"},
+		"dreams":      nil,
+		"woke":        nil,
+	}
+
+	for word, expectMatches := range expect {
+		gotIds, err := client.SearchByWord(word)
+		require.NoError(t, err)
+		require.ElementsMatch(t, expectMatches, gotIds, word)
+	}
+
+	// PUT also creates document if it doesn't exist
+	require.NoError(t, client.ReplaceDocument("created", strings.NewReader("Gregor")))
+	gotIds, err := client.SearchByWord("gregor")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"replaced", "created"}, gotIds)
+	require.NoError(t, client.RemoveDocument("replaced"))
+	require.NoError(t, client.RemoveDocument("created"))
+}
+
+func TestSearchWithLabels(t *testing.T) {
+	cleanData(t)
+	docs := map[string]map[string]string{
+		"payments-runbook": {"team": "payments", "kind": "runbook"},
+		"payments-spec":    {"team": "payments", "kind": "spec"},
+		"search-runbook":   {"team": "search", "kind": "runbook"},
+	}
+	for id, labels := range docs {
+		require.NoError(t, client.AddDocument(id, strings.NewReader("Request timeout"), api.WithLabels(labels)))
+	}
+
+	t.Run("filter by label", func(t *testing.T) {
+		rsp, err := client.Search(api.SearchQuery{Word: "timeout", Labels: map[string]string{"team": "payments"}})
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"payments-runbook", "payments-spec"}, rsp.IDs)
+		require.Equal(t, map[string]map[string]int{
+			"team": {"payments": 2},
+			"kind": {"runbook": 1, "spec": 1},
+		}, rsp.Facets)
+	})
+
+	t.Run("multiple labels", func(t *testing.T) {
+		rsp, err := client.Search(api.SearchQuery{
+			Word:   "timeout",
+			Labels: map[string]string{"team": "payments", "kind": "runbook"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"payments-runbook"}, rsp.IDs)
+	})
+
+	t.Run("facets without filter", func(t *testing.T) {
+		rsp, err := client.Search(api.SearchQuery{Word: "timeout"})
+		require.NoError(t, err)
+		require.Len(t, rsp.IDs, 3)
+		require.Equal(t, map[string]map[string]int{
+			"team": {"payments": 2, "search": 1},
+			"kind": {"runbook": 2, "spec": 1},
+		}, rsp.Facets)
+	})
+
+	t.Run("labels update", func(t *testing.T) {
+		require.NoError(t, client.ReplaceDocument("payments-spec", strings.NewReader("Request timeout"),
+			api.WithLabels(map[string]string{"team": "search"})))
+		rsp, err := client.Search(api.SearchQuery{Word: "timeout", Labels: map[string]string{"team": "search"}})
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{"search-runbook", "payments-spec"}, rsp.IDs)
+	})
+
+	t.Run("special characters", func(t *testing.T) {
+		labels := map[string]string{"tags": "db, http", "owner": "a=b c"}
+		require.NoError(t, client.AddDocument("tagged", strings.NewReader("Request timeout"), api.WithLabels(labels)))
+		defer func() {
+			require.NoError(t, client.RemoveDocument("tagged"))
+		}()
+
+		meta, err := client.GetMetadata("tagged")
+		require.NoError(t, err)
+		require.Equal(t, labels, meta.Labels)
+
+		rsp, err := client.Search(api.SearchQuery{Word: "timeout", Labels: map[string]string{"tags": "db, http"}})
+		require.NoError(t, err)
+		require.Equal(t, []string{"tagged"}, rsp.IDs)
+	})
+
+	t.Run("labels removed with document", func(t *testing.T) {
+		for id := range docs {
+			require.NoError(t, client.RemoveDocument(id))
+		}
+
+		rsp, err := client.Search(api.SearchQuery{Word: "timeout", Labels: map[string]string{"team": "search"}})
+		require.NoError(t, err)
+		require.Empty(t, rsp.IDs)
+		require.Empty(t, rsp.Facets)
+	})
+}
+
+func TestSearchPDF(t *testing.T) {
+	cleanData(t)
+	data := readTestData(t, "report.pdf")
+	require.NoError(t, client.AddDocument("report", bytes.NewReader(data)))
+
+	gotIds, err := client.SearchByWord("quarterly")
+	require.NoError(t, err)
+	require.Equal(t, []string{"report"}, gotIds)
+
+	// Words from PDF document structure should not be indexed
+	gotIds, err = client.SearchByWord("FlateDecode")
+	require.NoError(t, err)
+	require.Empty(t, gotIds)
+
+	// Original document should be stored unchanged
+	got, err := client.GetDocument("report")
+	require.NoError(t, err)
+	require.Equal(t, data, got)
+
+	meta, err := client.GetMetadata("report")
+	require.NoError(t, err)
+	require.Equal(t, "application/pdf", meta.ContentType)
+}
+
+func TestSearchMarkup(t *testing.T) {
+	cleanData(t)
+	page := `<html><head><title>Quarterly summary</title><script>var tracking = 1;</script></head>
+<body><p>Revenue grew in every region</p><style>.pipeline { color: red; }</style></body></html>`
+	require.NoError(t, client.AddDocument("page", strings.NewReader(page), api.WithContentType("text/html")))
+	require.NoError(t, client.AddDocument("notes.md", strings.NewReader("# Pipeline\n\nSee [details](https://tracking.example.com).\n")))
+	require.NoError(t, client.AddDocument("quarterly.txt", strings.NewReader("quarterly pipeline")))
+
+	// Documents with searched word in title or headings should be ranked first
+	gotIds, err := client.SearchByWord("quarterly")
+	require.NoError(t, err)
+	require.Equal(t, []string{"page", "quarterly.txt"}, gotIds)
+
+	gotIds, err = client.SearchByWord("pipeline")
+	require.NoError(t, err)
+	require.Equal(t, []string{"notes.md", "quarterly.txt"}, gotIds)
+
+	// Scripts, styles and link destinations should not be indexed
+	gotIds, err = client.SearchByWord("tracking")
+	require.NoError(t, err)
+	require.Empty(t, gotIds)
+
+	meta, err := client.GetMetadata("notes.md")
+	require.NoError(t, err)
+	require.Equal(t, "text/markdown; charset=utf-8", meta.ContentType)
+}
+
+func TestSearchOfficeDocuments(t *testing.T) {
+	cleanData(t)
+	docs := map[string]struct {
+		file        string
+		word        string
+		contentType string
+	}{
+		"spec": {
+			file:        "spec.docx",
+			word:        "replicated",
+			contentType: "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		},
+		"budget": {
+			file:        "budget.xlsx",
+			word:        "marketing",
+			contentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		},
+	}
+
+	for name, doc := range docs {
+		data := readTestData(t, doc.file)
+		require.NoError(t, client.AddDocument(name, bytes.NewReader(data)))
+
+		gotIds, err := client.SearchByWord(doc.word)
+		require.NoError(t, err)
+		require.Equal(t, []string{name}, gotIds)
+
+		// Original document should be stored unchanged
+		got, err := client.GetDocument(name)
+		require.NoError(t, err)
+		require.Equal(t, data, got)
+
+		meta, err := client.GetMetadata(name)
+		require.NoError(t, err)
+		require.Equal(t, doc.contentType, meta.ContentType)
+	}
+
+	// Words from document XML markup should not be indexed
+	gotIds, err := client.SearchByWord("schemas")
+	require.NoError(t, err)
+	require.Empty(t, gotIds)
+}
+
+func TestSearchEncodedText(t *testing.T) {
+	cleanData(t)
+	data, err := charmap.Windows1251.NewEncoder().Bytes([]byte("Отчёт о продажах за квартал"))
+	require.NoError(t, err)
+	require.NoError(t, client.AddDocument("sales", bytes.NewReader(data)))
+
+	gotIds, err := client.SearchByWord("продажах")
+	require.NoError(t, err)
+	require.Equal(t, []string{"sales"}, gotIds)
+
+	// Original document should be served with detected charset
+	got, err := client.GetDocument("sales")
+	require.NoError(t, err)
+	require.Equal(t, data, got)
+
+	meta, err := client.GetMetadata("sales")
+	require.NoError(t, err)
+	require.Equal(t, "windows-1251", meta.Charset)
+	require.Equal(t, "text/plain; charset=windows-1251", meta.ContentType)
+
+	rsp, err := http.Head(serverURL + "/document/sales")
+	require.NoError(t, err)
+	defer rsp.Body.Close()
+	require.Equal(t, "text/plain; charset=windows-1251", rsp.Header.Get("Content-Type"))
+}
+
+func TestSearchEmail(t *testing.T) {
+	cleanData(t)
+	require.NoError(t, client.AddDocument("report", bytes.NewReader(readTestData(t, "report.eml"))))
+
+	meta, err := client.GetMetadata("report")
+	require.NoError(t, err)
+	require.Equal(t, "message/rfc822", meta.ContentType)
+
+	cases := map[string][]string{
+		"квартальный":      {"report"},
+		"résumé":           {"report"},
+		"forecast":         {"report"},
+		"from:alice":       {"report"},
+		"to:carol":         {"report"},
+		"subject:budget":   {"report"},
+		"date:2023":        {"report"},
+		"from:bob":         nil,
+		"binaryattachment": nil,
+	}
+
+	for query, want := range cases {
+		gotIds, err := client.SearchByWord(query)
+		require.NoError(t, err)
+		if want == nil {
+			require.Empty(t, gotIds, query)
+			continue
+		}
+
+		require.Equal(t, want, gotIds, query)
+	}
+}
+
+func TestSearchStructuredFields(t *testing.T) {
+	cleanData(t)
+	docs := map[string]string{
+		"story.json": `{"title": "Metamorphosis", "characters": [{"name": "Gregor Samsa"}, {"name": "Grete"}]}`,
+		"cast.csv":   "title,character\nThe Trial,Josef K.\nGregor,Samsa\n",
+	}
+
+	for name, data := range docs {
+		require.NoError(t, client.AddDocument(name, strings.NewReader(data)))
+	}
+
+	cases := map[string][]string{
+		"gregor":                 {"cast.csv", "story.json"},
+		"title:gregor":           {"cast.csv"},
+		"characters.name:gregor": {"story.json"},
+		"character:samsa":        {"cast.csv"},
+		"Title:Metamorphosis":    {"story.json"},
+		"title:samsa":            nil,
+	}
+
+	for query, want := range cases {
+		gotIds, err := client.SearchByWord(query)
+		require.NoError(t, err)
+		require.ElementsMatch(t, want, gotIds, query)
+	}
+}
+
+func TestSearchSourceCode(t *testing.T) {
+	cleanData(t)
+	src := "func (r RedisProvider) SearchDocumentsByWord(ctx context.Context, word string) {}\n"
+	require.NoError(t, client.AddDocument("redis.go", strings.NewReader(src)))
+	require.NoError(t, client.AddDocument("redis.txt", strings.NewReader(src), api.WithAnalyzer("code")))
+	require.NoError(t, client.AddDocument("plain.go", strings.NewReader(src), api.WithAnalyzer("text")))
+
+	meta, err := client.GetMetadata("redis.go")
+	require.NoError(t, err)
+	require.Equal(t, "code", meta.Analyzer)
+
+	meta, err = client.GetMetadata("plain.go")
+	require.NoError(t, err)
+	require.Equal(t, "text", meta.Analyzer)
+
+	cases := map[string][]string{
+		"documents":             {"redis.go", "redis.txt"},
+		"SearchDocumentsByWord": {"plain.go", "redis.go", "redis.txt"},
+		"redisprovider":         {"plain.go", "redis.go", "redis.txt"},
+		"provider":              {"redis.go", "redis.txt"},
+	}
+
+	for query, want := range cases {
+		gotIds, err := client.SearchByWord(query)
+		require.NoError(t, err)
+		require.ElementsMatch(t, want, gotIds, query)
+	}
+
+	err = client.AddDocument("bad.go", strings.NewReader(src), api.WithAnalyzer("ngram"))
+	assertResponseError(t, err, api.ErrorResponse{
+		StatusCode: http.StatusBadRequest,
+		Message:    `unsupported analyzer "ngram", expected "text" or "code"`,
+	})
+}
@@ -1,17 +1,69 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/go-redis/redis/v8"
+	"github.com/x1unix/docusearch/internal/services/archive"
+	"github.com/x1unix/docusearch/internal/services/lock"
+	"github.com/x1unix/docusearch/internal/services/store"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"gopkg.in/yaml.v3"
 )
 
-// DefaultFileName is default config file name.
-const DefaultFileName = "config.yaml"
+const (
+	// DefaultFileName is default config file name.
+	DefaultFileName = "config.yaml"
+
+	// LockBackendMemory is in-process document lock backend.
+	LockBackendMemory = "memory"
+
+	// LockBackendRedis is Redis document lock backend.
+	LockBackendRedis = "redis"
+
+	// StorageBackendFile is local filesystem document storage backend.
+	StorageBackendFile = "file"
+
+	// StorageBackendS3 is S3-compatible object storage backend.
+	StorageBackendS3 = "s3"
+
+	// defaultS3Region is S3 region used if region is not set.
+	defaultS3Region = "us-east-1"
+
+	// defaultNamespaceSeparator is namespace separator used if separator is not set.
+	defaultNamespaceSeparator = ":"
+
+	// defaultArchiveMaxEntries is max number of uploaded archive entries used if limit is not set.
+	defaultArchiveMaxEntries = 1000
+
+	// defaultArchiveMaxSize is max uncompressed size of uploaded archive used if limit is not set.
+	defaultArchiveMaxSize = 100 << 20
+)
+
+// QuotaConfig is namespace storage quota config.
+type QuotaConfig struct {
+	// MaxDocuments is max number of documents in namespace.
+	//
+	// Zero value means no limit.
+	MaxDocuments int64 `yaml:"max_documents"`
+
+	// MaxBytes is max total size of documents in namespace in bytes.
+	//
+	// Zero value means no limit.
+	MaxBytes int64 `yaml:"max_bytes"`
+}
+
+func (c QuotaConfig) quota() store.Quota {
+	return store.Quota{MaxDocuments: c.MaxDocuments, MaxBytes: c.MaxBytes}
+}
 
 // Config is application configuration
 type Config struct {
@@ -31,13 +83,149 @@ type Config struct {
 	Search struct {
 		// IgnoreCommonWords toggle ignore of common verbs and articles in English language.
 		IgnoreCommonWords bool `yaml:"ignore_common_words"`
+
+		// BoostHeadings ranks documents which contain searched word in title or headings higher.
+		BoostHeadings bool `yaml:"boost_headings"`
+
+		// FieldPaths is list of JSON and CSV document field paths indexed as separate fields.
+		//
+		// JSON paths are dot-separated object keys, e.g. "author.name", CSV paths are column names.
+		// All fields are indexed if list is empty.
+		FieldPaths []string `yaml:"field_paths"`
 	} `yaml:"search"`
 
 	Storage struct {
+		// Backend is document storage backend: "file" (default) or "s3".
+		Backend string `yaml:"backend"`
+
 		// UploadsDirectory is uploaded files storage directory
 		UploadsDirectory string `yaml:"uploads_dir"`
+
+		// ShardLevels is number of hashed sub-directory levels of file storage, e.g. ".shards/ab/cd/<name>".
+		//
+		// Zero value means flat layout. Run "docusearchctl migrate-layout"
+		// to move documents stored before sharding was enabled.
+		ShardLevels int `yaml:"shard_levels"`
+
+		S3 struct {
+			// Endpoint is S3-compatible API endpoint URL.
+			//
+			// AWS endpoint is used if empty.
+			Endpoint string `yaml:"endpoint"`
+
+			// Region is bucket region.
+			Region string `yaml:"region"`
+
+			// Bucket is documents bucket name.
+			Bucket string `yaml:"bucket"`
+
+			// Prefix is object key prefix of stored documents.
+			Prefix string `yaml:"prefix"`
+
+			// AccessKeyID is access key ID.
+			//
+			// Default AWS credentials chain is used if empty.
+			AccessKeyID string `yaml:"access_key_id"`
+
+			// SecretAccessKey is secret access key.
+			SecretAccessKey string `yaml:"secret_access_key"`
+
+			// ForcePathStyle enables path-style bucket addressing required by some S3-compatible servers.
+			ForcePathStyle bool `yaml:"force_path_style"`
+		} `yaml:"s3"`
+
+		// MaxDocumentSize is max uploaded document size in bytes.
+		//
+		// Zero value means no limit.
+		MaxDocumentSize int64 `yaml:"max_document_size"`
+
+		Archives struct {
+			// MaxEntries is max number of files in uploaded archive.
+			//
+			// Default value is 1000.
+			MaxEntries int `yaml:"max_entries"`
+
+			// MaxSize is max total uncompressed size of uploaded archive in bytes.
+			//
+			// Size of each archive file is also limited by max document size.
+			// Default value is 100 MiB.
+			MaxSize int64 `yaml:"max_size"`
+		} `yaml:"archives"`
+
+		// MaxVersions is max number of previous document revisions to keep.
+		//
+		// Zero value disables versioning.
+		MaxVersions int `yaml:"max_versions"`
+
+		// Dedup enables storage of documents with identical contents only once.
+		//
+		// Words of identical contents are also indexed once.
+		// Versioning is not supported for deduplicated storage.
+		Dedup bool `yaml:"dedup"`
+
+		// Compression is compression algorithm of stored documents: "gzip" or "zstd".
+		//
+		// Empty value disables compression. Documents stored before
+		// compression was enabled are still readable.
+		Compression string `yaml:"compression"`
+
+		Encryption struct {
+			// Keys is list of document encryption keys in "<id>:<base64 key>" format.
+			//
+			// The first key is used to encrypt documents, other keys are kept
+			// to decrypt documents encrypted before key rotation.
+			// Encryption is disabled if no keys are set.
+			Keys []string `yaml:"keys"`
+
+			// KeyFile is path to file with encryption keys, one key per line.
+			//
+			// Keys from file are added after keys from config.
+			KeyFile string `yaml:"key_file"`
+		} `yaml:"encryption"`
+
+		Quotas struct {
+			// Enabled toggles tracking of namespace usage and quotas enforcement.
+			Enabled bool `yaml:"enabled"`
+
+			// NamespaceSeparator separates namespace and document name in document ID,
+			// e.g. "team-a:report.txt" document belongs to "team-a" namespace.
+			//
+			// Default value is ":".
+			NamespaceSeparator string `yaml:"namespace_separator"`
+
+			// Default is quota of namespaces which are not listed in namespaces list.
+			Default QuotaConfig `yaml:"default"`
+
+			// Namespaces is list of quotas per namespace.
+			Namespaces map[string]QuotaConfig `yaml:"namespaces"`
+		} `yaml:"quotas"`
+
+		// ExpiryCheckInterval is interval between removal of expired documents.
+		ExpiryCheckInterval time.Duration `yaml:"expiry_check_interval"`
+
+		Trash struct {
+			// Retention is how long removed documents are kept in trash.
+			//
+			// Zero value disables trash and documents are removed permanently.
+			Retention time.Duration `yaml:"retention"`
+
+			// PurgeInterval is interval between removal of expired documents from trash.
+			PurgeInterval time.Duration `yaml:"purge_interval"`
+		} `yaml:"trash"`
 	}
 
+	Lock struct {
+		// Backend is document lock backend: "memory" (default) or "redis".
+		//
+		// Redis lock should be used when multiple service instances share the same storage.
+		Backend string `yaml:"backend"`
+
+		// TTL is Redis lock expiration time.
+		//
+		// Lock TTL is periodically extended while lock is held.
+		TTL time.Duration `yaml:"ttl"`
+	} `yaml:"lock"`
+
 	Log struct {
 		Level zapcore.Level `yaml:"level"`
 	}
@@ -66,6 +254,117 @@ func (cfg Config) RedisClient() (*redis.Client, error) {
 	return redis.NewClient(connCfg), nil
 }
 
+// S3Client returns a new S3 client from storage config
+func (cfg Config) S3Client() (*s3.S3, error) {
+	s3Cfg := cfg.Storage.S3
+	if s3Cfg.Bucket == "" {
+		return nil, errors.New("S3 bucket name is required")
+	}
+
+	region := s3Cfg.Region
+	if region == "" {
+		region = defaultS3Region
+	}
+
+	awsCfg := aws.NewConfig().
+		WithRegion(region).
+		WithS3ForcePathStyle(s3Cfg.ForcePathStyle)
+	if s3Cfg.Endpoint != "" {
+		awsCfg = awsCfg.WithEndpoint(s3Cfg.Endpoint)
+	}
+
+	if s3Cfg.AccessKeyID != "" {
+		awsCfg = awsCfg.WithCredentials(credentials.NewStaticCredentials(s3Cfg.AccessKeyID, s3Cfg.SecretAccessKey, ""))
+	}
+
+	sess, err := session.NewSession(awsCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 session: %w", err)
+	}
+
+	return s3.New(sess), nil
+}
+
+// Locker returns a new document locker
+func (cfg Config) Locker(log *zap.Logger, redisConn redis.Cmdable) (lock.Locker, error) {
+	switch cfg.Lock.Backend {
+	case "", LockBackendMemory:
+		return lock.NewMemoryLocker(), nil
+	case LockBackendRedis:
+		return lock.NewRedisLocker(log, redisConn, cfg.Lock.TTL), nil
+	default:
+		return nil, fmt.Errorf("unsupported lock backend %q", cfg.Lock.Backend)
+	}
+}
+
+// EncryptionKeys returns document encryption key ring.
+//
+// Returns nil if encryption is not configured.
+func (cfg Config) EncryptionKeys() (*store.KeyRing, error) {
+	encCfg := cfg.Storage.Encryption
+	keys, err := store.ParseKeyRing(encCfg.Keys)
+	if err != nil {
+		return nil, err
+	}
+
+	if encCfg.KeyFile != "" {
+		if err := keys.ReadKeyFile(encCfg.KeyFile); err != nil {
+			return nil, err
+		}
+
+		if keys.IsEmpty() {
+			return nil, fmt.Errorf("key file %q has no encryption keys", encCfg.KeyFile)
+		}
+	}
+
+	if keys.IsEmpty() {
+		return nil, nil
+	}
+
+	return keys, nil
+}
+
+// Quotas returns namespace storage quotas.
+//
+// Returns nil if quotas are not enabled.
+func (cfg Config) Quotas(usage store.UsageStore) *store.Quotas {
+	quotaCfg := cfg.Storage.Quotas
+	if !quotaCfg.Enabled {
+		return nil
+	}
+
+	separator := quotaCfg.NamespaceSeparator
+	if separator == "" {
+		separator = defaultNamespaceSeparator
+	}
+
+	namespaces := make(map[string]store.Quota, len(quotaCfg.Namespaces))
+	for ns, quota := range quotaCfg.Namespaces {
+		namespaces[ns] = quota.quota()
+	}
+
+	return store.NewQuotas(usage, separator, quotaCfg.Default.quota(), namespaces)
+}
+
+// ArchiveLimits returns limits of uploaded archives.
+func (cfg Config) ArchiveLimits() archive.Limits {
+	limits := archive.Limits{
+		MaxEntries:   cfg.Storage.Archives.MaxEntries,
+		MaxEntrySize: cfg.Storage.MaxDocumentSize,
+		MaxSize:      cfg.Storage.Archives.MaxSize,
+	}
+
+	if limits.MaxEntries == 0 {
+		limits.MaxEntries = defaultArchiveMaxEntries
+	}
+
+	if limits.MaxSize == 0 {
+		limits.MaxSize = defaultArchiveMaxSize
+	}
+
+	return limits
+}
+
 // FromFile loads configuration from file.
 func FromFile(fileName string) (*Config, error) {
 	f, err := os.Open(fileName)
@@ -0,0 +1,26 @@
+package models
+
+const (
+	// ArchiveEntryStored is status of archive entry stored as a document.
+	ArchiveEntryStored = "stored"
+
+	// ArchiveEntryFailed is status of archive entry which wasn't stored.
+	ArchiveEntryFailed = "failed"
+)
+
+type ArchiveEntryResult struct {
+	// Path is entry path inside archive.
+	Path string `json:"path"`
+
+	// ID is ID of document created from entry.
+	ID     string `json:"id,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type ArchiveUploadResponse struct {
+	Items []ArchiveEntryResult `json:"items"`
+
+	// Message is set if archive processing was stopped, e.g. due to archive size limits.
+	Message string `json:"message,omitempty"`
+}
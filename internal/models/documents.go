@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+type DocumentInfo struct {
+	ID         string    `json:"id"`
+	Size       int64     `json:"size"`
+	UploadedAt time.Time `json:"uploaded_at"`
+}
+
+type DocumentListResponse struct {
+	Items      []DocumentInfo `json:"items"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+type DocumentMetadata struct {
+	ID          string            `json:"id"`
+	Size        int64             `json:"size"`
+	SHA256      string            `json:"sha256"`
+	ContentType string            `json:"content_type"`
+	Charset     string            `json:"charset,omitempty"`
+	Analyzer    string            `json:"analyzer,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+	ExpiresAt   *time.Time        `json:"expires_at,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+}
+
+type DocumentVersion struct {
+	Version   int       `json:"version"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type DocumentVersionsResponse struct {
+	Items []DocumentVersion `json:"items"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"net/url"
+	"strings"
+)
+
+// LabelHeader is HTTP header which contains document label in "key=value" format.
+//
+// Header can be specified multiple times to pass several labels.
+// Label key and value are percent-encoded, so they can contain commas.
+// Label values can contain "=" characters, but keys can't.
+const LabelHeader = "X-Document-Label"
+
+// FormatLabelHeader returns LabelHeader value of a label with percent-encoded key and value.
+func FormatLabelHeader(key, value string) string {
+	return escapeLabel(key) + "=" + escapeLabel(value)
+}
+
+// ParseLabelHeader parses label from "key=value" pair of LabelHeader value and decodes key and value.
+//
+// Returns false if pair is malformed.
+func ParseLabelHeader(pair string) (key, value string, ok bool) {
+	i := strings.Index(pair, "=")
+	if i == -1 {
+		return "", "", false
+	}
+
+	key, err := url.PathUnescape(strings.TrimSpace(pair[:i]))
+	if err != nil || key == "" {
+		return "", "", false
+	}
+
+	value, err = url.PathUnescape(strings.TrimSpace(pair[i+1:]))
+	if err != nil {
+		return "", "", false
+	}
+
+	return key, value, true
+}
+
+// escapeLabel percent-encodes label key or value.
+//
+// Spaces are encoded as "%20", as "+" is not decoded as a space.
+func escapeLabel(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
@@ -2,4 +2,7 @@ package models
 
 type DocumentIDsResponse struct {
 	IDs []string `json:"ids"`
+
+	// Facets is number of found documents per label value (label -> value -> count).
+	Facets map[string]map[string]int `json:"facets,omitempty"`
 }
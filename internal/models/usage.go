@@ -0,0 +1,13 @@
+package models
+
+type NamespaceUsage struct {
+	Namespace string `json:"namespace"`
+	Documents int64  `json:"documents"`
+	Bytes     int64  `json:"bytes"`
+
+	// MaxDocuments is max number of documents, zero means no limit.
+	MaxDocuments int64 `json:"max_documents"`
+
+	// MaxBytes is max total size of documents in bytes, zero means no limit.
+	MaxBytes int64 `json:"max_bytes"`
+}
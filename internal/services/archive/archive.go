@@ -0,0 +1,352 @@
+// Package archive reads file entries of ZIP and TAR archives and messages of mbox mailboxes.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/x1unix/docusearch/internal/services/mbox"
+)
+
+// sniffLen is number of leading bytes used to detect archive format.
+const sniffLen = 512
+
+var (
+	// ErrUnsupportedFormat is returned if archive is not a ZIP, TAR or mbox file, optionally gzip-compressed.
+	ErrUnsupportedFormat = errors.New("unsupported archive format, expected zip, tar, tar.gz or mbox")
+
+	// ErrTooManyEntries is returned if archive contains more entries than allowed.
+	ErrTooManyEntries = errors.New("archive contains too many entries")
+
+	// ErrTooLarge is returned if total uncompressed archive size exceeds limit.
+	ErrTooLarge = errors.New("archive uncompressed size exceeds limit")
+
+	// ErrEntryTooLarge is returned on read of entry which size exceeds limit.
+	ErrEntryTooLarge = errors.New("archive entry size exceeds limit")
+
+	// ErrUnsafePath is set for entries with absolute path or path outside of archive root.
+	ErrUnsafePath = errors.New("unsafe archive entry path")
+
+	// ErrNotRegularFile is set for entries which are not regular files, e.g. symbolic links.
+	ErrNotRegularFile = errors.New("archive entry is not a regular file")
+)
+
+var (
+	zipMagic  = []byte("PK\x03\x04")
+	gzipMagic = []byte{0x1f, 0x8b}
+	tarMagic  = []byte("ustar")
+)
+
+// tarMagicOffset is offset of format magic in TAR header.
+const tarMagicOffset = 257
+
+// mboxEntryExt is file extension of mbox message entries.
+const mboxEntryExt = ".eml"
+
+// Limits protects from archives which unpack into excessive amount of data.
+//
+// Zero values mean no limit.
+type Limits struct {
+	// MaxEntries is max number of archive entries, excluding directories.
+	MaxEntries int
+
+	// MaxEntrySize is max uncompressed size of a single entry in bytes.
+	MaxEntrySize int64
+
+	// MaxSize is max total uncompressed size of archive in bytes.
+	MaxSize int64
+}
+
+// Entry is archive file entry.
+type Entry struct {
+	// Path is cleaned slash-separated entry path.
+	Path string
+
+	// Err is set if entry can't be read, e.g. ErrUnsafePath.
+	Err error
+
+	r io.Reader
+}
+
+// Read implements io.Reader
+//
+// Returns ErrEntryTooLarge or ErrTooLarge if entry size exceeds limits.
+func (e Entry) Read(p []byte) (int, error) {
+	return e.r.Read(p)
+}
+
+// Walk calls fn for each file entry of ZIP, TAR or gzip-compressed TAR archive.
+//
+// Each message of mbox mailbox is passed as "<n>.eml" entry, where n is message number starting from 1.
+// Directories are skipped. Entries which can't be read are passed with Err set.
+// Walk stops if fn returns an error or archive exceeds limits.
+func Walk(r io.Reader, limits Limits, fn func(e Entry) error) error {
+	br := bufio.NewReaderSize(r, sniffLen)
+	head, _ := br.Peek(sniffLen)
+	switch {
+	case bytes.HasPrefix(head, zipMagic):
+		return walkZip(br, limits, fn)
+	case bytes.HasPrefix(head, gzipMagic):
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("failed to read gzip stream: %w", err)
+		}
+
+		defer gz.Close()
+		gzr := bufio.NewReaderSize(gz, sniffLen)
+		head, _ := gzr.Peek(sniffLen)
+		switch {
+		case isTar(head):
+			return walkTar(gzr, limits, fn)
+		case mbox.IsMbox(head):
+			return walkMbox(gzr, limits, fn)
+		}
+
+		return ErrUnsupportedFormat
+	case isTar(head):
+		return walkTar(br, limits, fn)
+	case mbox.IsMbox(head):
+		return walkMbox(br, limits, fn)
+	}
+
+	return ErrUnsupportedFormat
+}
+
+// walkZip reads ZIP archive entries.
+//
+// ZIP archive requires random access, so archive is read into memory.
+func walkZip(r io.Reader, limits Limits, fn func(e Entry) error) error {
+	data, err := ioutil.ReadAll(newLimitReader(r, limits.MaxSize, ErrTooLarge))
+	if err != nil {
+		return err
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("failed to read zip archive: %w", err)
+	}
+
+	w := walker{limits: limits, countSize: limits.MaxSize > 0, remaining: limits.MaxSize}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		if err := w.countEntry(); err != nil {
+			return err
+		}
+
+		err := w.visit(f.Name, f.Mode().IsRegular(), fn, func() (io.ReadCloser, error) {
+			if limits.MaxEntrySize > 0 && f.UncompressedSize64 > uint64(limits.MaxEntrySize) {
+				// Declared size is checked to avoid decompression, actual size is checked on read.
+				return nil, ErrEntryTooLarge
+			}
+
+			return f.Open()
+		})
+		if err != nil {
+			return err
+		}
+
+		if w.countSize && w.remaining < 0 {
+			return ErrTooLarge
+		}
+	}
+
+	return nil
+}
+
+// walkTar reads TAR archive entries.
+//
+// Total size includes headers and skipped entries, as they're read from stream anyway.
+func walkTar(r io.Reader, limits Limits, fn func(e Entry) error) error {
+	tr := tar.NewReader(newLimitReader(r, limits.MaxSize, ErrTooLarge))
+	w := walker{limits: limits}
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			if errors.Is(err, ErrTooLarge) {
+				return err
+			}
+
+			return fmt.Errorf("failed to read tar archive: %w", err)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir, tar.TypeXGlobalHeader:
+			continue
+		}
+
+		if err := w.countEntry(); err != nil {
+			return err
+		}
+
+		err = w.visit(hdr.Name, hdr.Typeflag == tar.TypeReg || hdr.Typeflag == tar.TypeRegA, fn, func() (io.ReadCloser, error) {
+			if limits.MaxEntrySize > 0 && hdr.Size > limits.MaxEntrySize {
+				return nil, ErrEntryTooLarge
+			}
+
+			return ioutil.NopCloser(tr), nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// walkMbox reads messages of mbox mailbox.
+func walkMbox(r io.Reader, limits Limits, fn func(e Entry) error) error {
+	mr := mbox.NewReader(newLimitReader(r, limits.MaxSize, ErrTooLarge))
+	w := walker{limits: limits}
+	for {
+		msg, err := mr.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			if errors.Is(err, ErrTooLarge) {
+				return err
+			}
+
+			return fmt.Errorf("failed to read mbox: %w", err)
+		}
+
+		if err := w.countEntry(); err != nil {
+			return err
+		}
+
+		name := strconv.Itoa(w.entries) + mboxEntryExt
+		err = w.visit(name, true, fn, func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(msg), nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// walker tracks archive limits during walk.
+type walker struct {
+	limits  Limits
+	entries int
+
+	// countSize enables count of entries total size, if archive stream size is not limited.
+	countSize bool
+
+	// remaining is number of uncompressed bytes which can be read from entries.
+	remaining int64
+}
+
+func (w *walker) countEntry() error {
+	w.entries++
+	if w.limits.MaxEntries > 0 && w.entries > w.limits.MaxEntries {
+		return ErrTooManyEntries
+	}
+
+	return nil
+}
+
+// visit opens an entry and passes it to fn.
+func (w *walker) visit(name string, isRegular bool, fn func(e Entry) error, open func() (io.ReadCloser, error)) error {
+	entryPath, err := cleanPath(name)
+	if err != nil {
+		return fn(Entry{Path: name, Err: err})
+	}
+
+	if !isRegular {
+		return fn(Entry{Path: entryPath, Err: ErrNotRegularFile})
+	}
+
+	rc, err := open()
+	if err != nil {
+		return fn(Entry{Path: entryPath, Err: err})
+	}
+
+	defer rc.Close()
+	var r io.Reader = newLimitReader(rc, w.limits.MaxEntrySize, ErrEntryTooLarge)
+	if w.countSize {
+		r = &countingReader{r: r, remaining: &w.remaining}
+	}
+
+	return fn(Entry{Path: entryPath, r: r})
+}
+
+// cleanPath returns cleaned entry path.
+//
+// Returns ErrUnsafePath if path is absolute or points outside of archive root.
+func cleanPath(name string) (string, error) {
+	if name == "" || strings.ContainsAny(name, "\\\x00") || path.IsAbs(name) {
+		return "", ErrUnsafePath
+	}
+
+	p := path.Clean(name)
+	if p == "." || p == ".." || strings.HasPrefix(p, "../") {
+		return "", ErrUnsafePath
+	}
+
+	return p, nil
+}
+
+func isTar(head []byte) bool {
+	return len(head) >= tarMagicOffset+len(tarMagic) &&
+		bytes.Equal(head[tarMagicOffset:tarMagicOffset+len(tarMagic)], tarMagic)
+}
+
+// limitReader is io.Reader which returns an error if more than limit bytes were read.
+type limitReader struct {
+	r         io.Reader
+	remaining int64
+	err       error
+}
+
+// newLimitReader returns reader limited to n bytes, zero means no limit.
+func newLimitReader(r io.Reader, n int64, err error) io.Reader {
+	if n <= 0 {
+		return r
+	}
+
+	return &limitReader{r: r, remaining: n, err: err}
+}
+
+// Read implements io.Reader
+func (l *limitReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, l.err
+	}
+
+	return n, err
+}
+
+// countingReader is io.Reader which returns ErrTooLarge if archive total size limit is exceeded.
+type countingReader struct {
+	r         io.Reader
+	remaining *int64
+}
+
+// Read implements io.Reader
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	*c.remaining -= int64(n)
+	if *c.remaining < 0 {
+		return n, ErrTooLarge
+	}
+
+	return n, err
+}
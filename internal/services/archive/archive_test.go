@@ -0,0 +1,221 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testEntry struct {
+	name    string
+	data    string
+	symlink bool
+}
+
+type entryResult struct {
+	path string
+	data string
+	err  error
+}
+
+func newZip(t *testing.T, entries []testEntry) []byte {
+	t.Helper()
+	var buff bytes.Buffer
+	w := zip.NewWriter(&buff)
+	for _, e := range entries {
+		hdr := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		if e.symlink {
+			hdr.SetMode(0777 | fs.ModeSymlink)
+		}
+
+		f, err := w.CreateHeader(hdr)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(e.data))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, w.Close())
+	return buff.Bytes()
+}
+
+func newTar(t *testing.T, entries []testEntry) []byte {
+	t.Helper()
+	var buff bytes.Buffer
+	w := tar.NewWriter(&buff)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.data)), Typeflag: tar.TypeReg, Format: tar.FormatPAX}
+		switch {
+		case e.symlink:
+			hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeSymlink, e.data, 0
+		case strings.HasSuffix(e.name, "/"):
+			hdr.Typeflag = tar.TypeDir
+		}
+
+		require.NoError(t, w.WriteHeader(hdr))
+		if hdr.Typeflag == tar.TypeReg {
+			_, err := w.Write([]byte(e.data))
+			require.NoError(t, err)
+		}
+	}
+
+	require.NoError(t, w.Close())
+	return buff.Bytes()
+}
+
+func gzipData(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buff bytes.Buffer
+	w := gzip.NewWriter(&buff)
+	_, err := w.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buff.Bytes()
+}
+
+func TestWalk(t *testing.T) {
+	entries := []testEntry{
+		{name: "docs/"},
+		{name: "docs/intro.md", data: "# Intro"},
+		{name: "./docs/guide/../setup.txt", data: "setup"},
+		{name: "../etc/passwd", data: "root"},
+		{name: "/abs.txt", data: "abs"},
+		{name: "link", data: "/etc/passwd", symlink: true},
+	}
+	want := []entryResult{
+		{path: "docs/intro.md", data: "# Intro"},
+		{path: "docs/setup.txt", data: "setup"},
+		{path: "../etc/passwd", err: ErrUnsafePath},
+		{path: "/abs.txt", err: ErrUnsafePath},
+		{path: "link", err: ErrNotRegularFile},
+	}
+
+	cases := map[string]struct {
+		data    []byte
+		limits  Limits
+		want    []entryResult
+		wantErr error
+	}{
+		"zip": {
+			data: newZip(t, entries),
+			want: want,
+		},
+		"tar": {
+			data: newTar(t, entries),
+			want: want,
+		},
+		"tar.gz": {
+			data: gzipData(t, newTar(t, entries)),
+			want: want,
+		},
+		"entry too large": {
+			data:   newZip(t, []testEntry{{name: "big.txt", data: "12345"}, {name: "small.txt", data: "1"}}),
+			limits: Limits{MaxEntrySize: 4},
+			want: []entryResult{
+				{path: "big.txt", err: ErrEntryTooLarge},
+				{path: "small.txt", data: "1"},
+			},
+		},
+		"tar entry too large": {
+			data:   newTar(t, []testEntry{{name: "big.txt", data: "12345"}, {name: "small.txt", data: "1"}}),
+			limits: Limits{MaxEntrySize: 4},
+			want: []entryResult{
+				{path: "big.txt", err: ErrEntryTooLarge},
+				{path: "small.txt", data: "1"},
+			},
+		},
+		"too many entries": {
+			data:    newTar(t, []testEntry{{name: "dir/"}, {name: "a", data: "a"}, {name: "b", data: "b"}, {name: "c", data: "c"}}),
+			limits:  Limits{MaxEntries: 2},
+			want:    []entryResult{{path: "a", data: "a"}, {path: "b", data: "b"}},
+			wantErr: ErrTooManyEntries,
+		},
+		"zip bomb": {
+			data:    newZip(t, []testEntry{{name: "a", data: strings.Repeat("0", 600)}, {name: "b", data: strings.Repeat("0", 600)}}),
+			limits:  Limits{MaxSize: 1000},
+			want:    []entryResult{{path: "a", data: strings.Repeat("0", 600)}, {path: "b", err: ErrTooLarge}},
+			wantErr: ErrTooLarge,
+		},
+		"tar.gz bomb": {
+			data:    gzipData(t, newTar(t, []testEntry{{name: "a", data: strings.Repeat("0", 1<<20)}})),
+			limits:  Limits{MaxSize: 64 << 10},
+			want:    []entryResult{{path: "a", err: ErrTooLarge}},
+			wantErr: ErrTooLarge,
+		},
+		"mbox": {
+			data: []byte("From a@b.c Mon Jan  2 15:04:05 2006\nSubject: one\n\n" +
+				"From d@e.f Mon Jan  2 15:04:05 2006\nSubject: two\n"),
+			want: []entryResult{
+				{path: "1.eml", data: "Subject: one\n\n"},
+				{path: "2.eml", data: "Subject: two\n"},
+			},
+		},
+		"mbox.gz": {
+			data: gzipData(t, []byte("From a@b.c Mon Jan  2 15:04:05 2006\nSubject: one\n")),
+			want: []entryResult{{path: "1.eml", data: "Subject: one\n"}},
+		},
+		"mbox message too large": {
+			data: []byte("From a@b.c Mon Jan  2 15:04:05 2006\nSubject: one\n\n" +
+				"From d@e.f Mon Jan  2 15:04:05 2006\nSubject: 2\n"),
+			limits: Limits{MaxEntrySize: 12},
+			want: []entryResult{
+				{path: "1.eml", err: ErrEntryTooLarge},
+				{path: "2.eml", data: "Subject: 2\n"},
+			},
+		},
+		"gzip without tar": {
+			data:    gzipData(t, []byte("plain text")),
+			wantErr: ErrUnsupportedFormat,
+		},
+		"unsupported format": {
+			data:    []byte("plain text"),
+			wantErr: ErrUnsupportedFormat,
+		},
+	}
+
+	for n, c := range cases {
+		t.Run(n, func(t *testing.T) {
+			var got []entryResult
+			err := Walk(bytes.NewReader(c.data), c.limits, func(e Entry) error {
+				if e.Err != nil {
+					got = append(got, entryResult{path: e.Path, err: e.Err})
+					return nil
+				}
+
+				data, err := ioutil.ReadAll(e)
+				got = append(got, entryResult{path: e.Path, data: string(data), err: err})
+				if err != nil {
+					// Partially read data is discarded.
+					got[len(got)-1].data = ""
+				}
+
+				return nil
+			})
+			if c.wantErr != nil {
+				require.ErrorIs(t, err, c.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.Equal(t, c.want, got)
+		})
+	}
+}
+
+func TestWalk_CallbackError(t *testing.T) {
+	data := newTar(t, []testEntry{{name: "a", data: "a"}, {name: "b", data: "b"}})
+	var visited int
+	err := Walk(bytes.NewReader(data), Limits{}, func(e Entry) error {
+		visited++
+		return io.ErrUnexpectedEOF
+	})
+	require.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	require.Equal(t, 1, visited)
+}
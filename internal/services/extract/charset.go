@@ -0,0 +1,196 @@
+package extract
+
+import (
+	"bytes"
+	"mime"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const (
+	// CharsetUTF8 is UTF-8 charset name.
+	CharsetUTF8 = "utf-8"
+
+	charsetUTF16LE     = "utf-16le"
+	charsetUTF16BE     = "utf-16be"
+	charsetWindows1251 = "windows-1251"
+	charsetWindows1252 = "windows-1252"
+)
+
+// charsetSampleSize is number of leading document bytes used to guess charset.
+const charsetSampleSize = 64 << 10
+
+// byteOrderMarks is list of byte order marks by charset.
+var byteOrderMarks = []struct {
+	charset string
+	bom     []byte
+}{
+	{charset: CharsetUTF8, bom: []byte{0xEF, 0xBB, 0xBF}},
+	{charset: charsetUTF16LE, bom: []byte{0xFF, 0xFE}},
+	{charset: charsetUTF16BE, bom: []byte{0xFE, 0xFF}},
+}
+
+// textTypes is list of non "text/*" MIME types of text documents.
+var textTypes = map[string]bool{
+	MIMETypeXHTML:      true,
+	"application/json": true,
+	"application/xml":  true,
+}
+
+// IsText reports whether content type is a text document type.
+func IsText(contentType string) bool {
+	mimeType := mediaType(contentType)
+	return strings.HasPrefix(mimeType, "text/") || textTypes[mimeType]
+}
+
+// DetectCharset returns canonical charset name of text document.
+//
+// Charset is detected by byte order mark, charset parameter of content type
+// and charset declared in HTML document. Otherwise, charset is guessed from contents:
+// valid UTF-8 is treated as "utf-8", text with mostly Cyrillic letters as "windows-1251"
+// and other text as "windows-1252", which is superset of Latin-1.
+//
+// Returns empty string for non-text documents.
+func DetectCharset(contentType string, data []byte) string {
+	if !IsText(contentType) {
+		return ""
+	}
+
+	for _, m := range byteOrderMarks {
+		if bytes.HasPrefix(data, m.bom) {
+			return m.charset
+		}
+	}
+
+	mimeType, params, _ := mime.ParseMediaType(contentType)
+	if label := params["charset"]; label != "" {
+		if _, name := charset.Lookup(label); name != "" {
+			return name
+		}
+	}
+
+	data = charsetSample(data)
+	if mimeType == MIMETypeHTML || mimeType == MIMETypeXHTML {
+		// Charset from meta tag is not certain, as default is returned if document has no declaration.
+		if _, name, _ := charset.DetermineEncoding(data, mimeType); name != charsetWindows1252 {
+			return name
+		}
+	}
+
+	return guessCharset(data)
+}
+
+// WithCharset returns content type with replaced charset parameter.
+func WithCharset(contentType, charsetName string) string {
+	mimeType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mimeType, params = mediaType(contentType), nil
+	}
+
+	if params == nil {
+		params = make(map[string]string, 1)
+	}
+
+	params["charset"] = charsetName
+	return mime.FormatMediaType(mimeType, params)
+}
+
+// decodeText transcodes text document to UTF-8 according to charset parameter of content type.
+//
+// Byte order mark is removed.
+func decodeText(contentType string, data []byte) ([]byte, error) {
+	charsetName := DetectCharset(contentType, data)
+	if charsetName == "" {
+		return data, nil
+	}
+
+	enc, _ := charset.Lookup(charsetName)
+	switch charsetName {
+	case CharsetUTF8:
+		return bytes.TrimPrefix(data, byteOrderMarks[0].bom), nil
+	case charsetUTF16LE:
+		enc = unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)
+	case charsetUTF16BE:
+		enc = unicode.UTF16(unicode.BigEndian, unicode.UseBOM)
+	}
+
+	if enc == nil || enc == encoding.Replacement {
+		return data, nil
+	}
+
+	return enc.NewDecoder().Bytes(data)
+}
+
+// charsetSample returns leading document bytes used to guess charset.
+func charsetSample(data []byte) []byte {
+	if len(data) > charsetSampleSize {
+		return data[:charsetSampleSize]
+	}
+
+	return data
+}
+
+// guessCharset returns charset guessed from text contents.
+func guessCharset(data []byte) string {
+	if charsetName := guessUTF16(data); charsetName != "" {
+		return charsetName
+	}
+
+	if utf8.Valid(data) {
+		return CharsetUTF8
+	}
+
+	var latin, high, cyrillic int
+	for _, b := range data {
+		switch {
+		case b >= 'A' && b <= 'Z', b >= 'a' && b <= 'z':
+			latin++
+		case b >= 0xC0:
+			// Letters of Cyrillic alphabet are encoded in 0xC0-0xFF range of Windows-1251.
+			cyrillic++
+			high++
+		case b >= 0x80:
+			high++
+		}
+	}
+
+	// Latin-1 text has mostly ASCII letters with occasional accented ones.
+	if cyrillic > latin && cyrillic*10 >= high*9 {
+		return charsetWindows1251
+	}
+
+	return charsetWindows1252
+}
+
+// guessUTF16 returns UTF-16 charset name if text without byte order mark looks like UTF-16,
+// which has zero high bytes in most characters of Latin text.
+func guessUTF16(data []byte) string {
+	if len(data) < 2 {
+		return ""
+	}
+
+	var evenZeros, oddZeros int
+	for i := 0; i+1 < len(data); i += 2 {
+		if data[i] == 0 {
+			evenZeros++
+		}
+
+		if data[i+1] == 0 {
+			oddZeros++
+		}
+	}
+
+	pairs := len(data) / 2
+	switch {
+	case oddZeros*2 > pairs && evenZeros == 0:
+		return charsetUTF16LE
+	case evenZeros*2 > pairs && oddZeros == 0:
+		return charsetUTF16BE
+	}
+
+	return ""
+}
@@ -0,0 +1,98 @@
+package extract
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/mail"
+	"path"
+	"strings"
+
+	"github.com/x1unix/docusearch/internal/services/mbox"
+)
+
+const (
+	mimeTypePlainText = "text/plain"
+	mimeTypeBinary    = "application/octet-stream"
+)
+
+// extensionTypes is list of document MIME types by file extension.
+//
+// Used for text formats which can't be detected from contents.
+var extensionTypes = map[string]string{
+	".md":       MIMETypeMarkdown,
+	".markdown": MIMETypeMarkdown,
+	".htm":      MIMETypeHTML,
+	".html":     MIMETypeHTML,
+	".xhtml":    MIMETypeXHTML,
+	".eml":      MIMETypeEmail,
+	".mbox":     MIMETypeMbox,
+	".json":     MIMETypeJSON,
+	".csv":      MIMETypeCSV,
+}
+
+// DetectContentType returns document MIME type sniffed from contents.
+//
+// Document name extension is used to refine generic plain text type
+// and ZIP archive contents are checked to detect office documents.
+// Plain text is checked to detect JSON documents, mbox mailboxes and email messages.
+// Charset parameter of text documents is detected using DetectCharset.
+func DetectContentType(name string, data []byte) string {
+	contentType := http.DetectContentType(data)
+	switch {
+	case contentType == mimeTypeZIP:
+		if mimeType := detectOfficeType(data); mimeType != "" {
+			return mimeType
+		}
+
+		return contentType
+	case contentType == mimeTypeBinary && guessUTF16(charsetSample(data)) != "":
+		// Sniffing treats UTF-16 text without byte order mark as binary.
+		contentType = mimeTypePlainText
+	case !IsText(contentType):
+		return contentType
+	}
+
+	// Sniffed charset is not reliable, as any text without control characters is treated as UTF-8.
+	mimeType := mediaType(contentType)
+	if mimeType == mimeTypePlainText {
+		mimeType = detectTextType(name, data)
+	}
+
+	if !IsText(mimeType) {
+		// Email messages declare charset of each part.
+		return mimeType
+	}
+
+	return WithCharset(mimeType, DetectCharset(mimeType, data))
+}
+
+// detectTextType returns MIME type of plain text document by name extension or contents.
+func detectTextType(name string, data []byte) string {
+	if extType, ok := extensionTypes[strings.ToLower(path.Ext(name))]; ok {
+		return extType
+	}
+
+	switch {
+	case isJSON(data):
+		return MIMETypeJSON
+	case mbox.IsMbox(data):
+		return MIMETypeMbox
+	case isEmail(charsetSample(data)):
+		return MIMETypeEmail
+	}
+
+	return mimeTypePlainText
+}
+
+// isEmail reports whether text starts with email message header which has sender and date.
+func isEmail(data []byte) bool {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	return err == nil && msg.Header.Get("From") != "" && msg.Header.Get("Date") != ""
+}
+
+// isJSON reports whether text is a JSON object or array.
+func isJSON(data []byte) bool {
+	trimmed := bytes.TrimSpace(data)
+	return len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed)
+}
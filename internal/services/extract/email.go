@@ -0,0 +1,271 @@
+package extract
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+
+	"github.com/x1unix/docusearch/internal/services/mbox"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	// MIMETypeEmail is RFC 5322 email message MIME type.
+	MIMETypeEmail = "message/rfc822"
+
+	// MIMETypeMbox is mbox mailbox MIME type.
+	MIMETypeMbox = "application/mbox"
+
+	// FieldFrom is email message sender field name.
+	FieldFrom = "from"
+
+	// FieldTo is email message recipients field name.
+	FieldTo = "to"
+
+	// FieldSubject is email message subject field name.
+	FieldSubject = "subject"
+
+	// FieldDate is email message date field name.
+	FieldDate = "date"
+)
+
+// emailDateLayout is format of indexed message date, which allows to search by year, month and weekday.
+const emailDateLayout = "Monday, 2 January 2006"
+
+// maxEmailDepth is max nesting depth of multipart entities and attached messages.
+const maxEmailDepth = 10
+
+// headerDecoder decodes RFC 2047 encoded words of message headers.
+var headerDecoder = mime.WordDecoder{
+	CharsetReader: charset.NewReaderLabel,
+}
+
+// ExtractEmail returns text of RFC 5322 email message.
+//
+// Text of "text/plain" and "text/html" parts is extracted, plain text is preferred
+// in "multipart/alternative" entities. Attached messages are included, other attachments are skipped.
+// Sender, recipients, subject and date are returned as fields.
+func ExtractEmail(data []byte) (*Content, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read email message: %w", err)
+	}
+
+	content := new(Content)
+	var text strings.Builder
+	writeEmailHeaders(&text, content, msg.Header)
+	if err := writeEmailEntity(&text, textproto.MIMEHeader(msg.Header), msg.Body, 0); err != nil {
+		return nil, err
+	}
+
+	content.Text = text.String()
+	return content, nil
+}
+
+// ExtractMbox returns text of all messages of mbox mailbox.
+//
+// Used for mailboxes stored as archive entries, as uploaded mailboxes are split into messages.
+// Messages which can't be read are skipped.
+func ExtractMbox(data []byte) (*Content, error) {
+	content := new(Content)
+	var text strings.Builder
+	r := mbox.NewReader(bytes.NewReader(data))
+	for {
+		msg, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read mbox: %w", err)
+		}
+
+		msgData, err := ioutil.ReadAll(msg)
+		if err != nil {
+			return nil, err
+		}
+
+		msgContent, err := ExtractEmail(msgData)
+		if err != nil {
+			continue
+		}
+
+		for name, value := range msgContent.Fields {
+			content.AddField(name, value)
+		}
+
+		text.WriteString(msgContent.Text)
+		text.WriteString("\n")
+	}
+
+	content.Text = text.String()
+	return content, nil
+}
+
+// emailField is indexed message header value.
+type emailField struct {
+	name  string
+	value string
+}
+
+// writeEmailHeaders writes text of indexed message headers and adds them to content fields.
+//
+// Fields are not added if content is nil.
+func writeEmailHeaders(w *strings.Builder, content *Content, h mail.Header) {
+	fields := []emailField{
+		{name: FieldFrom, value: decodeHeader(h.Get("From"))},
+		{name: FieldTo, value: strings.TrimSpace(decodeHeader(h.Get("To")) + "\n" + decodeHeader(h.Get("Cc")))},
+		{name: FieldSubject, value: decodeHeader(h.Get("Subject"))},
+	}
+
+	if date, err := h.Date(); err == nil {
+		fields = append(fields, emailField{name: FieldDate, value: date.Format(emailDateLayout)})
+	}
+
+	for _, f := range fields {
+		if f.value == "" {
+			continue
+		}
+
+		w.WriteString(f.value)
+		w.WriteString("\n")
+		if content != nil {
+			content.addField(f.name, f.value)
+		}
+	}
+}
+
+// writeEmailEntity writes text of a MIME entity.
+func writeEmailEntity(w *strings.Builder, h textproto.MIMEHeader, body io.Reader, depth int) error {
+	if depth > maxEmailDepth {
+		return nil
+	}
+
+	contentType := h.Get("Content-Type")
+	mimeType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		// Plain text is default type of message body.
+		contentType, mimeType, params = mimeTypePlainText, mimeTypePlainText, nil
+	}
+
+	body = decodeTransferEncoding(h.Get("Content-Transfer-Encoding"), body)
+	switch {
+	case strings.HasPrefix(mimeType, "multipart/"):
+		return writeMultipart(w, multipart.NewReader(body, params["boundary"]), mimeType == "multipart/alternative", depth)
+	case mimeType == MIMETypeEmail:
+		msg, err := mail.ReadMessage(body)
+		if err != nil {
+			// Malformed attached message is skipped.
+			return nil
+		}
+
+		writeEmailHeaders(w, nil, msg.Header)
+		return writeEmailEntity(w, textproto.MIMEHeader(msg.Header), msg.Body, depth+1)
+	case mimeType != mimeTypePlainText && mimeType != MIMETypeHTML:
+		// Binary attachments are skipped.
+		return nil
+	}
+
+	data, err := ioutil.ReadAll(body)
+	if err != nil {
+		return fmt.Errorf("failed to read message part: %w", err)
+	}
+
+	data, err = decodeText(contentType, data)
+	if err != nil {
+		return fmt.Errorf("failed to decode message part text: %w", err)
+	}
+
+	if mimeType == MIMETypeHTML {
+		content, err := ExtractHTML(data)
+		if err != nil {
+			return err
+		}
+
+		data = []byte(content.Text)
+	}
+
+	w.Write(data)
+	w.WriteString("\n")
+	return nil
+}
+
+// writeMultipart writes text of multipart entity parts.
+//
+// Only the first text part of alternative parts is written, as they contain the same text.
+func writeMultipart(w *strings.Builder, mr *multipart.Reader, alternative bool, depth int) error {
+	var alternatives []string
+	for {
+		// Raw part is read, as transfer encoding is decoded only for quoted-printable parts by NextPart.
+		p, err := mr.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to read multipart message: %w", err)
+		}
+
+		if !alternative {
+			if err := writeEmailEntity(w, p.Header, p, depth+1); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		var partText strings.Builder
+		if err := writeEmailEntity(&partText, p.Header, p, depth+1); err != nil {
+			return err
+		}
+
+		if mediaType(p.Header.Get("Content-Type")) == mimeTypePlainText && strings.TrimSpace(partText.String()) != "" {
+			w.WriteString(partText.String())
+			return nil
+		}
+
+		alternatives = append(alternatives, partText.String())
+	}
+
+	for _, text := range alternatives {
+		if strings.TrimSpace(text) != "" {
+			w.WriteString(text)
+			break
+		}
+	}
+
+	return nil
+}
+
+// decodeTransferEncoding returns reader of entity body decoded according to Content-Transfer-Encoding header.
+func decodeTransferEncoding(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	case "base64":
+		// Line breaks are ignored by decoder.
+		return base64.NewDecoder(base64.StdEncoding, r)
+	}
+
+	return r
+}
+
+// decodeHeader returns header value with decoded RFC 2047 encoded words.
+//
+// Raw value is returned if header can't be decoded.
+func decodeHeader(value string) string {
+	decoded, err := headerDecoder.DecodeHeader(value)
+	if err != nil {
+		return strings.TrimSpace(value)
+	}
+
+	return strings.TrimSpace(decoded)
+}
@@ -0,0 +1,116 @@
+// Package extract provides extraction of indexable text from documents of different formats.
+package extract
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+)
+
+const (
+	// FieldTitle is document title field name.
+	FieldTitle = "title"
+
+	// FieldHeading is document headings field name.
+	FieldHeading = "heading"
+)
+
+// Content is text extracted from a document.
+type Content struct {
+	// Text is document text.
+	Text string
+
+	// Fields contains text of named document parts, e.g. title or headings.
+	//
+	// Fields text is also included in document text.
+	Fields map[string]string
+}
+
+// AddField appends text to document field.
+func (c *Content) AddField(name, text string) {
+	if c.Fields == nil {
+		c.Fields = make(map[string]string)
+	}
+
+	if prev, ok := c.Fields[name]; ok {
+		text = prev + "\n" + text
+	}
+
+	c.Fields[name] = text
+}
+
+// Extractor extracts text from document contents.
+type Extractor interface {
+	// Extract returns document text.
+	Extract(data []byte) (*Content, error)
+}
+
+// ExtractorFunc is a function which implements Extractor.
+type ExtractorFunc func(data []byte) (*Content, error)
+
+// Extract implements Extractor
+func (fn ExtractorFunc) Extract(data []byte) (*Content, error) {
+	return fn(data)
+}
+
+// Pipeline selects text extractor by document content type.
+//
+// Documents of types without registered extractor are treated as plain text.
+type Pipeline struct {
+	extractors map[string]Extractor
+}
+
+// NewPipeline constructs a new empty pipeline.
+func NewPipeline() *Pipeline {
+	return &Pipeline{extractors: make(map[string]Extractor)}
+}
+
+// DefaultPipeline returns pipeline with extractors of all supported document formats.
+func DefaultPipeline() *Pipeline {
+	return NewPipeline().
+		Register(MIMETypePDF, ExtractorFunc(ExtractPDF)).
+		Register(MIMETypeHTML, ExtractorFunc(ExtractHTML)).
+		Register(MIMETypeXHTML, ExtractorFunc(ExtractHTML)).
+		Register(MIMETypeMarkdown, ExtractorFunc(ExtractMarkdown)).
+		Register(MIMETypeDOCX, ExtractorFunc(ExtractDOCX)).
+		Register(MIMETypeODT, ExtractorFunc(ExtractODT)).
+		Register(MIMETypeXLSX, ExtractorFunc(ExtractXLSX)).
+		Register(MIMETypeEmail, ExtractorFunc(ExtractEmail)).
+		Register(MIMETypeMbox, ExtractorFunc(ExtractMbox)).
+		Register(MIMETypeJSON, NewJSONExtractor(nil)).
+		Register(MIMETypeCSV, NewCSVExtractor(nil))
+}
+
+// Register registers extractor of documents of specified MIME type.
+func (p *Pipeline) Register(mimeType string, e Extractor) *Pipeline {
+	p.extractors[mimeType] = e
+	return p
+}
+
+// Extract returns text of a document with specified content type.
+//
+// Content type might contain parameters, e.g. "text/html; charset=utf-8".
+// Text documents are transcoded to UTF-8 before extraction.
+func (p Pipeline) Extract(contentType string, data []byte) (*Content, error) {
+	data, err := decodeText(contentType, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode text: %w", err)
+	}
+
+	if e, ok := p.extractors[mediaType(contentType)]; ok {
+		return e.Extract(data)
+	}
+
+	return &Content{Text: string(data)}, nil
+}
+
+// mediaType returns lower-cased MIME type without parameters.
+func mediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		// Parameters are malformed, media type is still usable.
+		mediaType = strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0])
+	}
+
+	return strings.ToLower(mediaType)
+}
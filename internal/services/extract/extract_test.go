@@ -0,0 +1,610 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func readTestData(t *testing.T, fname string) []byte {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join("testdata", fname))
+	require.NoError(t, err, "failed to open testdata")
+	return data
+}
+
+func TestPipeline_Extract(t *testing.T) {
+	pdfData := readTestData(t, "report.pdf")
+	cases := map[string]struct {
+		contentType string
+		data        []byte
+		want        []string
+		wantErr     string
+	}{
+		"plain text": {
+			contentType: "text/plain; charset=utf-8",
+			data:        []byte("foo bar"),
+			want:        []string{"foo bar"},
+		},
+		"unknown type": {
+			contentType: "application/x-unknown",
+			data:        []byte("foo"),
+			want:        []string{"foo"},
+		},
+		"windows-1251 text": {
+			contentType: "text/plain; charset=windows-1251",
+			data:        encodeText(t, charmap.Windows1251, "Отчёт о продажах"),
+			want:        []string{"Отчёт о продажах"},
+		},
+		"utf-16 html": {
+			contentType: "text/html",
+			data:        encodeText(t, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), "<title>Überblick</title>"),
+			want:        []string{"Überblick"},
+		},
+		"pdf": {
+			contentType: "application/pdf",
+			data:        pdfData,
+			want:        []string{"Quarterly revenue report", "Prepared by finance team", "Revenue grew in every region"},
+		},
+		"pdf with parameters": {
+			contentType: "Application/PDF; foo",
+			data:        pdfData,
+			want:        []string{"Quarterly revenue report"},
+		},
+		"malformed pdf": {
+			contentType: "application/pdf",
+			data:        []byte("%PDF-1.4\nfoo"),
+			wantErr:     "failed to read PDF document",
+		},
+		"truncated pdf": {
+			contentType: "application/pdf",
+			data:        pdfData[:len(pdfData)/2],
+			wantErr:     "PDF document",
+		},
+	}
+
+	p := DefaultPipeline()
+	for n, c := range cases {
+		t.Run(n, func(t *testing.T) {
+			got, err := p.Extract(c.contentType, c.data)
+			if c.wantErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			for _, str := range c.want {
+				require.Contains(t, got.Text, str)
+			}
+
+			require.False(t, strings.Contains(got.Text, "FlateDecode"), "raw document contents should not be returned")
+		})
+	}
+}
+
+func TestExtractHTML(t *testing.T) {
+	cases := map[string]struct {
+		data       string
+		want       []string
+		wantFields map[string]string
+		notWant    []string
+	}{
+		"visible text": {
+			data: `<!DOCTYPE html><html><head><title>Annual report</title>
+<meta name="keywords" content="hidden"><style>body { color: red; }</style>
+<script>var secret = "token";</script></head>
+<body><h1>Revenue <em>growth</em></h1><p>Sales went<b>up</b>.</p><div>north</div><div>south</div>
+<noscript>enable scripts</noscript><a href="https://example.com/link">details</a></body></html>`,
+			want: []string{"Annual report", "Revenue growth", "Sales wentup.", "north\n", "details"},
+			wantFields: map[string]string{
+				FieldTitle:   "Annual report",
+				FieldHeading: "Revenue growth",
+			},
+			notWant: []string{"color", "secret", "token", "hidden", "enable scripts", "example.com"},
+		},
+		"malformed markup": {
+			data:       `<p>unclosed <b>paragraph<h2>heading</p><script>alert(1)`,
+			want:       []string{"unclosed paragraph", "heading"},
+			wantFields: map[string]string{FieldHeading: "heading"},
+			notWant:    []string{"alert"},
+		},
+		"entities": {
+			data: `<p>Fish &amp; chips &lt;3</p>`,
+			// No fields are returned if document has no title or headings.
+			want: []string{"Fish & chips <3"},
+		},
+	}
+
+	for n, c := range cases {
+		t.Run(n, func(t *testing.T) {
+			got, err := ExtractHTML([]byte(c.data))
+			require.NoError(t, err)
+			for _, str := range c.want {
+				require.Contains(t, got.Text, str)
+			}
+
+			for _, str := range c.notWant {
+				require.NotContains(t, got.Text, str)
+			}
+
+			require.Equal(t, c.wantFields, got.Fields)
+		})
+	}
+}
+
+func TestExtractMarkdown(t *testing.T) {
+	data := "# Release *notes*\n\n" +
+		"Read the [changelog](https://example.com/changes) and ![diagram](arch.png).\n" +
+		"See <https://example.org> for <span class=\"hl\">details</span>.\n\n" +
+		"Setext heading\n--------------\n\n" +
+		"```go\nfmt.Println(\"code\")\n```\n\n" +
+		"<script>tracker()</script>\n<!-- hidden comment -->\n" +
+		"[changelog-ref]: https://example.com/ref\n"
+
+	got, err := ExtractMarkdown([]byte(data))
+	require.NoError(t, err)
+
+	for _, str := range []string{"Release *notes*", "changelog", "diagram", "https://example.org", "details", "Setext heading", `fmt.Println("code")`} {
+		require.Contains(t, got.Text, str)
+	}
+
+	for _, str := range []string{"example.com", "arch.png", "span", "tracker", "hidden comment", "```", "go\n"} {
+		require.NotContains(t, got.Text, str)
+	}
+
+	require.Equal(t, map[string]string{
+		FieldHeading: "Release *notes*\nSetext heading",
+	}, got.Fields)
+}
+
+func TestDetectContentType(t *testing.T) {
+	cases := map[string]struct {
+		name string
+		data string
+		want string
+	}{
+		"plain text": {
+			name: "notes.txt",
+			data: "foo",
+			want: "text/plain; charset=utf-8",
+		},
+		"markdown": {
+			name: "README.md",
+			data: "# foo",
+			want: "text/markdown; charset=utf-8",
+		},
+		"html by extension": {
+			name: "page.HTML",
+			data: "foo",
+			want: "text/html; charset=utf-8",
+		},
+		"html by contents": {
+			name: "page",
+			data: "<!DOCTYPE html><p>foo",
+			want: "text/html; charset=utf-8",
+		},
+		"binary": {
+			name: "report.md",
+			data: "%PDF-1.4",
+			want: "application/pdf",
+		},
+		"docx": {
+			name: "spec.zip",
+			data: string(readTestData(t, "spec.docx")),
+			want: MIMETypeDOCX,
+		},
+		"odt": {
+			name: "spec",
+			data: string(readTestData(t, "spec.odt")),
+			want: MIMETypeODT,
+		},
+		"xlsx": {
+			name: "budget.xlsx",
+			data: string(readTestData(t, "budget.xlsx")),
+			want: MIMETypeXLSX,
+		},
+		"windows-1251 text": {
+			name: "report.txt",
+			data: string(encodeText(t, charmap.Windows1251, "Отчёт о продажах за квартал")),
+			want: "text/plain; charset=windows-1251",
+		},
+		"utf-16 text without bom": {
+			name: "notes.md",
+			data: string(encodeText(t, unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), "# Notes")),
+			want: "text/markdown; charset=utf-16be",
+		},
+		"json by contents": {
+			name: "book",
+			data: ` [{"title": "Metamorphosis"}]`,
+			want: "application/json; charset=utf-8",
+		},
+		"csv by extension": {
+			name: "books.CSV",
+			data: "title,author\nMetamorphosis,Kafka",
+			want: "text/csv; charset=utf-8",
+		},
+		"malformed json": {
+			name: "book",
+			data: `{"title": `,
+			want: "text/plain; charset=utf-8",
+		},
+		"email by extension": {
+			name: "report.eml",
+			data: "Subject: foo\n\nbar",
+			want: MIMETypeEmail,
+		},
+		"email by contents": {
+			name: "report",
+			data: string(readTestData(t, "report.eml")),
+			want: MIMETypeEmail,
+		},
+		"text with header-like line": {
+			name: "notes",
+			data: "From: the author\n\nfoo",
+			want: "text/plain; charset=utf-8",
+		},
+		"mbox by contents": {
+			name: "inbox",
+			data: "From alice@example.com Tue Mar 14 09:30:00 2023\nSubject: foo\n\nbar\n",
+			want: MIMETypeMbox,
+		},
+		"zip archive": {
+			name: "spec.docx",
+			data: string(newZip(t, map[string]string{"doc.txt": "foo"})),
+			want: "application/zip",
+		},
+	}
+
+	for n, c := range cases {
+		t.Run(n, func(t *testing.T) {
+			require.Equal(t, c.want, DetectContentType(c.name, []byte(c.data)))
+		})
+	}
+}
+
+func TestExtractEmail(t *testing.T) {
+	cases := map[string]struct {
+		data       string
+		want       []string
+		notWant    []string
+		wantFields map[string]string
+		wantErr    string
+	}{
+		"multipart": {
+			data: string(readTestData(t, "report.eml")),
+			want: []string{
+				"Alice Smith <alice@example.com>", "carol@example.net", "Квартальный отчёт: budget review",
+				"Résumé of changes follows.", "Forwarded forecast", "Отчёт forecast numbers",
+			},
+			notWant: []string{"htmlonly", "hidden", "binaryattachment", "=C3"},
+			wantFields: map[string]string{
+				FieldFrom:    "Alice Smith <alice@example.com>",
+				FieldTo:      "Bob <bob@example.org>\ncarol@example.net",
+				FieldSubject: "Квартальный отчёт: budget review",
+				FieldDate:    "Tuesday, 14 March 2023",
+			},
+		},
+		"html alternative": {
+			data: "From: alice@example.com\nContent-Type: multipart/alternative; boundary=b\n\n" +
+				"--b\nContent-Type: text/plain\n\n\n--b\nContent-Type: text/html\n\n<p>Hello <b>world</b></p>\n--b--\n",
+			want:       []string{"Hello world"},
+			notWant:    []string{"<p>"},
+			wantFields: map[string]string{FieldFrom: "alice@example.com"},
+		},
+		"undecodable header": {
+			data: "Subject: =?unknown?q?raw?=\n\nbody",
+			want: []string{"=?unknown?q?raw?=", "body"},
+			wantFields: map[string]string{
+				FieldSubject: "=?unknown?q?raw?=",
+			},
+		},
+		"malformed": {
+			data:    "not an email",
+			wantErr: "failed to read email message",
+		},
+	}
+
+	for n, c := range cases {
+		t.Run(n, func(t *testing.T) {
+			got, err := ExtractEmail([]byte(c.data))
+			if c.wantErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			for _, str := range c.want {
+				require.Contains(t, got.Text, str)
+			}
+
+			for _, str := range c.notWant {
+				require.NotContains(t, got.Text, str)
+			}
+
+			require.Equal(t, c.wantFields, got.Fields)
+		})
+	}
+}
+
+func TestExtractMbox(t *testing.T) {
+	data := "From alice@example.com Tue Mar 14 09:30:00 2023\nFrom: alice@example.com\nSubject: first\n\n" +
+		">From the start\n\n" +
+		"From bob@example.com Wed Mar 15 10:00:00 2023\ninvalid message\n\n" +
+		"From bob@example.com Wed Mar 15 10:00:00 2023\nFrom: bob@example.com\nSubject: second\n\nreply\n"
+
+	got, err := ExtractMbox([]byte(data))
+	require.NoError(t, err)
+	for _, str := range []string{"first", "From the start", "second", "reply"} {
+		require.Contains(t, got.Text, str)
+	}
+
+	require.NotContains(t, got.Text, "invalid message")
+	require.Equal(t, map[string]string{
+		FieldFrom:    "alice@example.com\nbob@example.com",
+		FieldSubject: "first\nsecond",
+	}, got.Fields)
+}
+
+func TestExtractStructured(t *testing.T) {
+	book := `{
+		"title": "Metamorphosis",
+		"author": {"name": "Franz Kafka", "born": 1883},
+		"tags": ["novella", "classic"],
+		"translated": true,
+		"isbn": null
+	}`
+	books := "title,author,year\nMetamorphosis,Franz Kafka,1915\n\"The Trial\",Franz Kafka,1925,extra\n"
+
+	cases := map[string]struct {
+		extract    Extractor
+		data       string
+		want       []string
+		wantFields map[string]string
+		wantErr    string
+	}{
+		"json": {
+			extract: NewJSONExtractor(nil),
+			data:    book,
+			want:    []string{"Metamorphosis", "Franz Kafka", "1883", "novella", "classic", "true"},
+			wantFields: map[string]string{
+				"title":       "Metamorphosis",
+				"author.name": "Franz Kafka",
+				"author.born": "1883",
+				"tags":        "novella\nclassic",
+				"translated":  "true",
+			},
+		},
+		"json with field paths": {
+			extract: NewJSONExtractor([]string{" Author ", "tags", "missing"}),
+			data:    book,
+			want:    []string{"Metamorphosis", "Franz Kafka", "novella"},
+			wantFields: map[string]string{
+				"author": "1883\nFranz Kafka",
+				"tags":   "novella\nclassic",
+			},
+		},
+		"malformed json": {
+			extract: NewJSONExtractor(nil),
+			data:    `{"title": `,
+			wantErr: "failed to parse json document",
+		},
+		"csv": {
+			extract: NewCSVExtractor(nil),
+			data:    books,
+			want:    []string{"title", "Metamorphosis", "The Trial", "1925", "extra"},
+			wantFields: map[string]string{
+				"title":  "Metamorphosis\nThe Trial",
+				"author": "Franz Kafka\nFranz Kafka",
+				"year":   "1915\n1925",
+			},
+		},
+		"csv with field paths": {
+			extract: NewCSVExtractor([]string{"TITLE"}),
+			data:    books,
+			want:    []string{"Franz Kafka"},
+			wantFields: map[string]string{
+				"title": "Metamorphosis\nThe Trial",
+			},
+		},
+	}
+
+	for n, c := range cases {
+		t.Run(n, func(t *testing.T) {
+			got, err := c.extract.Extract([]byte(c.data))
+			if c.wantErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			for _, str := range c.want {
+				require.Contains(t, got.Text, str)
+			}
+
+			require.Equal(t, c.wantFields, got.Fields)
+		})
+	}
+}
+
+func TestExtractOffice(t *testing.T) {
+	cases := map[string]struct {
+		extract    ExtractorFunc
+		data       []byte
+		want       []string
+		wantFields map[string]string
+		notWant    []string
+		wantErr    string
+	}{
+		"docx": {
+			extract: ExtractDOCX,
+			data:    readTestData(t, "spec.docx"),
+			want: []string{
+				"Storage service specification\n", "Documents are replicated across three zones.\n", "Latency\nbudget\n",
+			},
+			wantFields: map[string]string{
+				FieldTitle:   "Storage service specification",
+				FieldHeading: "Replication",
+			},
+			notWant: []string{"Heading1", "schemas.openxmlformats.org"},
+		},
+		"odt": {
+			extract:    ExtractODT,
+			data:       readTestData(t, "spec.odt"),
+			want:       []string{"Deployment guide\n", "Services are deployed by the platform team.\nRollbacks are automatic.\n", "Canary"},
+			wantFields: map[string]string{FieldHeading: "Deployment guide"},
+			notWant:    []string{"P1", "opendocument"},
+		},
+		"xlsx": {
+			extract: ExtractXLSX,
+			data:    readTestData(t, "budget.xlsx"),
+			want:    []string{"Department\tSpending\t\n", "Marketing\t1200\t\n", "Research lab\toverspent\t\t\n"},
+			notWant: []string{"phonetic", "SUM", "CONCAT", "Budget"},
+		},
+		"docx without document part": {
+			extract: ExtractDOCX,
+			data:    newZip(t, map[string]string{"word/styles.xml": "<styles/>"}),
+			wantErr: "failed to read DOCX document",
+		},
+		"malformed xml": {
+			extract: ExtractODT,
+			data:    newZip(t, map[string]string{"content.xml": "<office:body><text:p>foo"}),
+			wantErr: "failed to read ODT document",
+		},
+		"not an archive": {
+			extract: ExtractXLSX,
+			data:    []byte("foo"),
+			wantErr: "failed to read XLSX document",
+		},
+		"highly compressed document": {
+			extract: ExtractDOCX,
+			data: newZip(t, map[string]string{
+				docxDocumentPart: "<w:document>" + strings.Repeat(" ", maxOfficeUncompressedSize) + "</w:document>",
+			}),
+			wantErr: "uncompressed document size exceeds",
+		},
+	}
+
+	for n, c := range cases {
+		t.Run(n, func(t *testing.T) {
+			got, err := c.extract(c.data)
+			if c.wantErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			for _, str := range c.want {
+				require.Contains(t, got.Text, str)
+			}
+
+			for _, str := range c.notWant {
+				require.NotContains(t, got.Text, str)
+			}
+
+			require.Equal(t, c.wantFields, got.Fields)
+		})
+	}
+}
+
+func newZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buff bytes.Buffer
+	w := zip.NewWriter(&buff)
+	for name, data := range files {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(data))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, w.Close())
+	return buff.Bytes()
+}
+
+func TestDetectCharset(t *testing.T) {
+	cases := map[string]struct {
+		contentType string
+		data        []byte
+		want        string
+	}{
+		"utf-8": {
+			contentType: "text/plain",
+			data:        []byte("Grüße, мир"),
+			want:        "utf-8",
+		},
+		"utf-8 bom": {
+			contentType: "text/plain; charset=windows-1251",
+			data:        []byte("\xEF\xBB\xBFfoo"),
+			want:        "utf-8",
+		},
+		"utf-16le bom": {
+			contentType: "text/csv",
+			data:        encodeText(t, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), "foo"),
+			want:        "utf-16le",
+		},
+		"utf-16le without bom": {
+			contentType: "text/plain",
+			data:        encodeText(t, unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), "foo bar"),
+			want:        "utf-16le",
+		},
+		"content type charset": {
+			contentType: "text/plain; charset=KOI8-R",
+			data:        []byte("foo"),
+			want:        "koi8-r",
+		},
+		"latin-1 alias": {
+			contentType: "text/plain; charset=latin1",
+			data:        []byte("foo"),
+			want:        "windows-1252",
+		},
+		"unknown charset": {
+			contentType: "text/plain; charset=foo",
+			data:        []byte("foo"),
+			want:        "utf-8",
+		},
+		"html meta": {
+			contentType: "text/html",
+			data:        append([]byte(`<meta charset="windows-1251"><p>`), encodeText(t, charmap.Windows1251, "Привет")...),
+			want:        "windows-1251",
+		},
+		"windows-1251": {
+			contentType: "text/plain",
+			data:        encodeText(t, charmap.Windows1251, "Отчёт о продажах, version 2"),
+			want:        "windows-1251",
+		},
+		"latin-1": {
+			contentType: "text/plain",
+			data:        encodeText(t, charmap.ISO8859_1, "Café, crème brûlée and déjà vu"),
+			want:        "windows-1252",
+		},
+		"binary": {
+			contentType: "application/pdf",
+			data:        []byte("%PDF-1.4"),
+		},
+	}
+
+	for n, c := range cases {
+		t.Run(n, func(t *testing.T) {
+			require.Equal(t, c.want, DetectCharset(c.contentType, c.data))
+		})
+	}
+}
+
+func encodeText(t *testing.T, enc encoding.Encoding, str string) []byte {
+	t.Helper()
+	data, err := enc.NewEncoder().Bytes([]byte(str))
+	require.NoError(t, err)
+	return data
+}
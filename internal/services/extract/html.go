@@ -0,0 +1,142 @@
+package extract
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	// MIMETypeHTML is HTML document MIME type.
+	MIMETypeHTML = "text/html"
+
+	// MIMETypeXHTML is XHTML document MIME type.
+	MIMETypeXHTML = "application/xhtml+xml"
+)
+
+// hiddenElements is list of elements which contents is not displayed.
+var hiddenElements = map[atom.Atom]bool{
+	atom.Script:   true,
+	atom.Style:    true,
+	atom.Noscript: true,
+	atom.Template: true,
+	atom.Head:     true,
+	atom.Svg:      true,
+	atom.Math:     true,
+}
+
+// headingElements is list of heading elements.
+var headingElements = map[atom.Atom]bool{
+	atom.H1: true,
+	atom.H2: true,
+	atom.H3: true,
+	atom.H4: true,
+	atom.H5: true,
+	atom.H6: true,
+}
+
+// inlineElements is list of elements which don't separate words.
+var inlineElements = map[atom.Atom]bool{
+	atom.A:      true,
+	atom.Abbr:   true,
+	atom.B:      true,
+	atom.Bdi:    true,
+	atom.Bdo:    true,
+	atom.Cite:   true,
+	atom.Code:   true,
+	atom.Data:   true,
+	atom.Dfn:    true,
+	atom.Em:     true,
+	atom.I:      true,
+	atom.Kbd:    true,
+	atom.Mark:   true,
+	atom.Q:      true,
+	atom.S:      true,
+	atom.Samp:   true,
+	atom.Small:  true,
+	atom.Span:   true,
+	atom.Strong: true,
+	atom.Sub:    true,
+	atom.Sup:    true,
+	atom.Time:   true,
+	atom.U:      true,
+	atom.Var:    true,
+	atom.Wbr:    true,
+}
+
+// ExtractHTML returns visible text of HTML document.
+//
+// Tags, attributes and contents of scripts and styles are omitted.
+// Document title and headings are also returned as FieldTitle and FieldHeading fields.
+func ExtractHTML(data []byte) (*Content, error) {
+	// Parser tolerates malformed markup and closes implied elements, e.g. document head.
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	var text strings.Builder
+	content := new(Content)
+	walkHTML(doc, &text, content)
+	content.Text = text.String()
+	return content, nil
+}
+
+// walkHTML writes visible text of node and its children.
+func walkHTML(n *html.Node, w *strings.Builder, content *Content) {
+	switch n.Type {
+	case html.TextNode:
+		w.WriteString(n.Data)
+		return
+	case html.ElementNode:
+		switch {
+		case n.DataAtom == atom.Title:
+			title := nodeText(n)
+			content.addField(FieldTitle, title)
+			w.WriteString(title)
+			w.WriteByte('\n')
+			return
+		case hiddenElements[n.DataAtom]:
+			// Document head has no visible elements except title.
+			if n.DataAtom == atom.Head {
+				for c := n.FirstChild; c != nil; c = c.NextSibling {
+					if c.DataAtom == atom.Title {
+						walkHTML(c, w, content)
+					}
+				}
+			}
+
+			return
+		case headingElements[n.DataAtom]:
+			content.addField(FieldHeading, nodeText(n))
+		}
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walkHTML(c, w, content)
+	}
+
+	if n.Type == html.ElementNode && !inlineElements[n.DataAtom] {
+		// Block elements separate words.
+		w.WriteByte('\n')
+	}
+}
+
+// nodeText returns visible text of node children.
+func nodeText(n *html.Node) string {
+	var w strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walkHTML(c, &w, new(Content))
+	}
+
+	return strings.TrimSpace(w.String())
+}
+
+// addField appends non-empty text to a field.
+func (c *Content) addField(name, text string) {
+	if text != "" {
+		c.AddField(name, text)
+	}
+}
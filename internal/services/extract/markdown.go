@@ -0,0 +1,102 @@
+package extract
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// MIMETypeMarkdown is Markdown document MIME type.
+const MIMETypeMarkdown = "text/markdown"
+
+var (
+	atxHeadingRe      = regexp.MustCompile(`^ {0,3}#{1,6}(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	setextUnderlineRe = regexp.MustCompile(`^ {0,3}(?:=+|-+)[ \t]*$`)
+	codeFenceRe       = regexp.MustCompile("^ {0,3}(`{3,}|~{3,})")
+	linkDefinitionRe  = regexp.MustCompile(`^ {0,3}\[[^\]]+\]:[ \t]*\S+`)
+
+	hiddenHTMLRe = regexp.MustCompile(`(?is)<script\b.*?</script\s*>|<style\b.*?</style\s*>|<!--.*?-->`)
+	autolinkRe   = regexp.MustCompile(`<((?:https?|ftp|mailto):[^>\s]+)>`)
+	imageRe      = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	linkRe       = regexp.MustCompile(`\[([^\]]*)\](?:\([^)]*\)|\[[^\]]*\])`)
+	htmlTagRe    = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+)
+
+// ExtractMarkdown returns visible text of Markdown document.
+//
+// Link destinations, inline HTML tags, scripts and styles are omitted.
+// Contents of code blocks is kept as is.
+// Headings are also returned as FieldHeading field.
+func ExtractMarkdown(data []byte) (*Content, error) {
+	var (
+		text, block strings.Builder
+		fence       string
+		paragraph   string
+		content     = new(Content)
+	)
+
+	// Inline markup is processed per block of lines, as inline HTML and links might span several lines.
+	flush := func() {
+		text.WriteString(stripMarkdownInline(block.String()))
+		block.Reset()
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if fence != "" {
+			if isClosingFence(line, fence) {
+				fence = ""
+				continue
+			}
+
+			text.WriteString(line)
+			text.WriteByte('\n')
+			continue
+		}
+
+		prevParagraph := paragraph
+		paragraph = ""
+		switch {
+		case codeFenceRe.MatchString(line):
+			// Fence info string is omitted.
+			flush()
+			fence = codeFenceRe.FindStringSubmatch(line)[1]
+		case linkDefinitionRe.MatchString(line):
+		case atxHeadingRe.MatchString(line):
+			heading := atxHeadingRe.FindStringSubmatch(line)[1]
+			content.addField(FieldHeading, strings.TrimSpace(stripMarkdownInline(heading)))
+			block.WriteString(heading)
+			block.WriteByte('\n')
+		case setextUnderlineRe.MatchString(line) && prevParagraph != "":
+			content.addField(FieldHeading, strings.TrimSpace(stripMarkdownInline(prevParagraph)))
+		default:
+			if strings.TrimSpace(line) != "" {
+				paragraph = line
+			}
+
+			block.WriteString(line)
+			block.WriteByte('\n')
+		}
+	}
+
+	flush()
+	content.Text = text.String()
+	return content, nil
+}
+
+// isClosingFence reports whether line closes a code block opened by fence.
+func isClosingFence(line, fence string) bool {
+	line = strings.TrimSpace(line)
+	return strings.HasPrefix(line, fence) && strings.Trim(line, fence[:1]) == ""
+}
+
+// stripMarkdownInline removes inline markup which contains words not visible in rendered document.
+func stripMarkdownInline(str string) string {
+	str = hiddenHTMLRe.ReplaceAllString(str, " ")
+	str = autolinkRe.ReplaceAllString(str, "$1")
+	str = imageRe.ReplaceAllString(str, "$1")
+	str = linkRe.ReplaceAllString(str, "$1")
+	str = htmlTagRe.ReplaceAllString(str, " ")
+	return html.UnescapeString(str)
+}
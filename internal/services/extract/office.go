@@ -0,0 +1,423 @@
+package extract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MIMETypeDOCX is Word document MIME type.
+	MIMETypeDOCX = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+
+	// MIMETypeXLSX is Excel spreadsheet MIME type.
+	MIMETypeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+	// MIMETypeODT is OpenDocument text document MIME type.
+	MIMETypeODT = "application/vnd.oasis.opendocument.text"
+
+	// mimeTypeZIP is MIME type of ZIP archive, which is detected for office documents.
+	mimeTypeZIP = "application/zip"
+)
+
+// maxOfficeUncompressedSize is max total uncompressed size of office document parts which are read.
+//
+// Limits memory and CPU consumption by highly compressed documents.
+const maxOfficeUncompressedSize = 64 << 20
+
+const (
+	docxDocumentPart      = "word/document.xml"
+	xlsxWorkbookPart      = "xl/workbook.xml"
+	xlsxSharedStringsPart = "xl/sharedStrings.xml"
+	xlsxWorksheetsDir     = "xl/worksheets"
+	odtMIMETypePart       = "mimetype"
+	odtContentPart        = "content.xml"
+)
+
+var errOfficeTooLarge = fmt.Errorf("uncompressed document size exceeds %d bytes", maxOfficeUncompressedSize)
+
+// ExtractDOCX returns text of Word document paragraphs.
+//
+// Paragraphs with "Title" and "Heading" styles are also returned as FieldTitle and FieldHeading fields.
+func ExtractDOCX(data []byte) (*Content, error) {
+	doc, err := openOfficeDocument(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DOCX document: %w", err)
+	}
+
+	var (
+		text, para strings.Builder
+		style      string
+		inText     bool
+		content    = new(Content)
+	)
+	err = doc.walkPart(docxDocumentPart, func(tok xml.Token) {
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				para.WriteByte('\t')
+			case "br", "cr":
+				para.WriteByte('\n')
+			case "pStyle":
+				style = xmlAttr(t, "val")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				writeParagraph(&text, content, para.String(), docxParagraphField(style))
+				para.Reset()
+				style = ""
+			}
+		case xml.CharData:
+			if inText {
+				para.Write(t)
+			}
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DOCX document: %w", err)
+	}
+
+	content.Text = text.String()
+	return content, nil
+}
+
+// ExtractODT returns text of OpenDocument text document.
+//
+// Headings are also returned as FieldHeading field.
+func ExtractODT(data []byte) (*Content, error) {
+	doc, err := openOfficeDocument(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ODT document: %w", err)
+	}
+
+	var (
+		text, para strings.Builder
+		inBody     bool
+		content    = new(Content)
+	)
+	err = doc.walkPart(odtContentPart, func(tok xml.Token) {
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "body":
+				inBody = true
+			case "s":
+				para.WriteByte(' ')
+			case "tab":
+				para.WriteByte('\t')
+			case "line-break":
+				para.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "body":
+				inBody = false
+			case "p":
+				writeParagraph(&text, content, para.String(), "")
+				para.Reset()
+			case "h":
+				writeParagraph(&text, content, para.String(), FieldHeading)
+				para.Reset()
+			}
+		case xml.CharData:
+			if inBody {
+				para.Write(t)
+			}
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ODT document: %w", err)
+	}
+
+	content.Text = text.String()
+	return content, nil
+}
+
+// ExtractXLSX returns text of Excel spreadsheet cells.
+//
+// Cells are separated by a tab and rows by a line break.
+// Formulas are omitted, only calculated values are returned.
+func ExtractXLSX(data []byte) (*Content, error) {
+	doc, err := openOfficeDocument(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read XLSX document: %w", err)
+	}
+
+	sharedStrings, err := doc.sharedStrings()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read XLSX shared strings: %w", err)
+	}
+
+	var (
+		text, value strings.Builder
+		cellType    string
+		inValue     bool
+	)
+	for _, sheet := range doc.worksheets() {
+		err = doc.walkPart(sheet, func(tok xml.Token) {
+			switch t := tok.(type) {
+			case xml.StartElement:
+				switch t.Name.Local {
+				case "c":
+					cellType = xmlAttr(t, "t")
+					value.Reset()
+				case "v", "t":
+					inValue = true
+				}
+			case xml.EndElement:
+				switch t.Name.Local {
+				case "v", "t":
+					inValue = false
+				case "c":
+					text.WriteString(cellText(cellType, value.String(), sharedStrings))
+					text.WriteByte('\t')
+				case "row":
+					text.WriteByte('\n')
+				}
+			case xml.CharData:
+				if inValue {
+					value.Write(t)
+				}
+			}
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read XLSX worksheet %q: %w", sheet, err)
+		}
+	}
+
+	return &Content{Text: text.String()}, nil
+}
+
+// detectOfficeType returns MIME type of office document stored as ZIP archive.
+//
+// Returns empty string if data is not a supported office document.
+func detectOfficeType(data []byte) string {
+	doc, err := openOfficeDocument(data)
+	if err != nil {
+		return ""
+	}
+
+	// OpenDocument stores MIME type in the first archive entry.
+	if mimeType, err := doc.readPart(odtMIMETypePart); err == nil {
+		if strings.TrimSpace(string(mimeType)) == MIMETypeODT {
+			return MIMETypeODT
+		}
+
+		return ""
+	}
+
+	switch {
+	case doc.hasPart(docxDocumentPart):
+		return MIMETypeDOCX
+	case doc.hasPart(xlsxWorkbookPart):
+		return MIMETypeXLSX
+	}
+
+	return ""
+}
+
+// officeDocument is ZIP container of office document XML parts.
+type officeDocument struct {
+	zr *zip.Reader
+
+	// remaining is number of uncompressed bytes which can be read from document parts.
+	remaining int64
+}
+
+func openOfficeDocument(data []byte) (*officeDocument, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	return &officeDocument{zr: zr, remaining: maxOfficeUncompressedSize}, nil
+}
+
+// hasPart reports whether document contains a part.
+func (d *officeDocument) hasPart(name string) bool {
+	_, err := fs.Stat(d.zr, name)
+	return err == nil
+}
+
+// readPart returns contents of a document part.
+func (d *officeDocument) readPart(name string) ([]byte, error) {
+	f, err := d.zr.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	return ioutil.ReadAll(d.limitReader(f))
+}
+
+// walkPart calls fn for each token of XML document part.
+func (d *officeDocument) walkPart(name string, fn func(tok xml.Token)) error {
+	f, err := d.zr.Open(name)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+	dec := xml.NewDecoder(d.limitReader(f))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		fn(tok)
+	}
+}
+
+// worksheets returns names of spreadsheet worksheet parts in order of sheet number.
+func (d *officeDocument) worksheets() []string {
+	var sheets []string
+	for _, f := range d.zr.File {
+		if path.Dir(f.Name) == xlsxWorksheetsDir && path.Ext(f.Name) == ".xml" {
+			sheets = append(sheets, f.Name)
+		}
+	}
+
+	sort.Slice(sheets, func(i, j int) bool {
+		return sheetNumber(sheets[i]) < sheetNumber(sheets[j])
+	})
+	return sheets
+}
+
+// sharedStrings returns spreadsheet strings table.
+//
+// Phonetic hints of strings are omitted.
+func (d *officeDocument) sharedStrings() ([]string, error) {
+	var (
+		items      []string
+		item       strings.Builder
+		inText     bool
+		inPhonetic bool
+	)
+	err := d.walkPart(xlsxSharedStringsPart, func(tok xml.Token) {
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "si":
+				item.Reset()
+			case "t":
+				inText = true
+			case "rPh":
+				inPhonetic = true
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "si":
+				items = append(items, item.String())
+			case "t":
+				inText = false
+			case "rPh":
+				inPhonetic = false
+			}
+		case xml.CharData:
+			if inText && !inPhonetic {
+				item.Write(t)
+			}
+		}
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		// Spreadsheet without text cells might have no strings table.
+		return nil, nil
+	}
+
+	return items, err
+}
+
+// limitReader returns reader which fails if document uncompressed size limit is exceeded.
+func (d *officeDocument) limitReader(r io.Reader) io.Reader {
+	return &officeReader{r: r, doc: d}
+}
+
+// officeReader is io.Reader which counts bytes read from document parts.
+type officeReader struct {
+	r   io.Reader
+	doc *officeDocument
+}
+
+// Read implements io.Reader
+func (o *officeReader) Read(p []byte) (int, error) {
+	n, err := o.r.Read(p)
+	o.doc.remaining -= int64(n)
+	if o.doc.remaining < 0 {
+		return n, errOfficeTooLarge
+	}
+
+	return n, err
+}
+
+// cellText returns spreadsheet cell text by cell type and value.
+func cellText(cellType, value string, sharedStrings []string) string {
+	if cellType != "s" {
+		return value
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil || i < 0 || i >= len(sharedStrings) {
+		return ""
+	}
+
+	return sharedStrings[i]
+}
+
+// sheetNumber returns number of worksheet part, e.g. 2 for "xl/worksheets/sheet2.xml".
+func sheetNumber(name string) int {
+	name = strings.TrimSuffix(path.Base(name), ".xml")
+	n, _ := strconv.Atoi(strings.TrimLeft(name, "abcdefghijklmnopqrstuvwxyz"))
+	return n
+}
+
+// docxParagraphField returns document field of a paragraph with specified style.
+func docxParagraphField(style string) string {
+	switch {
+	case style == "Title":
+		return FieldTitle
+	case strings.HasPrefix(style, "Heading"):
+		return FieldHeading
+	}
+
+	return ""
+}
+
+// writeParagraph writes paragraph to document text and appends it to a field if field is not empty.
+func writeParagraph(w *strings.Builder, content *Content, para, field string) {
+	w.WriteString(para)
+	w.WriteByte('\n')
+	if field != "" {
+		content.addField(field, strings.TrimSpace(para))
+	}
+}
+
+// xmlAttr returns value of element attribute with specified local name.
+func xmlAttr(el xml.StartElement, name string) string {
+	for _, attr := range el.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+
+	return ""
+}
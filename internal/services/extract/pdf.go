@@ -0,0 +1,55 @@
+package extract
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// MIMETypePDF is PDF document MIME type.
+const MIMETypePDF = "application/pdf"
+
+// ExtractPDF returns text of all PDF document pages.
+//
+// Pages are separated by a line break.
+func ExtractPDF(data []byte) (content *Content, err error) {
+	// PDF reader panics on malformed documents.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("malformed PDF document: %v", r)
+		}
+	}()
+
+	r, err := pdf.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PDF document: %w", err)
+	}
+
+	var buff bytes.Buffer
+	fonts := make(map[string]*pdf.Font)
+	for i := 1; i <= r.NumPage(); i++ {
+		page := r.Page(i)
+		if page.V.IsNull() {
+			continue
+		}
+
+		// Fonts are cached to avoid parsing of the same font on each page.
+		for _, name := range page.Fonts() {
+			if _, ok := fonts[name]; !ok {
+				font := page.Font(name)
+				fonts[name] = &font
+			}
+		}
+
+		pageText, err := page.GetPlainText(fonts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read PDF page %d: %w", i, err)
+		}
+
+		buff.WriteString(pageText)
+		buff.WriteByte('\n')
+	}
+
+	return &Content{Text: buff.String()}, nil
+}
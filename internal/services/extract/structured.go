@@ -0,0 +1,183 @@
+package extract
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const (
+	// MIMETypeJSON is JSON document MIME type.
+	MIMETypeJSON = "application/json"
+
+	// MIMETypeCSV is CSV document MIME type.
+	MIMETypeCSV = "text/csv"
+)
+
+// fieldPathSeparator separates object keys in JSON field path.
+const fieldPathSeparator = "."
+
+// fieldPaths selects fields of structured documents which are indexed as separate fields.
+type fieldPaths []string
+
+// newFieldPaths returns lower-cased list of field paths.
+func newFieldPaths(paths []string) fieldPaths {
+	out := make(fieldPaths, 0, len(paths))
+	for _, p := range paths {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			out = append(out, p)
+		}
+	}
+
+	return out
+}
+
+// fieldName returns name of indexed field which contains value at specified path.
+//
+// Value belongs to a field if its path matches field path or is nested in it,
+// e.g. "author.name" value belongs to "author" field.
+// All values are indexed by their own path if no field paths are set.
+func (f fieldPaths) fieldName(valuePath string) (string, bool) {
+	valuePath = strings.ToLower(valuePath)
+	if len(f) == 0 {
+		return valuePath, valuePath != ""
+	}
+
+	for _, p := range f {
+		if valuePath == p || strings.HasPrefix(valuePath, p+fieldPathSeparator) {
+			return p, true
+		}
+	}
+
+	return "", false
+}
+
+// NewJSONExtractor returns extractor of JSON document values.
+//
+// Each value is indexed as a field named by dot-separated path of object keys, e.g. "author.name".
+// Array items share path of an array. If paths are set, only values at specified paths
+// or nested in them are indexed as fields, while all values are included in document text.
+func NewJSONExtractor(paths []string) Extractor {
+	fields := newFieldPaths(paths)
+	return ExtractorFunc(func(data []byte) (*Content, error) {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return nil, fmt.Errorf("failed to parse json document: %w", err)
+		}
+
+		content := new(Content)
+		var text strings.Builder
+		writeJSONValue(&text, content, fields, "", v)
+		content.Text = text.String()
+		return content, nil
+	})
+}
+
+// writeJSONValue writes text of JSON value and adds it to content fields.
+func writeJSONValue(w *strings.Builder, content *Content, fields fieldPaths, valuePath string, v interface{}) {
+	var str string
+	switch val := v.(type) {
+	case map[string]interface{}:
+		// Keys are sorted to keep order of field values stable.
+		keys := make([]string, 0, len(val))
+		for key := range val {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+		for _, key := range keys {
+			item := val[key]
+			itemPath := key
+			if valuePath != "" {
+				itemPath = valuePath + fieldPathSeparator + key
+			}
+
+			writeJSONValue(w, content, fields, itemPath, item)
+		}
+
+		return
+	case []interface{}:
+		for _, item := range val {
+			writeJSONValue(w, content, fields, valuePath, item)
+		}
+
+		return
+	case string:
+		str = val
+	case json.Number:
+		str = val.String()
+	case bool:
+		str = fmt.Sprint(val)
+	default:
+		return
+	}
+
+	w.WriteString(str)
+	w.WriteString("\n")
+	if name, ok := fields.fieldName(valuePath); ok {
+		content.addField(name, str)
+	}
+}
+
+// NewCSVExtractor returns extractor of CSV document cells.
+//
+// First row is treated as header, each cell is indexed as a field named by its column header.
+// If paths are set, only cells of specified columns are indexed as fields,
+// while all cells are included in document text.
+func NewCSVExtractor(paths []string) Extractor {
+	fields := newFieldPaths(paths)
+	return ExtractorFunc(func(data []byte) (*Content, error) {
+		r := csv.NewReader(bytes.NewReader(data))
+		r.FieldsPerRecord = -1
+		r.LazyQuotes = true
+		r.ReuseRecord = true
+
+		content := new(Content)
+		var (
+			text   strings.Builder
+			header []string
+		)
+		for {
+			record, err := r.Read()
+			if err == io.EOF {
+				break
+			}
+
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse csv document: %w", err)
+			}
+
+			isHeader := header == nil
+			if isHeader {
+				header = make([]string, len(record))
+				for i, name := range record {
+					header[i] = strings.TrimSpace(name)
+				}
+			}
+
+			for i, cell := range record {
+				text.WriteString(cell)
+				text.WriteString("\t")
+				if isHeader || i >= len(header) {
+					continue
+				}
+
+				if name, ok := fields.fieldName(header[i]); ok {
+					content.addField(name, cell)
+				}
+			}
+
+			text.WriteString("\n")
+		}
+
+		content.Text = text.String()
+		return content, nil
+	})
+}
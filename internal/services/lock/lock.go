@@ -0,0 +1,15 @@
+// Package lock provides exclusive locks by key.
+package lock
+
+import "context"
+
+// UnlockFunc releases acquired lock.
+type UnlockFunc func() error
+
+// Locker is abstract keyed lock.
+type Locker interface {
+	// Lock acquires exclusive lock for a key.
+	//
+	// Blocks until lock is acquired or context is cancelled.
+	Lock(ctx context.Context, key string) (UnlockFunc, error)
+}
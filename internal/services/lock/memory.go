@@ -0,0 +1,72 @@
+package lock
+
+import (
+	"context"
+	"sync"
+)
+
+type keyLock struct {
+	ch   chan struct{}
+	refs int
+}
+
+// MemoryLocker is in-process keyed lock.
+//
+// Suitable only for single service instance deployments.
+type MemoryLocker struct {
+	mu    sync.Mutex
+	locks map[string]*keyLock
+}
+
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{locks: make(map[string]*keyLock)}
+}
+
+// Lock implements Locker
+func (l *MemoryLocker) Lock(ctx context.Context, key string) (UnlockFunc, error) {
+	kl := l.acquire(key)
+	select {
+	case kl.ch <- struct{}{}:
+	case <-ctx.Done():
+		l.release(key)
+		return nil, ctx.Err()
+	}
+
+	var once sync.Once
+	return func() error {
+		once.Do(func() {
+			<-kl.ch
+			l.release(key)
+		})
+		return nil
+	}, nil
+}
+
+// acquire returns lock for a key and increments number of its users.
+func (l *MemoryLocker) acquire(key string) *keyLock {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	kl, ok := l.locks[key]
+	if !ok {
+		kl = &keyLock{ch: make(chan struct{}, 1)}
+		l.locks[key] = kl
+	}
+
+	kl.refs++
+	return kl
+}
+
+// release decrements number of lock users and removes unused lock.
+func (l *MemoryLocker) release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	kl, ok := l.locks[key]
+	if !ok {
+		return
+	}
+
+	kl.refs--
+	if kl.refs == 0 {
+		delete(l.locks, key)
+	}
+}
@@ -0,0 +1,97 @@
+package lock
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryLocker_Lock(t *testing.T) {
+	l := NewMemoryLocker()
+	testLockerExclusive(t, l)
+	require.Empty(t, l.locks, "unused locks should be removed")
+}
+
+func TestMemoryLocker_LockCancel(t *testing.T) {
+	l := NewMemoryLocker()
+	testLockerCancel(t, l)
+
+	// Key should be still lockable after cancelled lock attempt
+	unlock, err := l.Lock(context.TODO(), "foo")
+	require.NoError(t, err)
+	require.NoError(t, unlock())
+	require.Empty(t, l.locks, "unused locks should be removed")
+}
+
+// testLockerExclusive checks that only one goroutine holds the lock for the same key.
+func testLockerExclusive(t *testing.T, l Locker) {
+	const workers = 20
+	type keyState struct {
+		counter int
+		active  int
+	}
+
+	var wg sync.WaitGroup
+	states := map[string]*keyState{"foo": {}, "bar": {}}
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		key := "foo"
+		if i%2 == 0 {
+			key = "bar"
+		}
+
+		go func(key string) {
+			defer wg.Done()
+			unlock, err := l.Lock(context.TODO(), key)
+			if !assertNoError(t, err) {
+				return
+			}
+
+			// State is intentionally accessed without sync primitives,
+			// race detector will catch concurrent access if lock doesn't work.
+			state := states[key]
+			state.counter++
+			state.active++
+			if state.active > 1 {
+				t.Errorf("lock %q is held by more than one goroutine", key)
+			}
+			time.Sleep(time.Millisecond)
+			state.active--
+			assertNoError(t, unlock())
+		}(key)
+	}
+
+	wg.Wait()
+	require.Equal(t, workers/2, states["foo"].counter)
+	require.Equal(t, workers/2, states["bar"].counter)
+}
+
+// testLockerCancel checks that lock attempt is cancelled by context.
+func testLockerCancel(t *testing.T, l Locker) {
+	unlock, err := l.Lock(context.TODO(), "foo")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 50*time.Millisecond)
+	defer cancel()
+	_, err = l.Lock(ctx, "foo")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Other keys are not affected
+	unlockOther, err := l.Lock(context.TODO(), "bar")
+	require.NoError(t, err)
+	require.NoError(t, unlockOther())
+	require.NoError(t, unlock())
+}
+
+func assertNoError(t *testing.T, err error) bool {
+	t.Helper()
+	if err != nil {
+		t.Error(err)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,171 @@
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"go.uber.org/zap"
+)
+
+const (
+	lockKeyPrefix = "lock:"
+
+	// DefaultTTL is default Redis lock expiration time.
+	DefaultTTL = time.Minute
+
+	minRetryInterval = 5 * time.Millisecond
+	maxRetryInterval = 200 * time.Millisecond
+)
+
+// ErrLockLost is returned on unlock when lock expired and was acquired by someone else.
+var ErrLockLost = errors.New("lock expired before release")
+
+// renewScript extends lock expiration time only if lock still belongs to lock owner.
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// unlockScript deletes lock key only if it still belongs to lock owner.
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// RedisLocker is distributed keyed lock for multi-instance deployments.
+//
+// Lock is a Redis key with random token which is set only if key doesn't exist.
+// Lock expires after TTL to avoid dead locks if lock owner crashed.
+// While lock is held, its TTL is periodically extended by lock owner.
+type RedisLocker struct {
+	log  *zap.Logger
+	conn redis.Cmdable
+	ttl  time.Duration
+}
+
+// NewRedisLocker constructs a new Redis lock.
+//
+// DefaultTTL is used if ttl is zero.
+func NewRedisLocker(log *zap.Logger, conn redis.Cmdable, ttl time.Duration) *RedisLocker {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	return &RedisLocker{log: log, conn: conn, ttl: ttl}
+}
+
+// Lock implements Locker
+func (l RedisLocker) Lock(ctx context.Context, key string) (UnlockFunc, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	lockKey := lockKeyPrefix + key
+	retryInterval := minRetryInterval
+	for {
+		ok, err := l.conn.SetNX(ctx, lockKey, token, l.ttl).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(retryInterval):
+		}
+
+		if retryInterval *= 2; retryInterval > maxRetryInterval {
+			retryInterval = maxRetryInterval
+		}
+	}
+
+	stop := make(chan struct{})
+	renewResult := make(chan error, 1)
+	go func() {
+		renewResult <- l.renew(lockKey, token, stop)
+	}()
+
+	var (
+		once      sync.Once
+		unlockErr error
+	)
+	return func() error {
+		once.Do(func() {
+			close(stop)
+			if unlockErr = <-renewResult; unlockErr != nil {
+				return
+			}
+
+			unlockErr = l.unlock(lockKey, token)
+		})
+		return unlockErr
+	}, nil
+}
+
+// unlock releases lock if it still belongs to lock owner.
+func (l RedisLocker) unlock(lockKey, token string) error {
+	// Lock should be released even if operation context is cancelled.
+	deleted, err := unlockScript.Run(context.Background(), l.conn, []string{lockKey}, token).Int()
+	if err != nil {
+		return err
+	}
+
+	if deleted == 0 {
+		l.log.Error("lock expired before release", zap.String("key", lockKey))
+		return ErrLockLost
+	}
+
+	return nil
+}
+
+// renew extends lock TTL until stop channel is closed.
+//
+// Returns ErrLockLost if lock expired and was acquired by someone else.
+// Renewal errors are only logged, as lock is still valid until TTL is exceeded.
+func (l RedisLocker) renew(lockKey, token string, stop <-chan struct{}) error {
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+
+		ok, err := renewScript.Run(context.Background(), l.conn, []string{lockKey},
+			token, l.ttl.Milliseconds()).Int()
+		if err != nil {
+			l.log.Warn("failed to extend lock TTL", zap.String("key", lockKey), zap.Error(err))
+			continue
+		}
+
+		if ok == 0 {
+			l.log.Error("lock expired before release", zap.String("key", lockKey))
+			return ErrLockLost
+		}
+	}
+}
+
+func newToken() (string, error) {
+	buff := make([]byte, 16)
+	if _, err := rand.Read(buff); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(buff), nil
+}
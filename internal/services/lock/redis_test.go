@@ -0,0 +1,67 @@
+package lock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	srv := miniredis.RunT(t)
+	conn := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	return srv, conn
+}
+
+func TestRedisLocker_Lock(t *testing.T) {
+	srv, conn := newTestRedis(t)
+	l := NewRedisLocker(zaptest.NewLogger(t), conn, time.Minute)
+	testLockerExclusive(t, l)
+	require.Empty(t, srv.Keys(), "lock keys should be removed")
+}
+
+func TestRedisLocker_LockCancel(t *testing.T) {
+	_, conn := newTestRedis(t)
+	testLockerCancel(t, NewRedisLocker(zaptest.NewLogger(t), conn, time.Minute))
+}
+
+func TestRedisLocker_Expire(t *testing.T) {
+	srv, conn := newTestRedis(t)
+	l := NewRedisLocker(zaptest.NewLogger(t), conn, time.Second)
+	unlock, err := l.Lock(context.TODO(), "foo")
+	require.NoError(t, err)
+
+	srv.FastForward(2 * time.Second)
+	unlockOther, err := l.Lock(context.TODO(), "foo")
+	require.NoError(t, err, "expired lock should be acquired")
+
+	require.ErrorIs(t, unlock(), ErrLockLost)
+	require.ErrorIs(t, unlock(), ErrLockLost, "repeated unlock should return the same result")
+	require.NoError(t, unlockOther(), "expired lock owner should not release a new lock")
+}
+
+func TestRedisLocker_Renew(t *testing.T) {
+	srv, conn := newTestRedis(t)
+	l := NewRedisLocker(zaptest.NewLogger(t), conn, 150*time.Millisecond)
+	unlock, err := l.Lock(context.TODO(), "foo")
+	require.NoError(t, err)
+
+	// Total elapsed time exceeds TTL, but lock should be kept by renewal.
+	for i := 0; i < 5; i++ {
+		srv.FastForward(100 * time.Millisecond)
+		require.True(t, srv.Exists(lockKeyPrefix+"foo"), "lock should be renewed")
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	require.NoError(t, unlock())
+	require.NoError(t, unlock(), "repeated unlock should be no-op")
+	require.Empty(t, srv.Keys(), "lock keys should be removed")
+}
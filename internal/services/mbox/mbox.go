@@ -0,0 +1,143 @@
+// Package mbox reads messages of mbox mailbox files.
+//
+// Both mboxo and mboxrd variants are supported: message separator is a line
+// which starts with "From " and quoted ">From " lines of message body are unquoted.
+package mbox
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"regexp"
+)
+
+// ErrInvalidFormat is returned if mailbox doesn't start with message separator line.
+var ErrInvalidFormat = errors.New("mailbox doesn't start with message separator")
+
+var separatorPrefix = []byte("From ")
+
+// separatorRe matches separator line of a message, e.g. "From MAILER-DAEMON Fri Jul  8 12:08:34 2011".
+var separatorRe = regexp.MustCompile(`^From \S+ +\S.*\d\d:\d\d(:\d\d)?`)
+
+// maxQuoteDepth is max number of ">" characters checked in quoted separator line.
+const maxQuoteDepth = 64
+
+// IsMbox reports whether data starts with mbox message separator line.
+func IsMbox(head []byte) bool {
+	line := head
+	if i := bytes.IndexByte(head, '\n'); i != -1 {
+		line = head[:i]
+	}
+
+	return separatorRe.Match(line)
+}
+
+// Reader reads messages of mbox file sequentially.
+type Reader struct {
+	br  *bufio.Reader
+	msg *messageReader
+}
+
+// NewReader returns a new mailbox reader.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{br: bufio.NewReader(r)}
+}
+
+// Next advances to the next message and returns its contents without separator line.
+//
+// Unread contents of previous message are skipped.
+// Returns io.EOF if there are no more messages.
+func (r *Reader) Next() (io.Reader, error) {
+	if r.msg != nil {
+		if _, err := io.Copy(ioutil.Discard, r.msg); err != nil {
+			return nil, err
+		}
+	}
+
+	// Separator line is skipped.
+	line, err := r.br.ReadSlice('\n')
+	if len(line) == 0 && err == io.EOF {
+		return nil, io.EOF
+	}
+
+	if !bytes.HasPrefix(line, separatorPrefix) {
+		return nil, ErrInvalidFormat
+	}
+
+	for err == bufio.ErrBufferFull {
+		_, err = r.br.ReadSlice('\n')
+	}
+
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	r.msg = &messageReader{br: r.br, lineStart: true}
+	return r.msg, nil
+}
+
+// messageReader reads message lines until the next separator line.
+type messageReader struct {
+	br        *bufio.Reader
+	line      []byte
+	buf       []byte
+	lineStart bool
+	done      bool
+}
+
+// Read implements io.Reader
+func (m *messageReader) Read(p []byte) (int, error) {
+	for len(m.buf) == 0 {
+		if m.done {
+			return 0, io.EOF
+		}
+
+		if err := m.fill(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, m.buf)
+	m.buf = m.buf[n:]
+	return n, nil
+}
+
+// fill reads the next line or a part of long line.
+func (m *messageReader) fill() error {
+	if m.lineStart {
+		head, _ := m.br.Peek(maxQuoteDepth + len(separatorPrefix))
+		if bytes.HasPrefix(head, separatorPrefix) {
+			// Separator line is left for the next message.
+			m.done = true
+			return nil
+		}
+
+		if isQuotedSeparator(head) {
+			if _, err := m.br.Discard(1); err != nil {
+				return err
+			}
+		}
+	}
+
+	chunk, err := m.br.ReadSlice('\n')
+	m.line = append(m.line[:0], chunk...)
+	m.buf = m.line
+	m.lineStart = bytes.HasSuffix(chunk, []byte{'\n'})
+	switch err {
+	case nil, bufio.ErrBufferFull:
+		return nil
+	case io.EOF:
+		m.done = true
+		return nil
+	}
+
+	return err
+}
+
+// isQuotedSeparator reports whether line is a separator line quoted with one or more ">" characters.
+func isQuotedSeparator(line []byte) bool {
+	unquoted := bytes.TrimLeft(line, ">")
+	return len(unquoted) < len(line) && bytes.HasPrefix(unquoted, separatorPrefix)
+}
@@ -0,0 +1,94 @@
+package mbox
+
+import (
+	"bufio"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsMbox(t *testing.T) {
+	cases := map[string]bool{
+		"From MAILER-DAEMON Fri Jul  8 12:08:34 2011\nFrom: a@b.c\n": true,
+		"From alice@example.com Mon Jan  2 15:04:05 2006":            true,
+		"From the author of this note, Jan 2 2006\n":                 false,
+		"From: alice@example.com\n":                                  false,
+		"":                                                           false,
+	}
+
+	for input, want := range cases {
+		require.Equal(t, want, IsMbox([]byte(input)), input)
+	}
+}
+
+func TestReader(t *testing.T) {
+	longLine := strings.Repeat("x", 2*bufio.MaxScanTokenSize/16)
+	cases := map[string]struct {
+		input   string
+		want    []string
+		wantErr error
+	}{
+		"empty": {},
+		"single message": {
+			input: "From a@b.c Mon Jan  2 15:04:05 2006\nSubject: one\n\nbody\n",
+			want:  []string{"Subject: one\n\nbody\n"},
+		},
+		"multiple messages": {
+			input: "From a@b.c Mon Jan  2 15:04:05 2006\nSubject: one\n\nfirst\n\n" +
+				"From d@e.f Mon Jan  2 15:04:05 2006\nSubject: two\n\nsecond",
+			want: []string{"Subject: one\n\nfirst\n\n", "Subject: two\n\nsecond"},
+		},
+		"quoted separator": {
+			input: "From a@b.c Mon Jan  2 15:04:05 2006\n\n>From here\n>>From there\n> From quote\nFrom",
+			want:  []string{"\nFrom here\n>From there\n> From quote\nFrom"},
+		},
+		"long lines": {
+			input: "From a@b.c Mon Jan  2 15:04:05 2006\n" + longLine + "\nFrom " + longLine + "\n" + longLine,
+			want:  []string{longLine + "\n", longLine},
+		},
+		"invalid format": {
+			input:   "Subject: one\n\nbody\n",
+			wantErr: ErrInvalidFormat,
+		},
+	}
+
+	for n, c := range cases {
+		t.Run(n, func(t *testing.T) {
+			var got []string
+			r := NewReader(strings.NewReader(c.input))
+			for {
+				msg, err := r.Next()
+				if c.wantErr != nil && err != nil {
+					require.ErrorIs(t, err, c.wantErr)
+					return
+				}
+
+				if err != nil {
+					break
+				}
+
+				data, err := ioutil.ReadAll(msg)
+				require.NoError(t, err)
+				got = append(got, string(data))
+			}
+
+			require.Nil(t, c.wantErr)
+			require.Equal(t, c.want, got)
+		})
+	}
+}
+
+func TestReader_SkipUnread(t *testing.T) {
+	input := "From a@b.c Mon Jan  2 15:04:05 2006\nfirst\n\nFrom d@e.f Mon Jan  2 15:04:05 2006\nsecond\n"
+	r := NewReader(strings.NewReader(input))
+	_, err := r.Next()
+	require.NoError(t, err)
+
+	msg, err := r.Next()
+	require.NoError(t, err)
+	data, err := ioutil.ReadAll(msg)
+	require.NoError(t, err)
+	require.Equal(t, "second\n", string(data))
+}
@@ -0,0 +1,130 @@
+package search
+
+import (
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/x1unix/docusearch/internal/utils/collections"
+)
+
+const (
+	// AnalyzerText splits text into words of letters and digits.
+	AnalyzerText = "text"
+
+	// AnalyzerCode splits source code into identifiers and indexes both
+	// identifiers and their camelCase, PascalCase, snake_case and kebab-case subwords.
+	AnalyzerCode = "code"
+)
+
+// codeExtensions is list of source code file extensions analyzed with AnalyzerCode.
+var codeExtensions = collections.NewStringsSet(
+	".go", ".ts", ".tsx", ".js", ".jsx", ".mjs", ".py", ".java", ".kt", ".scala", ".c", ".h",
+	".cc", ".cpp", ".hpp", ".cs", ".rs", ".rb", ".php", ".swift", ".sh",
+)
+
+// IsAnalyzer reports whether name is a supported analyzer name.
+func IsAnalyzer(name string) bool {
+	return name == AnalyzerText || name == AnalyzerCode
+}
+
+// DetectAnalyzer returns analyzer of a document by its name extension.
+//
+// AnalyzerCode is returned for source code files, AnalyzerText otherwise.
+func DetectAnalyzer(name string) string {
+	if codeExtensions.Has(strings.ToLower(path.Ext(name))) {
+		return AnalyzerCode
+	}
+
+	return AnalyzerText
+}
+
+// AnalyzeWords returns a list of unique words from string text using specified analyzer.
+//
+// AnalyzerText is used if analyzer is empty.
+func AnalyzeWords(analyzer, str string, ignoreList collections.StringsSet) []string {
+	if analyzer == AnalyzerCode {
+		return CodeWordsFromString(str, ignoreList)
+	}
+
+	return WordsFromString(str, ignoreList)
+}
+
+// CodeWordsFromString returns a list of unique identifiers and their subwords from source code text.
+//
+// For example, "SearchDocumentsByWord" produces "searchdocumentsbyword", "search", "documents", "by" and "word".
+func CodeWordsFromString(str string, ignoreList collections.StringsSet) []string {
+	uniqueWords := make(collections.StringsSet)
+	add := func(word string) {
+		lowered := strings.ToLower(word)
+		if lowered != "" && !ignoreList.Has(lowered) {
+			uniqueWords.Append(lowered)
+		}
+	}
+
+	for _, ident := range tokenizeCode(str) {
+		add(ident)
+		for _, word := range splitIdentifier(ident) {
+			add(word)
+		}
+	}
+
+	return uniqueWords.ToArray()
+}
+
+// tokenizeCode splits source code into identifiers.
+//
+// Leading and trailing underscores and hyphens are trimmed, e.g. "__init__" becomes "init".
+func tokenizeCode(str string) []string {
+	tokens := strings.FieldsFunc(str, func(r rune) bool {
+		return !unicode.IsNumber(r) && !unicode.IsLetter(r) && !isIdentifierSeparator(r)
+	})
+
+	out := tokens[:0]
+	for _, token := range tokens {
+		if token = strings.Trim(token, "_-"); token != "" {
+			out = append(out, token)
+		}
+	}
+
+	return out
+}
+
+// splitIdentifier splits identifier into snake_case, kebab-case and camelCase subwords.
+func splitIdentifier(ident string) []string {
+	var words []string
+	for _, part := range strings.FieldsFunc(ident, isIdentifierSeparator) {
+		words = append(words, splitCamelCase(part)...)
+	}
+
+	return words
+}
+
+// splitCamelCase splits camelCase or PascalCase word into subwords.
+//
+// Acronyms are kept as a single word, e.g. "HTTPServer" produces "HTTP" and "Server",
+// and plural acronyms are not split, e.g. "IDs".
+func splitCamelCase(word string) []string {
+	runes := []rune(word)
+	var words []string
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		prev, cur := runes[i-1], runes[i]
+		isBoundary := (unicode.IsLower(prev) || unicode.IsNumber(prev)) && unicode.IsUpper(cur)
+		if unicode.IsUpper(prev) && unicode.IsUpper(cur) && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			// Last capital letter of acronym starts a new word, unless it's a plural suffix.
+			isBoundary = !(runes[i+1] == 's' && i+2 == len(runes))
+		}
+
+		if isBoundary {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+
+	return append(words, string(runes[start:]))
+}
+
+func isIdentifierSeparator(r rune) bool {
+	return r == '_' || r == '-'
+}
@@ -0,0 +1,67 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/x1unix/docusearch/internal/utils/collections"
+)
+
+func TestCodeWordsFromString(t *testing.T) {
+	cases := map[string]struct {
+		input      string
+		want       []string
+		ignoreList []string
+	}{
+		"camel case": {
+			input: "func (r RedisProvider) SearchDocumentsByWord(ctx context.Context, word string)",
+			want: []string{
+				"func", "r", "redisprovider", "redis", "provider", "searchdocumentsbyword", "search", "documents",
+				"by", "word", "ctx", "context", "string",
+			},
+		},
+		"snake and kebab case": {
+			input: "max_document_size: 10, --dry-run",
+			want: []string{
+				"max_document_size", "max", "document", "size", "10", "dry-run", "dry", "run",
+			},
+		},
+		"acronyms and digits": {
+			input: "parseHTTPServer(userIDs, base64Decode, utf8)",
+			want: []string{
+				"parsehttpserver", "parse", "http", "server", "userids", "user", "ids",
+				"base64decode", "base64", "decode", "utf8",
+			},
+		},
+		"trimmed separators": {
+			input: "def __init__(self): _private - x",
+			want:  []string{"def", "init", "self", "private", "x"},
+		},
+		"ignore list": {
+			input:      "isValid",
+			want:       []string{"isvalid", "valid"},
+			ignoreList: []string{"is"},
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := CodeWordsFromString(c.input, collections.NewStringsSet(c.ignoreList...))
+			require.ElementsMatch(t, c.want, got)
+		})
+	}
+}
+
+func TestDetectAnalyzer(t *testing.T) {
+	cases := map[string]string{
+		"main.go":             AnalyzerCode,
+		"src:App.TSX":         AnalyzerCode,
+		"notes.txt":           AnalyzerText,
+		"README":              AnalyzerText,
+		"docs:guide:intro.md": AnalyzerText,
+	}
+
+	for name, want := range cases {
+		require.Equal(t, want, DetectAnalyzer(name), name)
+	}
+}
@@ -39,3 +39,11 @@ func WordsFromString(str string, ignoreList collections.StringsSet) []string {
 	}
 	return uniqueWords.ToArray()
 }
+
+// fieldSeparator separates field name and word in field term.
+const fieldSeparator = ":"
+
+// FieldTerm returns search index term of a word found in a document field, e.g. "title:report".
+func FieldTerm(field, word string) string {
+	return field + fieldSeparator + word
+}
@@ -6,33 +6,235 @@ import (
 	"strings"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/x1unix/docusearch/internal/utils/collections"
 	"go.uber.org/zap"
 )
 
 const (
 	wordKeyPrefix      = "word:"
+	labelKeyPrefix     = "label:"
 	docRecordKeyPrefix = "doc:"
+	docLabelsKeyPrefix = "doclabels:"
+
+	// Prefixes of content index keys, should match prefixes used by scripts.
+	contentWordKeyPrefix   = "contentword:"
+	contentDocsKeyPrefix   = "contentdocs:"
+	contentRecordKeyPrefix = "content:"
+	docContentKeyPrefix    = "doccontent:"
 )
 
+// unlinkContentFunc is Lua function which removes document from content documents set
+// and removes content from index when it's not referenced by documents anymore.
+const unlinkContentFunc = `
+local function unlink(docId, contentKey)
+	local docsKey = "contentdocs:" .. contentKey
+	redis.call("SREM", docsKey, docId)
+	if redis.call("SCARD", docsKey) > 0 then
+		return
+	end
+
+	local recordKey = "content:" .. contentKey
+	for _, wordKey in ipairs(redis.call("SMEMBERS", recordKey)) do
+		redis.call("SREM", wordKey, contentKey)
+	end
+	redis.call("DEL", recordKey)
+end
+`
+
+// linkContentScript links document to content and returns 1 if content words are indexed.
+//
+// Document and content are linked before check, so content can't be removed
+// from index by concurrent removal of another document after check.
+var linkContentScript = redis.NewScript(unlinkContentFunc + `
+local prev = redis.call("GET", KEYS[1])
+if prev and prev ~= ARGV[2] then
+	unlink(ARGV[1], prev)
+end
+
+redis.call("SET", KEYS[1], ARGV[2])
+redis.call("SADD", "contentdocs:" .. ARGV[2], ARGV[1])
+return redis.call("EXISTS", "content:" .. ARGV[2])
+`)
+
+// unlinkContentScript unlinks document from its content.
+var unlinkContentScript = redis.NewScript(unlinkContentFunc + `
+local prev = redis.call("GET", KEYS[1])
+if prev then
+	unlink(ARGV[1], prev)
+	redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
 // RedisProvider is redis-based search index.
 //
 // Stores word-to-document relationship as inverted index (word -> doc_ids)
 // and doc_id -> record relationships to speed-up read-write operations.
 //
-// Each Redis record is Set to guarantee that each documpanic("implement me")ent ID appears only once.
+// Document labels are stored in the same way as label -> doc_ids sets ("label:key=value")
+// and doc_id -> labels hash.
+//
+// Each Redis record is Set to guarantee that each document ID appears only once.
+//
+// Words of content indexed by ContentIndex are stored as "contentword:<word>" -> content keys sets
+// and "contentdocs:<content key>" -> doc_ids sets.
 type RedisProvider struct {
-	log  *zap.Logger
-	conn redis.Cmdable
+	log         *zap.Logger
+	conn        redis.Cmdable
+	boostFields []string
 }
 
 func NewRedisProvider(log *zap.Logger, conn redis.Cmdable) *RedisProvider {
 	return &RedisProvider{log: log, conn: conn}
 }
 
+// WithBoost enables ranking of documents which contain searched word
+// in one of specified fields higher in search results.
+//
+// Fields are listed in order of priority.
+func (r *RedisProvider) WithBoost(fields ...string) *RedisProvider {
+	r.boostFields = fields
+	return r
+}
+
 // SearchDocumentsByWord implements DocumentSearcher
 func (r RedisProvider) SearchDocumentsByWord(ctx context.Context, word string) ([]string, error) {
-	key := wordKeyPrefix + strings.ToLower(word)
-	return r.conn.SMembers(ctx, key).Result()
+	return r.termDocuments(ctx, strings.ToLower(word), nil)
+}
+
+// SearchDocuments implements DocumentSearcher
+//
+// Documents are not ranked if query is restricted to a field.
+func (r RedisProvider) SearchDocuments(ctx context.Context, q Query) (*Result, error) {
+	term := strings.ToLower(q.Word)
+	if q.Field != "" {
+		term = FieldTerm(strings.ToLower(q.Field), term)
+	}
+
+	labelKeys := make([]string, 0, len(q.Labels))
+	for k, v := range q.Labels {
+		labelKeys = append(labelKeys, labelKey(k, v))
+	}
+
+	ids, err := r.termDocuments(ctx, term, labelKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(r.boostFields) > 0 && q.Field == "" && len(ids) > 1 {
+		ids, err = r.rankDocuments(ctx, ids, q.Word, labelKeys)
+		if err != nil {
+			return nil, fmt.Errorf("failed to rank search results: %w", err)
+		}
+	}
+
+	facets, err := r.labelFacets(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get label facets: %w", err)
+	}
+
+	return &Result{IDs: ids, Facets: facets}, nil
+}
+
+// rankDocuments moves documents which contain a word in boosted fields to the beginning of results.
+//
+// Label keys are used to filter documents by labels.
+func (r RedisProvider) rankDocuments(ctx context.Context, ids []string, word string, labelKeys []string) ([]string, error) {
+	ranked := make([]string, 0, len(ids))
+	seen := make(collections.StringsSet, len(ids))
+	for _, field := range r.boostFields {
+		fieldIds, err := r.termDocuments(ctx, FieldTerm(field, strings.ToLower(word)), labelKeys)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, id := range fieldIds {
+			if !seen.Has(id) {
+				seen.Append(id)
+				ranked = append(ranked, id)
+			}
+		}
+	}
+
+	for _, id := range ids {
+		if !seen.Has(id) {
+			ranked = append(ranked, id)
+		}
+	}
+
+	return ranked, nil
+}
+
+// termDocuments returns IDs of documents which contain a term and have all labels.
+//
+// Documents referenced by indexed contents which contain a term are included.
+func (r RedisProvider) termDocuments(ctx context.Context, term string, labelKeys []string) ([]string, error) {
+	keys := append([]string{wordKeyPrefix + term}, labelKeys...)
+	ids, err := r.conn.SInter(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	contentKeys, err := r.conn.SMembers(ctx, contentWordKeyPrefix+term).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(contentKeys) == 0 {
+		return ids, nil
+	}
+
+	pipe := r.conn.Pipeline()
+	cmds := make([]*redis.StringSliceCmd, 0, len(contentKeys))
+	for _, contentKey := range contentKeys {
+		keys := append([]string{contentDocsKeyPrefix + contentKey}, labelKeys...)
+		cmds = append(cmds, pipe.SInter(ctx, keys...))
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	seen := collections.NewStringsSet(ids...)
+	for _, cmd := range cmds {
+		for _, id := range cmd.Val() {
+			if !seen.Has(id) {
+				seen.Append(id)
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	return ids, nil
+}
+
+// labelFacets returns count of documents per each label value.
+func (r RedisProvider) labelFacets(ctx context.Context, docIds []string) (map[string]map[string]int, error) {
+	facets := make(map[string]map[string]int)
+	if len(docIds) == 0 {
+		return facets, nil
+	}
+
+	pipe := r.conn.Pipeline()
+	cmds := make([]*redis.StringStringMapCmd, 0, len(docIds))
+	for _, docId := range docIds {
+		cmds = append(cmds, pipe.HGetAll(ctx, docLabelsKeyPrefix+docId))
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	for _, cmd := range cmds {
+		for k, v := range cmd.Val() {
+			if _, ok := facets[k]; !ok {
+				facets[k] = make(map[string]int)
+			}
+			facets[k][v]++
+		}
+	}
+
+	return facets, nil
 }
 
 // AddDocumentRef implements SearchProvider
@@ -52,6 +254,95 @@ func (r RedisProvider) AddDocumentRef(ctx context.Context, docId string, words [
 	return err
 }
 
+// LinkDocumentContent implements ContentIndex
+func (r RedisProvider) LinkDocumentContent(ctx context.Context, docId, contentKey string) (bool, error) {
+	indexed, err := linkContentScript.Run(ctx, r.conn, []string{docContentKeyPrefix + docId}, docId, contentKey).Int()
+	if err != nil {
+		return false, err
+	}
+
+	return indexed == 1, nil
+}
+
+// AddContentRef implements ContentIndex
+func (r RedisProvider) AddContentRef(ctx context.Context, contentKey string, words []string) error {
+	tx := r.conn.TxPipeline()
+	for _, word := range words {
+		wordKey := contentWordKeyPrefix + word
+		tx.SAdd(ctx, wordKey, contentKey)
+		tx.SAdd(ctx, contentRecordKeyPrefix+contentKey, wordKey)
+	}
+
+	_, err := tx.Exec(ctx)
+	return err
+}
+
+// UpdateDocumentRef implements SearchProvider
+func (r RedisProvider) UpdateDocumentRef(ctx context.Context, docId string, words []string) error {
+	docIndexKey := docRecordKeyPrefix + docId
+	oldKeys, err := r.conn.LRange(ctx, docIndexKey, 0, -1).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get list of document references: %w", err)
+	}
+
+	newKeys := make(collections.StringsSet, len(words))
+	for _, word := range words {
+		newKeys.Append(wordKeyPrefix + word)
+	}
+
+	oldKeysSet := collections.NewStringsSet(oldKeys...)
+	addedKeys := newKeys.Difference(oldKeysSet)
+	removedKeys := oldKeysSet.Difference(newKeys)
+	if len(addedKeys) == 0 && len(removedKeys) == 0 {
+		return nil
+	}
+
+	tx := r.conn.TxPipeline()
+	for _, key := range removedKeys {
+		tx.SRem(ctx, key, docId)
+		tx.LRem(ctx, docIndexKey, 0, key)
+	}
+
+	for _, key := range addedKeys {
+		tx.SAdd(ctx, key, docId)
+		tx.RPush(ctx, docIndexKey, key)
+	}
+
+	_, err = tx.Exec(ctx)
+	return err
+}
+
+// UpdateDocumentLabels implements SearchProvider
+func (r RedisProvider) UpdateDocumentLabels(ctx context.Context, docId string, labels map[string]string) error {
+	docLabelsKey := docLabelsKeyPrefix + docId
+	oldLabels, err := r.conn.HGetAll(ctx, docLabelsKey).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get list of document labels: %w", err)
+	}
+
+	tx := r.conn.TxPipeline()
+	for k, v := range oldLabels {
+		if newVal, ok := labels[k]; ok && newVal == v {
+			continue
+		}
+
+		tx.SRem(ctx, labelKey(k, v), docId)
+		tx.HDel(ctx, docLabelsKey, k)
+	}
+
+	for k, v := range labels {
+		if oldVal, ok := oldLabels[k]; ok && oldVal == v {
+			continue
+		}
+
+		tx.SAdd(ctx, labelKey(k, v), docId)
+		tx.HSet(ctx, docLabelsKey, k, v)
+	}
+
+	_, err = tx.Exec(ctx)
+	return err
+}
+
 // RemoveDocumentRef implements SearchProvider
 func (r RedisProvider) RemoveDocumentRef(ctx context.Context, docId string) error {
 	docIndexKey := docRecordKeyPrefix + docId
@@ -61,12 +352,32 @@ func (r RedisProvider) RemoveDocumentRef(ctx context.Context, docId string) erro
 		return fmt.Errorf("failed to get list of document references: %w", err)
 	}
 
+	docLabelsKey := docLabelsKeyPrefix + docId
+	labels, err := r.conn.HGetAll(ctx, docLabelsKey).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get list of document labels: %w", err)
+	}
+
 	tx := r.conn.TxPipeline()
 	for _, key := range wordKeys {
 		tx.SRem(ctx, key, docId)
 	}
 
-	tx.Del(ctx, docIndexKey)
-	_, err = tx.Exec(ctx)
-	return err
+	for k, v := range labels {
+		tx.SRem(ctx, labelKey(k, v), docId)
+	}
+
+	tx.Del(ctx, docIndexKey, docLabelsKey)
+	if _, err := tx.Exec(ctx); err != nil {
+		return err
+	}
+
+	return unlinkContentScript.Run(ctx, r.conn, []string{docContentKeyPrefix + docId}, docId).Err()
+}
+
+// labelKey returns key of label documents set.
+//
+// Label keys can't contain "=", so key and value are separated unambiguously.
+func labelKey(key, value string) string {
+	return labelKeyPrefix + key + "=" + value
 }
@@ -0,0 +1,112 @@
+package search_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/require"
+	"github.com/x1unix/docusearch/internal/services/search"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestRedisProvider_WithBoost(t *testing.T) {
+	srv := miniredis.RunT(t)
+	conn := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	defer conn.Close()
+
+	ctx := context.TODO()
+	docs := map[string][]string{
+		"body.html":    {"report"},
+		"heading.html": {"report", search.FieldTerm("heading", "report")},
+		"title.html":   {"report", search.FieldTerm("title", "report"), search.FieldTerm("heading", "report")},
+		"other.html":   {"other", search.FieldTerm("title", "other")},
+	}
+
+	provider := search.NewRedisProvider(zaptest.NewLogger(t), conn)
+	for id, words := range docs {
+		require.NoError(t, provider.AddDocumentRef(ctx, id, words))
+	}
+	require.NoError(t, provider.UpdateDocumentLabels(ctx, "title.html", map[string]string{"lang": "en"}))
+	require.NoError(t, provider.UpdateDocumentLabels(ctx, "body.html", map[string]string{"lang": "en"}))
+
+	provider.WithBoost("title", "heading")
+	result, err := provider.SearchDocuments(ctx, search.Query{Word: "Report"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"title.html", "heading.html", "body.html"}, result.IDs)
+
+	result, err = provider.SearchDocuments(ctx, search.Query{Word: "report", Labels: map[string]string{"lang": "en"}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"title.html", "body.html"}, result.IDs)
+
+	result, err = provider.SearchDocuments(ctx, search.Query{Word: "Report", Field: "Heading"})
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"title.html", "heading.html"}, result.IDs)
+}
+
+func TestRedisProvider_ContentIndex(t *testing.T) {
+	srv := miniredis.RunT(t)
+	conn := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	defer conn.Close()
+
+	ctx := context.TODO()
+	provider := search.NewRedisProvider(zaptest.NewLogger(t), conn)
+	linkContent := func(t *testing.T, docId, contentKey string, words ...string) {
+		t.Helper()
+		indexed, err := provider.LinkDocumentContent(ctx, docId, contentKey)
+		require.NoError(t, err)
+		if !indexed {
+			require.NoError(t, provider.AddContentRef(ctx, contentKey, words))
+		}
+	}
+	assertFound := func(t *testing.T, q search.Query, want ...string) {
+		t.Helper()
+		result, err := provider.SearchDocuments(ctx, q)
+		require.NoError(t, err)
+		require.ElementsMatch(t, want, result.IDs)
+	}
+
+	// Content words are indexed once
+	indexed, err := provider.LinkDocumentContent(ctx, "a.txt", "foo")
+	require.NoError(t, err)
+	require.False(t, indexed)
+	require.NoError(t, provider.AddContentRef(ctx, "foo", []string{"report", search.FieldTerm("title", "report")}))
+	indexed, err = provider.LinkDocumentContent(ctx, "b.txt", "foo")
+	require.NoError(t, err)
+	require.True(t, indexed)
+
+	require.NoError(t, provider.AddDocumentRef(ctx, "c.txt", []string{"report"}))
+	require.NoError(t, provider.UpdateDocumentLabels(ctx, "b.txt", map[string]string{"lang": "en"}))
+	assertFound(t, search.Query{Word: "report"}, "a.txt", "b.txt", "c.txt")
+	assertFound(t, search.Query{Word: "report", Field: "title"}, "a.txt", "b.txt")
+	assertFound(t, search.Query{Word: "report", Labels: map[string]string{"lang": "en"}}, "b.txt")
+
+	ids, err := provider.SearchDocumentsByWord(ctx, "report")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"a.txt", "b.txt", "c.txt"}, ids)
+
+	provider.WithBoost("title")
+	result, err := provider.SearchDocuments(ctx, search.Query{Word: "report"})
+	require.NoError(t, err)
+	require.Equal(t, "c.txt", result.IDs[2])
+
+	// Content is removed from index with the last document
+	linkContent(t, "a.txt", "bar", "summary")
+	assertFound(t, search.Query{Word: "summary"}, "a.txt")
+	assertFound(t, search.Query{Word: "report", Field: "title"}, "b.txt")
+
+	require.NoError(t, provider.RemoveDocumentRef(ctx, "b.txt"))
+	assertFound(t, search.Query{Word: "report"}, "c.txt")
+	require.False(t, srv.Exists("content:foo"), "unreferenced content should be removed")
+
+	linkContent(t, "b.txt", "foo", "report")
+	assertFound(t, search.Query{Word: "report"}, "b.txt", "c.txt")
+
+	require.NoError(t, provider.RemoveDocumentRef(ctx, "a.txt"))
+	require.NoError(t, provider.RemoveDocumentRef(ctx, "b.txt"))
+	assertFound(t, search.Query{Word: "summary"})
+	for _, key := range srv.Keys() {
+		require.NotContains(t, key, "content", "content index keys should be removed")
+	}
+}
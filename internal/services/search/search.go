@@ -1,12 +1,57 @@
 package search
 
-import "context"
+import (
+	"context"
+	"strings"
+)
+
+// Query is document search query.
+type Query struct {
+	// Word is a word which document should contain.
+	Word string
+
+	// Field restricts search to a word found in specified document field, e.g. "title".
+	//
+	// Word is searched in all document text if field is empty.
+	Field string
+
+	// Labels is list of labels which document should have.
+	Labels map[string]string
+}
+
+// ParseTerm splits "field:word" search term into field name and word.
+//
+// Field name is empty for unqualified term.
+func ParseTerm(term string) (field, word string) {
+	// Words never contain separator, while field paths might.
+	i := strings.LastIndex(term, fieldSeparator)
+	if i == -1 {
+		return "", term
+	}
+
+	return term[:i], term[i+len(fieldSeparator):]
+}
+
+// Result is document search result.
+type Result struct {
+	// IDs is list of found document IDs.
+	IDs []string
+
+	// Facets contains number of found documents per each label value.
+	//
+	// Map key is label name and value is map of label value and documents count.
+	Facets map[string]map[string]int
+}
 
 // DocumentSearcher is abstract document search implementation.
 type DocumentSearcher interface {
 	// SearchDocumentsByWord returns list of document IDs
 	// that contain specified word.
 	SearchDocumentsByWord(ctx context.Context, word string) ([]string, error)
+
+	// SearchDocuments returns list of documents that contain specified word
+	// and have all labels passed in query.
+	SearchDocuments(ctx context.Context, q Query) (*Result, error)
 }
 
 // Provider is abstract document search provider.
@@ -16,6 +61,28 @@ type Provider interface {
 	// AddDocumentRef adds references of specified words to document in search index.
 	AddDocumentRef(ctx context.Context, docId string, words []string) error
 
+	// UpdateDocumentRef replaces list of words referenced by document in search index.
+	//
+	// Only difference between previous and new list of words is applied.
+	UpdateDocumentRef(ctx context.Context, docId string, words []string) error
+
+	// UpdateDocumentLabels replaces list of document labels in search index.
+	UpdateDocumentLabels(ctx context.Context, docId string, labels map[string]string) error
+
 	// RemoveDocumentRef removes any references to document from index.
 	RemoveDocumentRef(ctx context.Context, docId string) error
 }
+
+// ContentIndex is implemented by search providers which index words of identical document contents once.
+//
+// Words are referenced by content key, which is mapped to IDs of documents with the same contents on search.
+// Document is unlinked from its content by Provider.RemoveDocumentRef.
+type ContentIndex interface {
+	// LinkDocumentContent links document to content, replacing previous document content.
+	//
+	// Returns true if content words are already indexed.
+	LinkDocumentContent(ctx context.Context, docId, contentKey string) (bool, error)
+
+	// AddContentRef adds references of specified words to content in search index.
+	AddContentRef(ctx context.Context, contentKey string, words []string) error
+}
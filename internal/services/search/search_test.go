@@ -0,0 +1,27 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTerm(t *testing.T) {
+	cases := map[string]struct {
+		field string
+		word  string
+	}{
+		"gregor":            {word: "gregor"},
+		"title:gregor":      {field: "title", word: "gregor"},
+		"author.name:kafka": {field: "author.name", word: "kafka"},
+		"urn:isbn:978":      {field: "urn:isbn", word: "978"},
+		"title:":            {field: "title"},
+		":gregor":           {word: "gregor"},
+	}
+
+	for term, want := range cases {
+		field, word := ParseTerm(term)
+		require.Equal(t, want.field, field, term)
+		require.Equal(t, want.word, word, term)
+	}
+}
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	blobRefsKey   = "dedup:refs"
+	blobCountsKey = "dedup:blobs"
+)
+
+// decrScript decrements hash field and removes field when it reaches zero.
+var decrScript = redis.NewScript(`
+local n = redis.call("HINCRBY", KEYS[1], ARGV[1], -1)
+if n <= 0 then
+	redis.call("HDEL", KEYS[1], ARGV[1])
+end
+return n
+`)
+
+// RedisBlobRefStore is Redis-based blob references storage.
+//
+// Document references are stored as JSON values in "dedup:refs" hash
+// and blob reference counters are stored in "dedup:blobs" hash.
+type RedisBlobRefStore struct {
+	conn redis.Cmdable
+}
+
+func NewRedisBlobRefStore(conn redis.Cmdable) *RedisBlobRefStore {
+	return &RedisBlobRefStore{conn: conn}
+}
+
+// GetRef implements BlobRefStore
+func (r RedisBlobRefStore) GetRef(ctx context.Context, name string) (*BlobRef, error) {
+	data, err := r.conn.HGet(ctx, blobRefsKey, name).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, fs.ErrNotExist
+		}
+
+		return nil, err
+	}
+
+	ref := new(BlobRef)
+	if err := json.Unmarshal(data, ref); err != nil {
+		return nil, fmt.Errorf("failed to decode blob reference of %q: %w", name, err)
+	}
+
+	return ref, nil
+}
+
+// SetRef implements BlobRefStore
+func (r RedisBlobRefStore) SetRef(ctx context.Context, name string, ref BlobRef) error {
+	data, err := json.Marshal(ref)
+	if err != nil {
+		return err
+	}
+
+	return r.conn.HSet(ctx, blobRefsKey, name, data).Err()
+}
+
+// RemoveRef implements BlobRefStore
+func (r RedisBlobRefStore) RemoveRef(ctx context.Context, name string) error {
+	return r.conn.HDel(ctx, blobRefsKey, name).Err()
+}
+
+// ListRefs implements BlobRefStore
+func (r RedisBlobRefStore) ListRefs(ctx context.Context) (map[string]BlobRef, error) {
+	values, err := r.conn.HGetAll(ctx, blobRefsKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make(map[string]BlobRef, len(values))
+	for name, data := range values {
+		var ref BlobRef
+		if err := json.Unmarshal([]byte(data), &ref); err != nil {
+			return nil, fmt.Errorf("failed to decode blob reference of %q: %w", name, err)
+		}
+
+		refs[name] = ref
+	}
+
+	return refs, nil
+}
+
+// IncrBlobRefs implements BlobRefStore
+func (r RedisBlobRefStore) IncrBlobRefs(ctx context.Context, hash string) (int64, error) {
+	return r.conn.HIncrBy(ctx, blobCountsKey, hash, 1).Result()
+}
+
+// DecrBlobRefs implements BlobRefStore
+func (r RedisBlobRefStore) DecrBlobRefs(ctx context.Context, hash string) (int64, error) {
+	return decrScript.Run(ctx, r.conn, []string{blobCountsKey}, hash).Int64()
+}
@@ -0,0 +1,131 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultExpiryCheckInterval is default interval between expired documents removal.
+const DefaultExpiryCheckInterval = time.Minute
+
+// expiryBatchSize is max number of expired documents removed per batch.
+const expiryBatchSize = 100
+
+// RemoveExpired removes all documents which expiration time has passed.
+//
+// Returns number of removed documents.
+func (s SyncedDocumentStore) RemoveExpired(ctx context.Context) (int, error) {
+	var removed int
+	for {
+		names, err := s.metaStore.ListExpired(ctx, time.Now(), expiryBatchSize)
+		if err != nil {
+			return removed, fmt.Errorf("failed to list expired documents: %w", err)
+		}
+
+		batchRemoved := 0
+		for _, name := range names {
+			ok, err := s.removeIfExpired(ctx, name)
+			if err != nil {
+				// Don't let a single broken document block removal of others.
+				s.log.Error("failed to remove expired document", zap.String("name", name), zap.Error(err))
+				continue
+			}
+
+			if ok {
+				batchRemoved++
+			}
+		}
+
+		removed += batchRemoved
+		if len(names) < expiryBatchSize || batchRemoved == 0 {
+			// Stop if there is nothing left or remaining documents are not expired yet.
+			return removed, nil
+		}
+	}
+}
+
+// RunExpiryReaper periodically removes expired documents until context is cancelled.
+func (s SyncedDocumentStore) RunExpiryReaper(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultExpiryCheckInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			removed, err := s.RemoveExpired(ctx)
+			if err != nil {
+				s.log.Error("failed to remove expired documents", zap.Error(err))
+			}
+
+			if removed > 0 {
+				s.log.Info("removed expired documents", zap.Int("count", removed))
+			}
+		}
+	}
+}
+
+// removeIfExpired removes a document if it's still expired after acquiring document lock.
+func (s SyncedDocumentStore) removeIfExpired(ctx context.Context, name string) (bool, error) {
+	unlock, err := s.lockDocument(ctx, name)
+	if err != nil {
+		return false, err
+	}
+
+	defer unlock()
+
+	// Document might be removed or replaced after listing.
+	meta, err := s.metaStore.GetMetadata(ctx, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	if !meta.IsExpired(time.Now()) {
+		return false, nil
+	}
+
+	if err := s.removeExpired(ctx, name); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// removeExpired removes expired document using the same flow as RemoveDocument.
+//
+// Expired documents are removed permanently without moving to trash.
+// Caller should hold document lock.
+func (s SyncedDocumentStore) removeExpired(ctx context.Context, name string) error {
+	err := s.removeDocument(ctx, name, false)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	// Document contents are already missing, drop stale index and metadata records.
+	if meta, err := s.metaStore.GetMetadata(ctx, name); err == nil {
+		s.updateUsage(ctx, name, Usage{Documents: -1, Bytes: -meta.Size})
+	}
+
+	if err := s.searchProvider.RemoveDocumentRef(ctx, name); err != nil {
+		return fmt.Errorf("failed to remove document from search index: %w", err)
+	}
+
+	if err := s.metaStore.RemoveMetadata(ctx, name); err != nil {
+		return fmt.Errorf("failed to remove document metadata: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package store
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// maxKeyIDLength is max length of encryption key ID stored in document header.
+const maxKeyIDLength = 255
+
+// documentKeyInfo is HKDF context information of per-document keys.
+var documentKeyInfo = []byte("docusearch document key")
+
+// KeyRing is a set of document encryption keys.
+//
+// The first added key is the active key used to encrypt documents,
+// other keys are used only to decrypt documents encrypted before key rotation.
+//
+// Documents are not encrypted by keys directly, each document is encrypted
+// by a separate key derived from a key ring key using HKDF.
+type KeyRing struct {
+	activeKey string
+	keys      map[string][]byte
+}
+
+// NewKeyRing returns an empty key ring.
+func NewKeyRing() *KeyRing {
+	return &KeyRing{keys: make(map[string][]byte)}
+}
+
+// ParseKeyRing constructs a key ring from list of keys in "<id>:<base64 key>" format.
+func ParseKeyRing(keys []string) (*KeyRing, error) {
+	ring := NewKeyRing()
+	for i, str := range keys {
+		if err := ring.parseKey(str); err != nil {
+			return nil, fmt.Errorf("invalid encryption key #%d: %w", i+1, err)
+		}
+	}
+
+	return ring, nil
+}
+
+// AddKey adds AES-128, AES-192 or AES-256 key to key ring.
+func (k *KeyRing) AddKey(id string, key []byte) error {
+	if id == "" || len(id) > maxKeyIDLength {
+		return fmt.Errorf("key ID should be from 1 to %d bytes long", maxKeyIDLength)
+	}
+
+	if _, ok := k.keys[id]; ok {
+		return fmt.Errorf("duplicate key ID %q", id)
+	}
+
+	// Key is checked as derived key has the same size.
+	if _, err := aes.NewCipher(key); err != nil {
+		return err
+	}
+
+	k.keys[id] = append([]byte(nil), key...)
+	if k.activeKey == "" {
+		k.activeKey = id
+	}
+
+	return nil
+}
+
+// ReadKeys adds keys from reader to key ring.
+//
+// Reader should contain one key per line in "<id>:<base64 key>" format.
+// Empty lines and lines starting with "#" are ignored.
+func (k *KeyRing) ReadKeys(r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		str := strings.TrimSpace(scanner.Text())
+		if str == "" || strings.HasPrefix(str, "#") {
+			continue
+		}
+
+		if err := k.parseKey(str); err != nil {
+			return fmt.Errorf("invalid encryption key at line %d: %w", line, err)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// ReadKeyFile adds keys from key file to key ring.
+//
+// See ReadKeys for file format.
+func (k *KeyRing) ReadKeyFile(fileName string) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+	if err := k.ReadKeys(f); err != nil {
+		return fmt.Errorf("failed to read key file %q: %w", fileName, err)
+	}
+
+	return nil
+}
+
+// ActiveKey returns ID of key used to encrypt documents.
+func (k KeyRing) ActiveKey() string {
+	return k.activeKey
+}
+
+// IsEmpty returns true if key ring has no keys.
+func (k KeyRing) IsEmpty() bool {
+	return len(k.keys) == 0
+}
+
+// documentCipher returns AES-GCM cipher of a document key derived from key ring key and document salt.
+func (k KeyRing) documentCipher(keyID string, salt []byte) (cipher.AEAD, error) {
+	key, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("document is encrypted with unknown key %q", keyID)
+	}
+
+	docKey := make([]byte, len(key))
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, salt, documentKeyInfo), docKey); err != nil {
+		return nil, fmt.Errorf("failed to derive document key: %w", err)
+	}
+
+	block, err := aes.NewCipher(docKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func (k *KeyRing) parseKey(str string) error {
+	chunks := strings.SplitN(str, ":", 2)
+	if len(chunks) != 2 {
+		return fmt.Errorf("key should be in \"<id>:<base64 key>\" format")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(chunks[1]))
+	if err != nil {
+		return fmt.Errorf("malformed key value: %w", err)
+	}
+
+	return k.AddKey(strings.TrimSpace(chunks[0]), key)
+}
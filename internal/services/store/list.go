@@ -0,0 +1,215 @@
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrInvalidCursor is returned when list cursor is malformed.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// SortField is document list sort field.
+type SortField string
+
+const (
+	// SortByName sorts documents by name.
+	SortByName SortField = "name"
+
+	// SortByTime sorts documents by upload time.
+	SortByTime SortField = "time"
+
+	// SortBySize sorts documents by size.
+	SortBySize SortField = "size"
+)
+
+// ParseSortField parses sort field name.
+//
+// Empty string is treated as SortByName.
+func ParseSortField(str string) (SortField, error) {
+	switch f := SortField(str); f {
+	case "":
+		return SortByName, nil
+	case SortByName, SortByTime, SortBySize:
+		return f, nil
+	default:
+		return "", fmt.Errorf("unsupported sort field %q", str)
+	}
+}
+
+// ListOptions is documents list query.
+type ListOptions struct {
+	// Prefix filters documents by name prefix.
+	Prefix string
+
+	// SortBy is sort field.
+	SortBy SortField
+
+	// Desc toggles descending sort order.
+	Desc bool
+
+	// Cursor is opaque cursor returned in previous page.
+	//
+	// Empty value means first page.
+	Cursor string
+
+	// Limit is max number of items per page.
+	//
+	// Zero value means no limit.
+	Limit int
+}
+
+// DocumentInfo contains basic stored document information.
+type DocumentInfo struct {
+	// Name is document name.
+	Name string
+
+	// Size is document size in bytes.
+	Size int64
+
+	// UploadedAt is document upload time.
+	UploadedAt time.Time
+}
+
+// ListResult is documents list page.
+type ListResult struct {
+	// Items is list of documents on page.
+	Items []DocumentInfo
+
+	// NextCursor is cursor of next page.
+	//
+	// Empty if there are no more items.
+	NextCursor string
+}
+
+// listCursor is last item position of previous page.
+type listCursor struct {
+	Name       string `json:"n"`
+	Size       int64  `json:"s"`
+	UploadedAt int64  `json:"t"`
+}
+
+func encodeCursor(info DocumentInfo) string {
+	data, _ := json.Marshal(listCursor{
+		Name:       info.Name,
+		Size:       info.Size,
+		UploadedAt: info.UploadedAt.UnixNano(),
+	})
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(cursor string) (*DocumentInfo, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	c := new(listCursor)
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &DocumentInfo{
+		Name:       c.Name,
+		Size:       c.Size,
+		UploadedAt: time.Unix(0, c.UploadedAt),
+	}, nil
+}
+
+// compareDocuments compares two documents by sort field.
+//
+// Name is used as a tie-breaker to keep order stable.
+func compareDocuments(a, b DocumentInfo, sortBy SortField) int {
+	switch sortBy {
+	case SortBySize:
+		if a.Size != b.Size {
+			if a.Size < b.Size {
+				return -1
+			}
+			return 1
+		}
+	case SortByTime:
+		if !a.UploadedAt.Equal(b.UploadedAt) {
+			if a.UploadedAt.Before(b.UploadedAt) {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return strings.Compare(a.Name, b.Name)
+}
+
+// paginateDocuments sorts and filters list of documents and returns a requested page.
+//
+// Used by storage implementations which don't support sorted listing natively.
+func paginateDocuments(items []DocumentInfo, opts ListOptions) (*ListResult, error) {
+	less := func(a, b DocumentInfo) bool {
+		if opts.Desc {
+			return compareDocuments(a, b, opts.SortBy) > 0
+		}
+		return compareDocuments(a, b, opts.SortBy) < 0
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return less(items[i], items[j])
+	})
+
+	if opts.Cursor != "" {
+		last, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			return nil, err
+		}
+
+		offset := sort.Search(len(items), func(i int) bool {
+			return less(*last, items[i])
+		})
+		items = items[offset:]
+	}
+
+	result := &ListResult{Items: items}
+	if opts.Limit > 0 && len(items) > opts.Limit {
+		result.Items = items[:opts.Limit]
+		result.NextCursor = encodeCursor(result.Items[opts.Limit-1])
+	}
+
+	return result, nil
+}
+
+// listDocumentSizes lists documents of underlying store and replaces item sizes
+// using size function, which is used by stores which keep documents encoded.
+//
+// Documents should be sorted by resolved sizes, so underlying store listing
+// is not paginated when documents are sorted by size.
+// Item size is kept if size function returns error, as document might be removed during listing.
+func listDocumentSizes(ctx context.Context, store DocumentStore, opts ListOptions,
+	size func(item DocumentInfo) (int64, error)) (*ListResult, error) {
+	storeOpts := opts
+	if opts.SortBy == SortBySize {
+		storeOpts.Cursor = ""
+		storeOpts.Limit = 0
+	}
+
+	result, err := store.List(ctx, storeOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, item := range result.Items {
+		if v, err := size(item); err == nil {
+			result.Items[i].Size = v
+		}
+	}
+
+	if opts.SortBy != SortBySize {
+		return result, nil
+	}
+
+	return paginateDocuments(result.Items, opts)
+}
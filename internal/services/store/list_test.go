@@ -0,0 +1,98 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSortField(t *testing.T) {
+	cases := map[string]struct {
+		want    SortField
+		wantErr string
+	}{
+		"":      {want: SortByName},
+		"name":  {want: SortByName},
+		"time":  {want: SortByTime},
+		"size":  {want: SortBySize},
+		"color": {wantErr: `unsupported sort field "color"`},
+	}
+
+	for input, c := range cases {
+		t.Run(input, func(t *testing.T) {
+			got, err := ParseSortField(input)
+			if c.wantErr != "" {
+				require.EqualError(t, err, c.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, c.want, got)
+		})
+	}
+}
+
+func TestPaginateDocuments(t *testing.T) {
+	now := time.Now()
+	items := []DocumentInfo{
+		{Name: "foo", Size: 30, UploadedAt: now.Add(2 * time.Second)},
+		{Name: "bar", Size: 10, UploadedAt: now},
+		{Name: "baz", Size: 20, UploadedAt: now.Add(time.Second)},
+		{Name: "qux", Size: 20, UploadedAt: now.Add(3 * time.Second)},
+	}
+
+	cases := map[string]struct {
+		opts ListOptions
+		want [][]string
+	}{
+		"all by name": {
+			opts: ListOptions{SortBy: SortByName},
+			want: [][]string{{"bar", "baz", "foo", "qux"}},
+		},
+		"pages by name": {
+			opts: ListOptions{SortBy: SortByName, Limit: 3},
+			want: [][]string{{"bar", "baz", "foo"}, {"qux"}},
+		},
+		"pages by time desc": {
+			opts: ListOptions{SortBy: SortByTime, Desc: true, Limit: 2},
+			want: [][]string{{"qux", "foo"}, {"baz", "bar"}},
+		},
+		"pages by size with equal values": {
+			opts: ListOptions{SortBy: SortBySize, Limit: 1},
+			want: [][]string{{"bar"}, {"baz"}, {"qux"}, {"foo"}},
+		},
+	}
+
+	for n, c := range cases {
+		t.Run(n, func(t *testing.T) {
+			opts := c.opts
+			for i, wantPage := range c.want {
+				input := make([]DocumentInfo, len(items))
+				copy(input, items)
+
+				got, err := paginateDocuments(input, opts)
+				require.NoError(t, err)
+
+				names := make([]string, 0, len(got.Items))
+				for _, item := range got.Items {
+					names = append(names, item.Name)
+				}
+				require.Equalf(t, wantPage, names, "page %d mismatch", i)
+
+				if i == len(c.want)-1 {
+					require.Empty(t, got.NextCursor, "last page should not have cursor")
+					return
+				}
+
+				require.NotEmpty(t, got.NextCursor)
+				opts.Cursor = got.NextCursor
+			}
+		})
+	}
+}
+
+func TestPaginateDocuments_InvalidCursor(t *testing.T) {
+	_, err := paginateDocuments(nil, ListOptions{Cursor: "%%%"})
+	require.ErrorIs(t, err, ErrInvalidCursor)
+}
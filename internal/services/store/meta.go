@@ -0,0 +1,92 @@
+package store
+
+import (
+	"context"
+	"time"
+)
+
+// Metadata is stored document metadata.
+type Metadata struct {
+	// Size is document size in bytes.
+	Size int64 `json:"size"`
+
+	// SHA256 is hex-encoded SHA-256 checksum of document contents.
+	SHA256 string `json:"sha256"`
+
+	// ContentType is document MIME type.
+	ContentType string `json:"content_type"`
+
+	// Charset is detected charset of text document.
+	//
+	// Document is transcoded from charset to UTF-8 before indexing.
+	Charset string `json:"charset,omitempty"`
+
+	// Analyzer is name of search analyzer used to index document text.
+	//
+	// Empty value means search.AnalyzerText, which is stored only if it was set on upload.
+	Analyzer string `json:"analyzer,omitempty"`
+
+	// CreatedAt is document creation time.
+	CreatedAt time.Time `json:"created_at"`
+
+	// UpdatedAt is last document update time.
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// Labels is list of user-defined key-value labels.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// DeletedAt is time when document was moved to trash.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+
+	// ExpiresAt is time after which document is removed.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// IsExpired reports whether document expiration time has passed.
+func (m Metadata) IsExpired(now time.Time) bool {
+	return m.ExpiresAt != nil && !now.Before(*m.ExpiresAt)
+}
+
+// MetadataStore is documents metadata storage.
+type MetadataStore interface {
+	// GetMetadata returns document metadata.
+	//
+	// Should return fs.ErrNotExist if metadata doesn't exist.
+	GetMetadata(ctx context.Context, name string) (*Metadata, error)
+
+	// SaveMetadata creates or replaces document metadata.
+	SaveMetadata(ctx context.Context, name string, meta *Metadata) error
+
+	// RemoveMetadata removes document metadata.
+	RemoveMetadata(ctx context.Context, name string) error
+
+	// ListExpired returns names of up to limit documents which expired before specified time.
+	ListExpired(ctx context.Context, before time.Time, limit int) ([]string, error)
+}
+
+// WriteOptions contains additional document parameters passed on upload.
+type WriteOptions struct {
+	// ContentType is document MIME type.
+	//
+	// Detected from document contents if empty.
+	ContentType string
+
+	// Labels is list of document labels.
+	//
+	// On replace, previous labels are preserved if value is nil.
+	Labels map[string]string
+
+	// Precondition is write precondition.
+	Precondition Precondition
+
+	// ExpiresAt is document expiration time.
+	//
+	// On replace, previous expiration time is preserved if value is nil.
+	ExpiresAt *time.Time
+
+	// Analyzer is name of search analyzer used to index document text.
+	//
+	// Detected from document name if empty.
+	// On replace, previous analyzer is preserved if value is empty.
+	Analyzer string
+}
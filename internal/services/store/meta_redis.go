@@ -0,0 +1,108 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	metaKeyPrefix      = "meta:"
+	trashMetaKeyPrefix = "trash:meta:"
+	expiryKey          = "expiry"
+)
+
+// RedisMetadataStore is Redis-based documents metadata storage.
+//
+// Metadata is stored as JSON string under "meta:<name>" key.
+// Names of expiring documents are kept in "expiry" sorted set scored by expiration time.
+type RedisMetadataStore struct {
+	conn      redis.Cmdable
+	keyPrefix string
+	expiryKey string
+}
+
+func NewRedisMetadataStore(conn redis.Cmdable) *RedisMetadataStore {
+	return &RedisMetadataStore{conn: conn, keyPrefix: metaKeyPrefix, expiryKey: expiryKey}
+}
+
+// NewRedisTrashMetadataStore returns metadata storage for removed documents.
+//
+// Metadata is stored under "trash:meta:<name>" key, document expiration is not tracked.
+func NewRedisTrashMetadataStore(conn redis.Cmdable) *RedisMetadataStore {
+	return &RedisMetadataStore{conn: conn, keyPrefix: trashMetaKeyPrefix}
+}
+
+// GetMetadata implements MetadataStore
+func (r RedisMetadataStore) GetMetadata(ctx context.Context, name string) (*Metadata, error) {
+	data, err := r.conn.Get(ctx, r.keyPrefix+name).Bytes()
+	if err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil, fs.ErrNotExist
+		}
+
+		return nil, err
+	}
+
+	meta := new(Metadata)
+	if err := json.Unmarshal(data, meta); err != nil {
+		return nil, fmt.Errorf("failed to decode metadata of %q: %w", name, err)
+	}
+
+	return meta, nil
+}
+
+// SaveMetadata implements MetadataStore
+func (r RedisMetadataStore) SaveMetadata(ctx context.Context, name string, meta *Metadata) error {
+	data, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	if r.expiryKey == "" {
+		return r.conn.Set(ctx, r.keyPrefix+name, data, 0).Err()
+	}
+
+	tx := r.conn.TxPipeline()
+	tx.Set(ctx, r.keyPrefix+name, data, 0)
+	if meta.ExpiresAt == nil {
+		tx.ZRem(ctx, r.expiryKey, name)
+	} else {
+		tx.ZAdd(ctx, r.expiryKey, &redis.Z{Score: float64(meta.ExpiresAt.Unix()), Member: name})
+	}
+
+	_, err = tx.Exec(ctx)
+	return err
+}
+
+// RemoveMetadata implements MetadataStore
+func (r RedisMetadataStore) RemoveMetadata(ctx context.Context, name string) error {
+	if r.expiryKey == "" {
+		return r.conn.Del(ctx, r.keyPrefix+name).Err()
+	}
+
+	tx := r.conn.TxPipeline()
+	tx.Del(ctx, r.keyPrefix+name)
+	tx.ZRem(ctx, r.expiryKey, name)
+	_, err := tx.Exec(ctx)
+	return err
+}
+
+// ListExpired implements MetadataStore
+func (r RedisMetadataStore) ListExpired(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	if r.expiryKey == "" {
+		return nil, nil
+	}
+
+	return r.conn.ZRangeByScore(ctx, r.expiryKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(before.Unix(), 10),
+		Count: int64(limit),
+	}).Result()
+}
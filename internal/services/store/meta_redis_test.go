@@ -0,0 +1,53 @@
+package store
+
+import (
+	"context"
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisMetadataStore(t *testing.T) {
+	srv := miniredis.RunT(t)
+	conn := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	defer conn.Close()
+
+	ctx := context.TODO()
+	s := NewRedisMetadataStore(conn)
+	_, err := s.GetMetadata(ctx, "doc")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	now := time.Now()
+	expired := now.Add(-time.Minute)
+	notExpired := now.Add(time.Hour)
+	want := &Metadata{Size: 3, SHA256: "foo", ExpiresAt: &expired}
+	require.NoError(t, s.SaveMetadata(ctx, "doc", want))
+	require.NoError(t, s.SaveMetadata(ctx, "alive", &Metadata{ExpiresAt: &notExpired}))
+	require.NoError(t, s.SaveMetadata(ctx, "permanent", &Metadata{}))
+
+	got, err := s.GetMetadata(ctx, "doc")
+	require.NoError(t, err)
+	require.Equal(t, want.SHA256, got.SHA256)
+	require.True(t, got.IsExpired(now))
+
+	names, err := s.ListExpired(ctx, now, 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"doc"}, names)
+
+	// Expiration should be reset when metadata is saved without it
+	require.NoError(t, s.SaveMetadata(ctx, "doc", &Metadata{}))
+	names, err = s.ListExpired(ctx, now, 10)
+	require.NoError(t, err)
+	require.Empty(t, names)
+
+	require.NoError(t, s.RemoveMetadata(ctx, "alive"))
+	names, err = s.ListExpired(ctx, notExpired, 10)
+	require.NoError(t, err)
+	require.Empty(t, names)
+	_, err = s.GetMetadata(ctx, "alive")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
@@ -0,0 +1,95 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: github.com/x1unix/docusearch/internal/services/store (interfaces: MetadataStore)
+
+// Package mocks is a generated GoMock package.
+package mocks
+
+import (
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "github.com/golang/mock/gomock"
+	store "github.com/x1unix/docusearch/internal/services/store"
+)
+
+// MockMetadataStore is a mock of MetadataStore interface.
+type MockMetadataStore struct {
+	ctrl     *gomock.Controller
+	recorder *MockMetadataStoreMockRecorder
+}
+
+// MockMetadataStoreMockRecorder is the mock recorder for MockMetadataStore.
+type MockMetadataStoreMockRecorder struct {
+	mock *MockMetadataStore
+}
+
+// NewMockMetadataStore creates a new mock instance.
+func NewMockMetadataStore(ctrl *gomock.Controller) *MockMetadataStore {
+	mock := &MockMetadataStore{ctrl: ctrl}
+	mock.recorder = &MockMetadataStoreMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMetadataStore) EXPECT() *MockMetadataStoreMockRecorder {
+	return m.recorder
+}
+
+// GetMetadata mocks base method.
+func (m *MockMetadataStore) GetMetadata(arg0 context.Context, arg1 string) (*store.Metadata, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetMetadata", arg0, arg1)
+	ret0, _ := ret[0].(*store.Metadata)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetMetadata indicates an expected call of GetMetadata.
+func (mr *MockMetadataStoreMockRecorder) GetMetadata(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMetadata", reflect.TypeOf((*MockMetadataStore)(nil).GetMetadata), arg0, arg1)
+}
+
+// ListExpired mocks base method.
+func (m *MockMetadataStore) ListExpired(arg0 context.Context, arg1 time.Time, arg2 int) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListExpired", arg0, arg1, arg2)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListExpired indicates an expected call of ListExpired.
+func (mr *MockMetadataStoreMockRecorder) ListExpired(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListExpired", reflect.TypeOf((*MockMetadataStore)(nil).ListExpired), arg0, arg1, arg2)
+}
+
+// RemoveMetadata mocks base method.
+func (m *MockMetadataStore) RemoveMetadata(arg0 context.Context, arg1 string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveMetadata", arg0, arg1)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveMetadata indicates an expected call of RemoveMetadata.
+func (mr *MockMetadataStoreMockRecorder) RemoveMetadata(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveMetadata", reflect.TypeOf((*MockMetadataStore)(nil).RemoveMetadata), arg0, arg1)
+}
+
+// SaveMetadata mocks base method.
+func (m *MockMetadataStore) SaveMetadata(arg0 context.Context, arg1 string, arg2 *store.Metadata) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveMetadata", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveMetadata indicates an expected call of SaveMetadata.
+func (mr *MockMetadataStoreMockRecorder) SaveMetadata(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveMetadata", reflect.TypeOf((*MockMetadataStore)(nil).SaveMetadata), arg0, arg1, arg2)
+}
@@ -9,6 +9,7 @@ import (
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
+	search "github.com/x1unix/docusearch/internal/services/search"
 )
 
 // MockProvider is a mock of Provider interface.
@@ -62,6 +63,21 @@ func (mr *MockProviderMockRecorder) RemoveDocumentRef(arg0, arg1 interface{}) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveDocumentRef", reflect.TypeOf((*MockProvider)(nil).RemoveDocumentRef), arg0, arg1)
 }
 
+// SearchDocuments mocks base method.
+func (m *MockProvider) SearchDocuments(arg0 context.Context, arg1 search.Query) (*search.Result, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchDocuments", arg0, arg1)
+	ret0, _ := ret[0].(*search.Result)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchDocuments indicates an expected call of SearchDocuments.
+func (mr *MockProviderMockRecorder) SearchDocuments(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchDocuments", reflect.TypeOf((*MockProvider)(nil).SearchDocuments), arg0, arg1)
+}
+
 // SearchDocumentsByWord mocks base method.
 func (m *MockProvider) SearchDocumentsByWord(arg0 context.Context, arg1 string) ([]string, error) {
 	m.ctrl.T.Helper()
@@ -76,3 +92,31 @@ func (mr *MockProviderMockRecorder) SearchDocumentsByWord(arg0, arg1 interface{}
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchDocumentsByWord", reflect.TypeOf((*MockProvider)(nil).SearchDocumentsByWord), arg0, arg1)
 }
+
+// UpdateDocumentLabels mocks base method.
+func (m *MockProvider) UpdateDocumentLabels(arg0 context.Context, arg1 string, arg2 map[string]string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateDocumentLabels", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateDocumentLabels indicates an expected call of UpdateDocumentLabels.
+func (mr *MockProviderMockRecorder) UpdateDocumentLabels(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateDocumentLabels", reflect.TypeOf((*MockProvider)(nil).UpdateDocumentLabels), arg0, arg1, arg2)
+}
+
+// UpdateDocumentRef mocks base method.
+func (m *MockProvider) UpdateDocumentRef(arg0 context.Context, arg1 string, arg2 []string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateDocumentRef", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateDocumentRef indicates an expected call of UpdateDocumentRef.
+func (mr *MockProviderMockRecorder) UpdateDocumentRef(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateDocumentRef", reflect.TypeOf((*MockProvider)(nil).UpdateDocumentRef), arg0, arg1, arg2)
+}
@@ -10,6 +10,7 @@ import (
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
+	store "github.com/x1unix/docusearch/internal/services/store"
 )
 
 // MockDocumentStore is a mock of DocumentStore interface.
@@ -64,6 +65,51 @@ func (mr *MockDocumentStoreMockRecorder) GetDocument(arg0 interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDocument", reflect.TypeOf((*MockDocumentStore)(nil).GetDocument), arg0)
 }
 
+// GetDocumentVersion mocks base method.
+func (m *MockDocumentStore) GetDocumentVersion(arg0 string, arg1 int) (io.ReadCloser, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDocumentVersion", arg0, arg1)
+	ret0, _ := ret[0].(io.ReadCloser)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDocumentVersion indicates an expected call of GetDocumentVersion.
+func (mr *MockDocumentStoreMockRecorder) GetDocumentVersion(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDocumentVersion", reflect.TypeOf((*MockDocumentStore)(nil).GetDocumentVersion), arg0, arg1)
+}
+
+// List mocks base method.
+func (m *MockDocumentStore) List(arg0 context.Context, arg1 store.ListOptions) (*store.ListResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", arg0, arg1)
+	ret0, _ := ret[0].(*store.ListResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockDocumentStoreMockRecorder) List(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockDocumentStore)(nil).List), arg0, arg1)
+}
+
+// ListVersions mocks base method.
+func (m *MockDocumentStore) ListVersions(arg0 context.Context, arg1 string) ([]store.VersionInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListVersions", arg0, arg1)
+	ret0, _ := ret[0].([]store.VersionInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListVersions indicates an expected call of ListVersions.
+func (mr *MockDocumentStoreMockRecorder) ListVersions(arg0, arg1 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListVersions", reflect.TypeOf((*MockDocumentStore)(nil).ListVersions), arg0, arg1)
+}
+
 // RemoveDocument mocks base method.
 func (m *MockDocumentStore) RemoveDocument(arg0 context.Context, arg1 string) error {
 	m.ctrl.T.Helper()
@@ -77,3 +123,17 @@ func (mr *MockDocumentStoreMockRecorder) RemoveDocument(arg0, arg1 interface{})
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveDocument", reflect.TypeOf((*MockDocumentStore)(nil).RemoveDocument), arg0, arg1)
 }
+
+// ReplaceDocument mocks base method.
+func (m *MockDocumentStore) ReplaceDocument(arg0 context.Context, arg1 string, arg2 io.Reader) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplaceDocument", arg0, arg1, arg2)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReplaceDocument indicates an expected call of ReplaceDocument.
+func (mr *MockDocumentStoreMockRecorder) ReplaceDocument(arg0, arg1, arg2 interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplaceDocument", reflect.TypeOf((*MockDocumentStore)(nil).ReplaceDocument), arg0, arg1, arg2)
+}
@@ -0,0 +1,56 @@
+package store
+
+import "errors"
+
+// AnyChecksum is precondition value which matches any existing document.
+const AnyChecksum = "*"
+
+// ErrPreconditionFailed is returned when document doesn't satisfy write precondition.
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// Precondition is conditional write requirement based on document checksum.
+//
+// Follows If-Match and If-None-Match HTTP headers semantics.
+type Precondition struct {
+	// IfMatch is list of checksums and one of them should match the document.
+	//
+	// AnyChecksum requires document to exist.
+	IfMatch []string
+
+	// IfNoneMatch is list of checksums which shouldn't match the document.
+	//
+	// AnyChecksum requires document to not exist.
+	IfNoneMatch []string
+}
+
+// IsEmpty returns whether precondition has no requirements.
+func (p Precondition) IsEmpty() bool {
+	return len(p.IfMatch) == 0 && len(p.IfNoneMatch) == 0
+}
+
+// Check checks if document satisfies precondition.
+//
+// Nil metadata means that document doesn't exist.
+func (p Precondition) Check(meta *Metadata) error {
+	if len(p.IfMatch) > 0 {
+		if meta == nil || !matchChecksum(p.IfMatch, meta.SHA256) {
+			return ErrPreconditionFailed
+		}
+	}
+
+	if len(p.IfNoneMatch) > 0 && meta != nil && matchChecksum(p.IfNoneMatch, meta.SHA256) {
+		return ErrPreconditionFailed
+	}
+
+	return nil
+}
+
+func matchChecksum(list []string, checksum string) bool {
+	for _, v := range list {
+		if v == AnyChecksum || v == checksum {
+			return true
+		}
+	}
+
+	return false
+}
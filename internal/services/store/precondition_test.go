@@ -0,0 +1,66 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrecondition_Check(t *testing.T) {
+	meta := &Metadata{SHA256: "abc"}
+	cases := map[string]struct {
+		cond    Precondition
+		meta    *Metadata
+		wantErr bool
+	}{
+		"empty precondition": {
+			meta: meta,
+		},
+		"if-match checksum": {
+			cond: Precondition{IfMatch: []string{"foo", "abc"}},
+			meta: meta,
+		},
+		"if-match checksum mismatch": {
+			cond:    Precondition{IfMatch: []string{"foo"}},
+			meta:    meta,
+			wantErr: true,
+		},
+		"if-match any": {
+			cond: Precondition{IfMatch: []string{AnyChecksum}},
+			meta: meta,
+		},
+		"if-match any not exists": {
+			cond:    Precondition{IfMatch: []string{AnyChecksum}},
+			wantErr: true,
+		},
+		"if-none-match any": {
+			cond: Precondition{IfNoneMatch: []string{AnyChecksum}},
+		},
+		"if-none-match any exists": {
+			cond:    Precondition{IfNoneMatch: []string{AnyChecksum}},
+			meta:    meta,
+			wantErr: true,
+		},
+		"if-none-match checksum": {
+			cond:    Precondition{IfNoneMatch: []string{"abc"}},
+			meta:    meta,
+			wantErr: true,
+		},
+		"if-none-match other checksum": {
+			cond: Precondition{IfNoneMatch: []string{"foo"}},
+			meta: meta,
+		},
+	}
+
+	for n, c := range cases {
+		t.Run(n, func(t *testing.T) {
+			err := c.cond.Check(c.meta)
+			if c.wantErr {
+				require.ErrorIs(t, err, ErrPreconditionFailed)
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
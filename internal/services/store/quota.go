@@ -0,0 +1,351 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// usageBatchSize is number of documents processed per listing page during usage calculation.
+const usageBatchSize = 1000
+
+var (
+	// ErrQuotaExceeded is returned when document write exceeds namespace storage quota.
+	ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+	// ErrQuotasDisabled is returned on usage request when quotas are not enabled.
+	ErrQuotasDisabled = errors.New("storage quotas are not enabled")
+)
+
+// QuotaLimit is a resource limited by storage quota.
+type QuotaLimit string
+
+const (
+	// QuotaLimitDocuments limits number of documents.
+	QuotaLimitDocuments QuotaLimit = "documents"
+
+	// QuotaLimitBytes limits total size of documents.
+	QuotaLimitBytes QuotaLimit = "bytes"
+)
+
+// QuotaError is returned when namespace quota is exceeded.
+//
+// QuotaError matches ErrQuotaExceeded using errors.Is.
+type QuotaError struct {
+	// Namespace is namespace name.
+	Namespace string
+
+	// Limit is exceeded resource.
+	Limit QuotaLimit
+}
+
+// Error implements error
+func (e QuotaError) Error() string {
+	return fmt.Sprintf("namespace %q exceeds %s quota", e.Namespace, e.Limit)
+}
+
+// Is reports whether target is ErrQuotaExceeded.
+func (e QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// Quota is namespace storage quota.
+//
+// Zero values mean no limit.
+type Quota struct {
+	// MaxDocuments is max number of documents.
+	MaxDocuments int64
+
+	// MaxBytes is max total size of documents in bytes.
+	MaxBytes int64
+}
+
+// IsUnlimited reports whether quota has no limits.
+func (q Quota) IsUnlimited() bool {
+	return q.MaxDocuments <= 0 && q.MaxBytes <= 0
+}
+
+// Usage is namespace storage usage.
+type Usage struct {
+	// Documents is number of documents.
+	Documents int64
+
+	// Bytes is total size of documents in bytes.
+	Bytes int64
+}
+
+// UsageStore keeps storage usage counters of namespaces.
+type UsageStore interface {
+	// GetUsage returns namespace usage.
+	//
+	// Should return zero usage if namespace has no documents.
+	GetUsage(ctx context.Context, namespace string) (Usage, error)
+
+	// AddUsage atomically adds delta to namespace usage and returns updated usage.
+	AddUsage(ctx context.Context, namespace string, delta Usage) (Usage, error)
+
+	// ResetUsage replaces usage of all namespaces.
+	ResetUsage(ctx context.Context, usage map[string]Usage) error
+
+	// IsTracked reports whether usage was initialized by ResetUsage.
+	IsTracked(ctx context.Context) (bool, error)
+}
+
+// Quotas enforces storage quotas of document namespaces.
+//
+// Namespace is a part of document name before namespace separator,
+// e.g. "team-a" for "team-a:report.txt" document.
+// Documents without separator in name belong to default namespace with empty name.
+type Quotas struct {
+	usage        UsageStore
+	separator    string
+	defaultQuota Quota
+	namespaces   map[string]Quota
+}
+
+// NewQuotas constructs a new namespace quotas.
+//
+// Default quota is applied to namespaces which are not present in namespaces map.
+func NewQuotas(usage UsageStore, separator string, defaultQuota Quota, namespaces map[string]Quota) *Quotas {
+	return &Quotas{
+		usage:        usage,
+		separator:    separator,
+		defaultQuota: defaultQuota,
+		namespaces:   namespaces,
+	}
+}
+
+// Namespace returns namespace of a document.
+func (q Quotas) Namespace(name string) string {
+	i := strings.Index(name, q.separator)
+	if i == -1 {
+		return ""
+	}
+
+	return name[:i]
+}
+
+// Quota returns namespace quota.
+func (q Quotas) Quota(namespace string) Quota {
+	if quota, ok := q.namespaces[namespace]; ok {
+		return quota
+	}
+
+	return q.defaultQuota
+}
+
+// WithQuotas enables storage quotas of document namespaces.
+//
+// New documents are counted before write, so parallel uploads can't exceed documents quota.
+// Size of a document is counted only after write, so namespace might exceed bytes quota
+// by size of documents uploaded in parallel.
+func (s *SyncedDocumentStore) WithQuotas(quotas *Quotas) *SyncedDocumentStore {
+	s.quotas = quotas
+	return s
+}
+
+// GetUsage returns namespace storage usage and quota.
+//
+// Returns ErrQuotasDisabled if quotas are not enabled.
+func (s SyncedDocumentStore) GetUsage(ctx context.Context, namespace string) (Usage, Quota, error) {
+	if s.quotas == nil {
+		return Usage{}, Quota{}, ErrQuotasDisabled
+	}
+
+	usage, err := s.quotas.usage.GetUsage(ctx, namespace)
+	if err != nil {
+		return Usage{}, Quota{}, fmt.Errorf("failed to get namespace usage: %w", err)
+	}
+
+	return usage, s.quotas.Quota(namespace), nil
+}
+
+// InitUsage calculates usage of all namespaces from stored documents if usage is not tracked yet.
+//
+// Document sizes are taken from metadata, which is calculated for documents stored without it.
+// Should be called before serving writes, as writes during calculation might be not counted.
+func (s SyncedDocumentStore) InitUsage(ctx context.Context) error {
+	if s.quotas == nil {
+		return nil
+	}
+
+	tracked, err := s.quotas.usage.IsTracked(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check namespace usage: %w", err)
+	}
+
+	if tracked {
+		return nil
+	}
+
+	usage := make(map[string]Usage)
+	var cursor string
+	for {
+		page, err := s.store.List(ctx, ListOptions{
+			SortBy: SortByName,
+			Cursor: cursor,
+			Limit:  usageBatchSize,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list documents: %w", err)
+		}
+
+		for _, item := range page.Items {
+			// Size in storage might differ from document size if documents are encoded,
+			// so metadata is used as for usage updates on write.
+			meta, err := s.metadata(ctx, item.Name)
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					// Document was removed during listing.
+					continue
+				}
+
+				return fmt.Errorf("failed to get %q document metadata: %w", item.Name, err)
+			}
+
+			ns := s.quotas.Namespace(item.Name)
+			nsUsage := usage[ns]
+			nsUsage.Documents++
+			nsUsage.Bytes += meta.Size
+			usage[ns] = nsUsage
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+
+		cursor = page.NextCursor
+	}
+
+	if err := s.quotas.usage.ResetUsage(ctx, usage); err != nil {
+		return fmt.Errorf("failed to save namespace usage: %w", err)
+	}
+
+	return nil
+}
+
+// limitQuota checks namespace quota before document write and returns a reader
+// which fails with QuotaError if document exceeds remaining namespace quota.
+//
+// A new document is counted in namespace usage before write, so releaseQuota
+// should be called if document write fails.
+// Previous metadata is nil for a new document.
+func (s SyncedDocumentStore) limitQuota(ctx context.Context, name string, data io.Reader, prev *Metadata) (io.Reader, error) {
+	if s.quotas == nil {
+		return data, nil
+	}
+
+	ns := s.quotas.Namespace(name)
+	usage, err := s.reserveQuota(ctx, ns, prev)
+	if err != nil {
+		return nil, err
+	}
+
+	quota := s.quotas.Quota(ns)
+	if quota.IsUnlimited() {
+		return data, nil
+	}
+
+	if prev != nil {
+		// Replaced document is not counted.
+		usage.Bytes -= prev.Size
+	}
+
+	// Written document is not counted to compare usage with the limit.
+	usage.Documents--
+	if quota.MaxDocuments > 0 && usage.Documents >= quota.MaxDocuments {
+		s.releaseQuota(ctx, name, prev)
+		return nil, QuotaError{Namespace: ns, Limit: QuotaLimitDocuments}
+	}
+
+	if quota.MaxBytes <= 0 {
+		return data, nil
+	}
+
+	if usage.Bytes >= quota.MaxBytes {
+		s.releaseQuota(ctx, name, prev)
+		return nil, QuotaError{Namespace: ns, Limit: QuotaLimitBytes}
+	}
+
+	return &quotaReader{
+		r:         data,
+		remaining: quota.MaxBytes - usage.Bytes,
+		err:       QuotaError{Namespace: ns, Limit: QuotaLimitBytes},
+	}, nil
+}
+
+// reserveQuota counts a new document in namespace usage and returns namespace usage.
+//
+// Usage is returned unchanged for a replaced document.
+func (s SyncedDocumentStore) reserveQuota(ctx context.Context, ns string, prev *Metadata) (Usage, error) {
+	if prev != nil {
+		usage, err := s.quotas.usage.GetUsage(ctx, ns)
+		if err != nil {
+			return Usage{}, fmt.Errorf("failed to get namespace usage: %w", err)
+		}
+
+		return usage, nil
+	}
+
+	usage, err := s.quotas.usage.AddUsage(ctx, ns, Usage{Documents: 1})
+	if err != nil {
+		return Usage{}, fmt.Errorf("failed to reserve namespace usage: %w", err)
+	}
+
+	return usage, nil
+}
+
+// releaseQuota removes a new document counted by limitQuota from namespace usage.
+func (s SyncedDocumentStore) releaseQuota(ctx context.Context, name string, prev *Metadata) {
+	if prev == nil {
+		s.updateUsage(ctx, name, Usage{Documents: -1})
+	}
+}
+
+// updateUsage adds document write or removal to namespace usage.
+//
+// Document is already written, so error is only logged.
+func (s SyncedDocumentStore) updateUsage(ctx context.Context, name string, delta Usage) {
+	if s.quotas == nil {
+		return
+	}
+
+	if _, err := s.quotas.usage.AddUsage(ctx, s.quotas.Namespace(name), delta); err != nil {
+		s.log.Error("failed to update namespace usage", zap.String("name", name), zap.Error(err))
+	}
+}
+
+// usageDelta returns namespace usage change caused by document write.
+//
+// A new document is already counted by limitQuota.
+func usageDelta(meta, prev *Metadata) Usage {
+	if prev == nil {
+		return Usage{Bytes: meta.Size}
+	}
+
+	return Usage{Bytes: meta.Size - prev.Size}
+}
+
+// quotaReader is io.Reader which returns quota error
+// if more than remaining bytes were read from underlying reader.
+type quotaReader struct {
+	r         io.Reader
+	remaining int64
+	err       error
+}
+
+// Read implements io.Reader
+func (q *quotaReader) Read(p []byte) (int, error) {
+	n, err := q.r.Read(p)
+	q.remaining -= int64(n)
+	if q.remaining < 0 {
+		return n, q.err
+	}
+
+	return n, err
+}
@@ -0,0 +1,236 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/x1unix/docusearch/internal/services/store"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestQuotas_Namespace(t *testing.T) {
+	q := store.NewQuotas(nil, ":", store.Quota{MaxDocuments: 1}, map[string]store.Quota{
+		"team-a": {MaxBytes: 10},
+	})
+
+	cases := map[string]struct {
+		name      string
+		namespace string
+		quota     store.Quota
+	}{
+		"default namespace": {
+			name:  "doc.txt",
+			quota: store.Quota{MaxDocuments: 1},
+		},
+		"configured namespace": {
+			name:      "team-a:doc.txt",
+			namespace: "team-a",
+			quota:     store.Quota{MaxBytes: 10},
+		},
+		"nested name": {
+			name:      "team-b:foo:doc.txt",
+			namespace: "team-b",
+			quota:     store.Quota{MaxDocuments: 1},
+		},
+	}
+
+	for n, c := range cases {
+		t.Run(n, func(t *testing.T) {
+			ns := q.Namespace(c.name)
+			require.Equal(t, c.namespace, ns)
+			require.Equal(t, c.quota, q.Quota(ns))
+		})
+	}
+}
+
+func TestSyncedDocumentStore_Quotas(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "docsearch-test-*")
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tmpDir))
+	}()
+
+	srv := miniredis.RunT(t)
+	conn := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	defer conn.Close()
+
+	ctx := context.TODO()
+	fileStore := store.NewFileDocumentStore(tmpDir)
+	require.NoError(t, fileStore.AddDocument(ctx, "a:old", strings.NewReader("12345")))
+
+	quotas := store.NewQuotas(store.NewRedisUsageStore(conn), ":", store.Quota{}, map[string]store.Quota{
+		"a": {MaxDocuments: 2, MaxBytes: 10},
+	})
+	syncStore := store.NewSyncedDocumentStore(zaptest.NewLogger(t), fileStore, newMemoryMetaStore(),
+		newMemoryIndex(), nil, store.TextIndexConfig{}).WithQuotas(quotas)
+
+	// Documents stored before quotas were enabled should be counted
+	require.NoError(t, syncStore.InitUsage(ctx))
+	assertUsage := func(t *testing.T, namespace string, want store.Usage) {
+		t.Helper()
+		got, _, err := syncStore.GetUsage(ctx, namespace)
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	}
+	assertQuotaError := func(t *testing.T, err error, limit store.QuotaLimit) {
+		t.Helper()
+		require.ErrorIs(t, err, store.ErrQuotaExceeded)
+		var quotaErr store.QuotaError
+		require.True(t, errors.As(err, &quotaErr))
+		require.Equal(t, store.QuotaError{Namespace: "a", Limit: limit}, quotaErr)
+	}
+	assertUsage(t, "a", store.Usage{Documents: 1, Bytes: 5})
+
+	// Bytes quota should be checked while document is written
+	_, err = syncStore.AddDocument(ctx, "a:big", strings.NewReader("123456"), store.WriteOptions{})
+	assertQuotaError(t, err, store.QuotaLimitBytes)
+	_, err = syncStore.GetDocument("a:big")
+	require.Error(t, err, "document over quota should not be stored")
+	assertUsage(t, "a", store.Usage{Documents: 1, Bytes: 5})
+
+	_, err = syncStore.AddDocument(ctx, "a:new", strings.NewReader("123"), store.WriteOptions{})
+	require.NoError(t, err)
+	assertUsage(t, "a", store.Usage{Documents: 2, Bytes: 8})
+
+	_, err = syncStore.AddDocument(ctx, "a:extra", strings.NewReader(""), store.WriteOptions{})
+	assertQuotaError(t, err, store.QuotaLimitDocuments)
+
+	// Replaced document size should not be counted
+	_, err = syncStore.ReplaceDocument(ctx, "a:new", strings.NewReader("12345"), store.WriteOptions{})
+	require.NoError(t, err)
+	assertUsage(t, "a", store.Usage{Documents: 2, Bytes: 10})
+
+	_, err = syncStore.ReplaceDocument(ctx, "a:old", strings.NewReader("123456"), store.WriteOptions{})
+	assertQuotaError(t, err, store.QuotaLimitBytes)
+	require.Equal(t, []byte("12345"), readDocument(t, syncStore, "a:old"), "document over quota should not be replaced")
+
+	// Other namespaces are not limited
+	_, err = syncStore.AddDocument(ctx, "b:doc", strings.NewReader("12345678901"), store.WriteOptions{})
+	require.NoError(t, err)
+	assertUsage(t, "b", store.Usage{Documents: 1, Bytes: 11})
+
+	require.NoError(t, syncStore.RemoveDocument(ctx, "a:old", store.Precondition{}))
+	assertUsage(t, "a", store.Usage{Documents: 1, Bytes: 5})
+
+	// Usage should be calculated only once
+	require.NoError(t, fileStore.AddDocument(ctx, "a:untracked", strings.NewReader("1")))
+	require.NoError(t, syncStore.InitUsage(ctx))
+	assertUsage(t, "a", store.Usage{Documents: 1, Bytes: 5})
+
+	usage, quota, err := syncStore.GetUsage(ctx, "c")
+	require.NoError(t, err)
+	require.Zero(t, usage)
+	require.True(t, quota.IsUnlimited())
+}
+
+// storageSizeStore is document store stub which reports sizes in storage
+// different from document sizes, as stores which keep documents encoded.
+type storageSizeStore struct {
+	store.DocumentStore
+}
+
+func (s storageSizeStore) List(ctx context.Context, opts store.ListOptions) (*store.ListResult, error) {
+	result, err := s.DocumentStore.List(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range result.Items {
+		result.Items[i].Size += 100
+	}
+
+	return result, nil
+}
+
+func TestSyncedDocumentStore_InitUsageStorageSize(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "docsearch-test-*")
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tmpDir))
+	}()
+
+	srv := miniredis.RunT(t)
+	conn := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	defer conn.Close()
+
+	ctx := context.TODO()
+	fileStore := store.NewFileDocumentStore(tmpDir)
+	syncStore := store.NewSyncedDocumentStore(zaptest.NewLogger(t), storageSizeStore{fileStore},
+		newMemoryMetaStore(), newMemoryIndex(), nil, store.TextIndexConfig{})
+	_, err = syncStore.AddDocument(ctx, "a:doc", strings.NewReader("12345"), store.WriteOptions{})
+	require.NoError(t, err)
+	require.NoError(t, fileStore.AddDocument(ctx, "a:untracked", strings.NewReader("123")))
+
+	// Usage should be calculated from document sizes as for updates on write
+	quotas := store.NewQuotas(store.NewRedisUsageStore(conn), ":", store.Quota{}, nil)
+	syncStore.WithQuotas(quotas)
+	require.NoError(t, syncStore.InitUsage(ctx))
+	got, _, err := syncStore.GetUsage(ctx, "a")
+	require.NoError(t, err)
+	require.Equal(t, store.Usage{Documents: 2, Bytes: 8}, got)
+}
+
+func TestSyncedDocumentStore_QuotasParallel(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "docsearch-test-*")
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tmpDir))
+	}()
+
+	srv := miniredis.RunT(t)
+	conn := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	defer conn.Close()
+
+	ctx := context.TODO()
+	quotas := store.NewQuotas(store.NewRedisUsageStore(conn), ":", store.Quota{MaxDocuments: 3}, nil)
+	syncStore := store.NewSyncedDocumentStore(zaptest.NewLogger(t), store.NewFileDocumentStore(tmpDir),
+		newMemoryMetaStore(), newMemoryIndex(), nil, store.TextIndexConfig{}).WithQuotas(quotas)
+	require.NoError(t, syncStore.InitUsage(ctx))
+
+	// Parallel uploads should not exceed documents quota
+	const uploads = 20
+	var (
+		wg     sync.WaitGroup
+		stored int32
+	)
+	for i := 0; i < uploads; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := syncStore.AddDocument(ctx, fmt.Sprintf("a:%d", i), strings.NewReader("foo"), store.WriteOptions{})
+			if err == nil {
+				atomic.AddInt32(&stored, 1)
+				return
+			}
+
+			assert.ErrorIs(t, err, store.ErrQuotaExceeded)
+		}(i)
+	}
+	wg.Wait()
+
+	require.Equal(t, int32(3), stored)
+	usage, _, err := syncStore.GetUsage(ctx, "a")
+	require.NoError(t, err)
+	require.Equal(t, store.Usage{Documents: 3, Bytes: 9}, usage)
+}
+
+func readDocument(t *testing.T, s *store.SyncedDocumentStore, name string) []byte {
+	t.Helper()
+	r, err := s.GetDocument(name)
+	require.NoError(t, err)
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	return data
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// spoolDir is directory of spool files, system temporary directory is used if empty.
+var spoolDir = ""
+
+// spoolFile is a temporary file used to buffer document before it's written to storage.
+//
+// Spooled data is encrypted with ephemeral key which is kept only in memory,
+// so document contents can't be recovered from a spool file left after crash
+// even if documents are encrypted at rest.
+type spoolFile struct {
+	fd     *os.File
+	block  cipher.Block
+	iv     []byte
+	writer io.Writer
+}
+
+// newSpoolFile creates a new spool file using file name pattern.
+func newSpoolFile(pattern string) (*spoolFile, error) {
+	key := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := ioutil.TempFile(spoolDir, pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+
+	return &spoolFile{
+		fd:     fd,
+		block:  block,
+		iv:     iv,
+		writer: cipher.StreamWriter{S: cipher.NewCTR(block, iv), W: fd},
+	}, nil
+}
+
+// Write implements io.Writer
+func (s *spoolFile) Write(p []byte) (int, error) {
+	return s.writer.Write(p)
+}
+
+// Reader returns reader of spooled data from the beginning.
+//
+// File should not be written after this call.
+func (s *spoolFile) Reader() (io.Reader, error) {
+	if _, err := s.fd.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	return cipher.StreamReader{S: cipher.NewCTR(s.block, s.iv), R: s.fd}, nil
+}
+
+// Close closes and removes spool file.
+func (s *spoolFile) Close() error {
+	err := s.fd.Close()
+	if rmErr := os.Remove(s.fd.Name()); err == nil {
+		err = rmErr
+	}
+
+	return err
+}
@@ -0,0 +1,118 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/x1unix/docusearch/internal/services/lock"
+)
+
+// spoolCheckStore is document store which checks that written data
+// can't be found in spool files during write.
+type spoolCheckStore struct {
+	DocumentStore
+	t      *testing.T
+	offset int
+	writes int
+}
+
+func (s *spoolCheckStore) AddDocument(ctx context.Context, name string, data io.Reader) error {
+	return s.DocumentStore.AddDocument(ctx, name, s.checkSpool(data))
+}
+
+func (s *spoolCheckStore) ReplaceDocument(ctx context.Context, name string, data io.Reader) error {
+	return s.DocumentStore.ReplaceDocument(ctx, name, s.checkSpool(data))
+}
+
+func (s *spoolCheckStore) checkSpool(data io.Reader) io.Reader {
+	s.t.Helper()
+	got, err := ioutil.ReadAll(data)
+	require.NoError(s.t, err)
+
+	entries, err := os.ReadDir(spoolDir)
+	require.NoError(s.t, err)
+	require.NotEmpty(s.t, entries, "document should be spooled")
+	for _, e := range entries {
+		spooled, err := os.ReadFile(filepath.Join(spoolDir, e.Name()))
+		require.NoError(s.t, err)
+		require.NotEmpty(s.t, spooled)
+		require.False(s.t, bytes.Contains(spooled, got[s.offset:]), "spool file %q contains document data", e.Name())
+	}
+
+	s.writes++
+	return bytes.NewReader(got)
+}
+
+func TestSpoolFile(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "test-store-spool-*")
+	require.NoError(t, err, "failed to create temp dir")
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tmpDir), "failed to remove temp dir")
+	}()
+
+	spoolDir = filepath.Join(tmpDir, "spool")
+	require.NoError(t, os.Mkdir(spoolDir, 0755))
+	defer func() {
+		spoolDir = ""
+	}()
+
+	ctx := context.TODO()
+	data := strings.Repeat("The quick brown fox jumps over the lazy dog. ", 100)
+	cases := map[string]struct {
+		offset   int
+		newStore func(t *testing.T, s DocumentStore) DocumentStore
+	}{
+		"compressed": {
+			offset: compressedHeaderSize,
+			newStore: func(_ *testing.T, s DocumentStore) DocumentStore {
+				return NewCompressedDocumentStore(s, CompressionGzip)
+			},
+		},
+		"dedup": {
+			newStore: func(t *testing.T, s DocumentStore) DocumentStore {
+				srv := miniredis.RunT(t)
+				conn := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+				t.Cleanup(func() {
+					_ = conn.Close()
+				})
+
+				return NewDedupDocumentStore(s, NewRedisBlobRefStore(conn), lock.NewMemoryLocker())
+			},
+		},
+	}
+
+	for n, c := range cases {
+		t.Run(n, func(t *testing.T) {
+			check := &spoolCheckStore{
+				DocumentStore: NewFileDocumentStore(filepath.Join(tmpDir, n)),
+				t:             t,
+				offset:        c.offset,
+			}
+			s := c.newStore(t, check)
+			require.NoError(t, s.AddDocument(ctx, "foo", strings.NewReader(data)))
+			require.NoError(t, s.ReplaceDocument(ctx, "foo", strings.NewReader(data+"bar")))
+			require.Equal(t, 2, check.writes)
+
+			r, err := s.GetDocument("foo")
+			require.NoError(t, err)
+			got, err := ioutil.ReadAll(r)
+			require.NoError(t, err)
+			require.NoError(t, r.Close())
+			require.Equal(t, data+"bar", string(got))
+
+			entries, err := os.ReadDir(spoolDir)
+			require.NoError(t, err)
+			require.Empty(t, entries, "spool files should be removed")
+		})
+	}
+}
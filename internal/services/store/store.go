@@ -3,8 +3,21 @@ package store
 import (
 	"context"
 	"io"
+	"time"
 )
 
+// VersionInfo describes a document revision.
+type VersionInfo struct {
+	// Version is revision number, starting from 1.
+	Version int
+
+	// Size is revision size in bytes.
+	Size int64
+
+	// CreatedAt is revision write time.
+	CreatedAt time.Time
+}
+
 // DocumentStore is abstract document storage.
 type DocumentStore interface {
 	// AddDocument stores a new document.
@@ -12,6 +25,11 @@ type DocumentStore interface {
 	// Should return fs.ErrExist if item already exists.
 	AddDocument(ctx context.Context, name string, data io.Reader) error
 
+	// ReplaceDocument stores document, atomically replacing existing one.
+	//
+	// Creates a new document if it doesn't exist.
+	ReplaceDocument(ctx context.Context, name string, data io.Reader) error
+
 	// RemoveDocument removes document from storage.
 	//
 	// Should return fs.ErrNotExist if item doesn't exist.
@@ -21,4 +39,76 @@ type DocumentStore interface {
 	//
 	// Should return fs.ErrNotExist if item doesn't exist.
 	GetDocument(name string) (io.ReadCloser, error)
+
+	// List returns a page of stored documents list.
+	//
+	// Should return ErrInvalidCursor if passed cursor is malformed.
+	List(ctx context.Context, opts ListOptions) (*ListResult, error)
+
+	// ListVersions returns list of kept document revisions sorted by version.
+	//
+	// The last item is current document version.
+	// Should return fs.ErrNotExist if item doesn't exist.
+	ListVersions(ctx context.Context, name string) ([]VersionInfo, error)
+
+	// GetDocumentVersion returns reader of specified document revision.
+	//
+	// Should return fs.ErrNotExist if item or revision doesn't exist.
+	GetDocumentVersion(name string, version int) (io.ReadCloser, error)
+}
+
+// IndexedDocumentStore is document storage which keeps documents metadata and search index in sync.
+//
+// Implemented by SyncedDocumentStore.
+type IndexedDocumentStore interface {
+	// AddDocument stores a new document and adds it to search index.
+	//
+	// Should return fs.ErrExist if item already exists
+	// and ErrPreconditionFailed if document doesn't satisfy write precondition.
+	AddDocument(ctx context.Context, name string, data io.Reader, opts WriteOptions) (*Metadata, error)
+
+	// ReplaceDocument creates or replaces a document and updates search index.
+	//
+	// Should return ErrPreconditionFailed if document doesn't satisfy write precondition.
+	ReplaceDocument(ctx context.Context, name string, data io.Reader, opts WriteOptions) (*Metadata, error)
+
+	// RemoveDocument removes document from storage and search index.
+	//
+	// Should return fs.ErrNotExist if item doesn't exist
+	// and ErrPreconditionFailed if document doesn't satisfy precondition.
+	RemoveDocument(ctx context.Context, name string, cond Precondition) error
+
+	// RestoreDocument restores removed document from trash and adds it back to search index.
+	//
+	// Should return fs.ErrNotExist if item isn't in trash
+	// and fs.ErrExist if a document with the same name was uploaded after removal.
+	RestoreDocument(ctx context.Context, name string) (*Metadata, error)
+
+	// GetEncodedDocument returns document reader using one of accepted content encodings.
+	//
+	// Should return fs.ErrNotExist if item doesn't exist.
+	GetEncodedDocument(name string, acceptEncodings []string) (io.ReadCloser, string, error)
+
+	// GetMetadata returns document metadata.
+	//
+	// Should return fs.ErrNotExist if item doesn't exist or expired.
+	GetMetadata(ctx context.Context, name string) (*Metadata, error)
+
+	// List returns a page of stored documents list.
+	List(ctx context.Context, opts ListOptions) (*ListResult, error)
+
+	// ListVersions returns list of kept document revisions.
+	//
+	// Should return fs.ErrNotExist if item doesn't exist.
+	ListVersions(ctx context.Context, name string) ([]VersionInfo, error)
+
+	// GetDocumentVersion returns reader of specified document revision.
+	//
+	// Should return fs.ErrNotExist if item or revision doesn't exist.
+	GetDocumentVersion(name string, version int) (io.ReadCloser, error)
+
+	// GetUsage returns namespace storage usage and quota.
+	//
+	// Should return ErrQuotasDisabled if quotas are not enabled.
+	GetUsage(ctx context.Context, namespace string) (Usage, Quota, error)
 }
@@ -0,0 +1,289 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is document compression algorithm.
+type Compression string
+
+const (
+	// CompressionNone disables compression.
+	CompressionNone Compression = ""
+
+	// CompressionGzip is gzip compression.
+	CompressionGzip Compression = "gzip"
+
+	// CompressionZstd is Zstandard compression.
+	CompressionZstd Compression = "zstd"
+)
+
+// compressedMagic is signature of compressed document header.
+//
+// Header consists of signature, compression algorithm ID and uncompressed size.
+var compressedMagic = []byte("\x89DSZ")
+
+const compressedHeaderSize = 4 + 1 + 8
+
+var compressionIDs = map[Compression]byte{
+	CompressionGzip: 'g',
+	CompressionZstd: 'z',
+}
+
+// ParseCompression parses compression algorithm name.
+func ParseCompression(str string) (Compression, error) {
+	switch c := Compression(str); c {
+	case CompressionNone, CompressionGzip, CompressionZstd:
+		return c, nil
+	default:
+		return CompressionNone, fmt.Errorf("unsupported compression algorithm %q", str)
+	}
+}
+
+// EncodedDocumentReader is implemented by document stores which keep documents encoded.
+type EncodedDocumentReader interface {
+	// GetEncodedDocument returns document reader using one of accepted content encodings.
+	//
+	// Returns decoded document and empty encoding if document encoding is not accepted.
+	// Should return fs.ErrNotExist if item doesn't exist.
+	GetEncodedDocument(name string, acceptEncodings []string) (io.ReadCloser, string, error)
+}
+
+// CompressedDocumentStore is document storage wrapper which compresses documents at rest.
+//
+// Documents stored before compression was enabled are returned as is.
+// Document sizes in List and ListVersions are uncompressed sizes.
+type CompressedDocumentStore struct {
+	store       DocumentStore
+	compression Compression
+}
+
+// NewCompressedDocumentStore wraps document store with compression using specified algorithm.
+func NewCompressedDocumentStore(store DocumentStore, compression Compression) *CompressedDocumentStore {
+	return &CompressedDocumentStore{store: store, compression: compression}
+}
+
+// AddDocument implements DocumentStore
+func (c CompressedDocumentStore) AddDocument(ctx context.Context, name string, data io.Reader) error {
+	return c.writeDocument(data, func(r io.Reader) error {
+		return c.store.AddDocument(ctx, name, r)
+	})
+}
+
+// ReplaceDocument implements DocumentStore
+func (c CompressedDocumentStore) ReplaceDocument(ctx context.Context, name string, data io.Reader) error {
+	return c.writeDocument(data, func(r io.Reader) error {
+		return c.store.ReplaceDocument(ctx, name, r)
+	})
+}
+
+// RemoveDocument implements DocumentStore
+func (c CompressedDocumentStore) RemoveDocument(ctx context.Context, name string) error {
+	return c.store.RemoveDocument(ctx, name)
+}
+
+// GetDocument implements DocumentStore
+func (c CompressedDocumentStore) GetDocument(name string) (io.ReadCloser, error) {
+	r, _, err := c.GetEncodedDocument(name, nil)
+	return r, err
+}
+
+// GetEncodedDocument implements EncodedDocumentReader
+func (c CompressedDocumentStore) GetEncodedDocument(name string, acceptEncodings []string) (io.ReadCloser, string, error) {
+	r, err := c.store.GetDocument(name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return decompressReader(r, acceptEncodings)
+}
+
+// List implements DocumentStore
+func (c CompressedDocumentStore) List(ctx context.Context, opts ListOptions) (*ListResult, error) {
+	return listDocumentSizes(ctx, c.store, opts, func(item DocumentInfo) (int64, error) {
+		return c.documentSize(func() (io.ReadCloser, error) {
+			return c.store.GetDocument(item.Name)
+		})
+	})
+}
+
+// ListVersions implements DocumentStore
+func (c CompressedDocumentStore) ListVersions(ctx context.Context, name string) ([]VersionInfo, error) {
+	versions, err := c.store.ListVersions(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, v := range versions {
+		size, err := c.documentSize(func() (io.ReadCloser, error) {
+			return c.store.GetDocumentVersion(name, v.Version)
+		})
+		if err != nil {
+			continue
+		}
+
+		versions[i].Size = size
+	}
+
+	return versions, nil
+}
+
+// GetDocumentVersion implements DocumentStore
+func (c CompressedDocumentStore) GetDocumentVersion(name string, version int) (io.ReadCloser, error) {
+	r, err := c.store.GetDocumentVersion(name, version)
+	if err != nil {
+		return nil, err
+	}
+
+	dr, _, err := decompressReader(r, nil)
+	return dr, err
+}
+
+// writeDocument compresses document and passes it to write function.
+//
+// Compressed document is spooled to a temporary file as uncompressed size
+// is written to header before compressed data.
+func (c CompressedDocumentStore) writeDocument(data io.Reader, write func(r io.Reader) error) error {
+	spool, err := newSpoolFile("docusearch-compress-*")
+	if err != nil {
+		return err
+	}
+
+	defer spool.Close() //nolint:errcheck
+
+	w, err := newCompressWriter(spool, c.compression)
+	if err != nil {
+		return err
+	}
+
+	size, err := io.Copy(w, data)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to compress document: %w", err)
+	}
+
+	r, err := spool.Reader()
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, compressedHeaderSize)
+	copy(header, compressedMagic)
+	header[len(compressedMagic)] = compressionIDs[c.compression]
+	binary.BigEndian.PutUint64(header[len(compressedMagic)+1:], uint64(size))
+	return write(io.MultiReader(bytes.NewReader(header), r))
+}
+
+// documentSize returns uncompressed document size from document header.
+func (c CompressedDocumentStore) documentSize(open func() (io.ReadCloser, error)) (int64, error) {
+	r, err := open()
+	if err != nil {
+		return 0, err
+	}
+
+	defer r.Close()
+	header := make([]byte, compressedHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, err
+	}
+
+	if _, ok := parseCompressedHeader(header); !ok {
+		return 0, fmt.Errorf("document is not compressed")
+	}
+
+	return int64(binary.BigEndian.Uint64(header[len(compressedMagic)+1:])), nil
+}
+
+func newCompressWriter(w io.Writer, compression Compression) (io.WriteCloser, error) {
+	switch compression {
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		return zstd.NewWriter(w)
+	default:
+		return nil, fmt.Errorf("unsupported compression algorithm %q", compression)
+	}
+}
+
+// parseCompressedHeader returns compression algorithm from compressed document header.
+func parseCompressedHeader(header []byte) (Compression, bool) {
+	if len(header) < compressedHeaderSize || !bytes.HasPrefix(header, compressedMagic) {
+		return CompressionNone, false
+	}
+
+	id := header[len(compressedMagic)]
+	for compression, v := range compressionIDs {
+		if v == id {
+			return compression, true
+		}
+	}
+
+	return CompressionNone, false
+}
+
+// decompressReader returns reader which decompresses stored document.
+//
+// Compressed contents are returned as is if compression algorithm is in list of accepted encodings.
+func decompressReader(r io.ReadCloser, acceptEncodings []string) (io.ReadCloser, string, error) {
+	br := bufio.NewReader(r)
+	header, _ := br.Peek(compressedHeaderSize)
+	compression, ok := parseCompressedHeader(header)
+	if !ok {
+		// Document was stored before compression was enabled.
+		return readCloser{Reader: br, closeFn: r.Close}, "", nil
+	}
+
+	if _, err := br.Discard(compressedHeaderSize); err != nil {
+		_ = r.Close()
+		return nil, "", err
+	}
+
+	for _, enc := range acceptEncodings {
+		if enc == string(compression) {
+			return readCloser{Reader: br, closeFn: r.Close}, enc, nil
+		}
+	}
+
+	switch compression {
+	case CompressionGzip:
+		gr, err := gzip.NewReader(br)
+		if err != nil {
+			_ = r.Close()
+			return nil, "", fmt.Errorf("failed to read compressed document: %w", err)
+		}
+
+		return readCloser{Reader: gr, closeFn: r.Close}, "", nil
+	default:
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			_ = r.Close()
+			return nil, "", fmt.Errorf("failed to read compressed document: %w", err)
+		}
+
+		return readCloser{Reader: zr, closeFn: func() error {
+			zr.Close()
+			return r.Close()
+		}}, "", nil
+	}
+}
+
+// readCloser is io.ReadCloser with custom close function.
+type readCloser struct {
+	io.Reader
+	closeFn func() error
+}
+
+func (r readCloser) Close() error {
+	return r.closeFn()
+}
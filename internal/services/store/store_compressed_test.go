@@ -0,0 +1,189 @@
+package store
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressedDocumentStore(t *testing.T) {
+	contents := strings.Repeat("The quick brown fox jumps over the lazy dog. ", 100)
+	cases := map[string]Compression{
+		"gzip": CompressionGzip,
+		"zstd": CompressionZstd,
+	}
+
+	for n, compression := range cases {
+		t.Run(n, func(t *testing.T) {
+			tmpDir, err := ioutil.TempDir(os.TempDir(), "test-store-compressed-*")
+			require.NoError(t, err, "failed to create temp dir")
+			defer func() {
+				assert.NoError(t, os.RemoveAll(tmpDir), "failed to remove temp dir")
+			}()
+
+			ctx := context.TODO()
+			s := NewCompressedDocumentStore(NewFileDocumentStore(tmpDir).WithVersioning(1), compression)
+			require.NoError(t, s.AddDocument(ctx, "doc", strings.NewReader("foo")))
+			require.NoError(t, s.ReplaceDocument(ctx, "doc", strings.NewReader(contents)))
+
+			// Document should be stored compressed
+			raw, err := ioutil.ReadFile(filepath.Join(tmpDir, "doc"))
+			require.NoError(t, err)
+			require.True(t, bytes.HasPrefix(raw, compressedMagic), "missing compressed document header")
+			require.Less(t, len(raw), len(contents), "document is not compressed")
+
+			r, err := s.GetDocument("doc")
+			require.NoError(t, err)
+			got, err := ioutil.ReadAll(r)
+			require.NoError(t, err)
+			require.NoError(t, r.Close())
+			require.Equal(t, contents, string(got))
+
+			// Compressed contents should be returned only if encoding is accepted
+			r, enc, err := s.GetEncodedDocument("doc", []string{"br", string(compression)})
+			require.NoError(t, err)
+			require.Equal(t, string(compression), enc)
+			got, err = ioutil.ReadAll(r)
+			require.NoError(t, err)
+			require.NoError(t, r.Close())
+			require.Equal(t, raw[compressedHeaderSize:], got)
+
+			r, enc, err = s.GetEncodedDocument("doc", []string{"br"})
+			require.NoError(t, err)
+			require.Empty(t, enc)
+			require.NoError(t, r.Close())
+
+			// Sizes should be reported uncompressed
+			list, err := s.List(ctx, ListOptions{})
+			require.NoError(t, err)
+			require.Len(t, list.Items, 1)
+			require.Equal(t, int64(len(contents)), list.Items[0].Size)
+
+			versions, err := s.ListVersions(ctx, "doc")
+			require.NoError(t, err)
+			require.Len(t, versions, 2)
+			require.Equal(t, int64(3), versions[0].Size)
+			require.Equal(t, int64(len(contents)), versions[1].Size)
+
+			r, err = s.GetDocumentVersion("doc", versions[0].Version)
+			require.NoError(t, err)
+			got, err = ioutil.ReadAll(r)
+			require.NoError(t, err)
+			require.NoError(t, r.Close())
+			require.Equal(t, "foo", string(got))
+		})
+	}
+}
+
+func TestCompressedDocumentStore_Uncompressed(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "test-store-compressed-*")
+	require.NoError(t, err, "failed to create temp dir")
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tmpDir), "failed to remove temp dir")
+	}()
+
+	// Document stored before compression was enabled, including gzip file uploaded as is.
+	var gzipped bytes.Buffer
+	w := gzip.NewWriter(&gzipped)
+	_, err = w.Write([]byte("foo"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	ctx := context.TODO()
+	fileStore := NewFileDocumentStore(tmpDir)
+	require.NoError(t, fileStore.AddDocument(ctx, "plain", strings.NewReader("foo")))
+	require.NoError(t, fileStore.AddDocument(ctx, "archive", bytes.NewReader(gzipped.Bytes())))
+
+	s := NewCompressedDocumentStore(fileStore, CompressionGzip)
+	cases := map[string][]byte{
+		"plain":   []byte("foo"),
+		"archive": gzipped.Bytes(),
+	}
+	for name, want := range cases {
+		r, enc, err := s.GetEncodedDocument(name, []string{"gzip"})
+		require.NoError(t, err)
+		require.Empty(t, enc, name)
+		got, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		require.Equal(t, want, got, name)
+	}
+
+	list, err := s.List(ctx, ListOptions{SortBy: SortByName})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 2)
+	require.Equal(t, int64(gzipped.Len()), list.Items[0].Size)
+	require.Equal(t, int64(3), list.Items[1].Size)
+}
+
+func TestCompressedDocumentStore_ListBySize(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "test-store-compressed-*")
+	require.NoError(t, err, "failed to create temp dir")
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tmpDir), "failed to remove temp dir")
+	}()
+
+	// Compressed size order differs from uncompressed size order.
+	random := make([]byte, 100)
+	_, err = rand.Read(random)
+	require.NoError(t, err)
+	docs := map[string][]byte{
+		"random":   random,
+		"repeated": bytes.Repeat([]byte("a"), 1000),
+		"small":    []byte("foo"),
+	}
+
+	ctx := context.TODO()
+	s := NewCompressedDocumentStore(NewFileDocumentStore(tmpDir), CompressionGzip)
+	for name, data := range docs {
+		require.NoError(t, s.AddDocument(ctx, name, bytes.NewReader(data)))
+	}
+
+	for _, desc := range []bool{false, true} {
+		var (
+			got    []DocumentInfo
+			cursor string
+		)
+		for {
+			page, err := s.List(ctx, ListOptions{SortBy: SortBySize, Desc: desc, Cursor: cursor, Limit: 1})
+			require.NoError(t, err)
+			got = append(got, page.Items...)
+			if page.NextCursor == "" {
+				break
+			}
+
+			cursor = page.NextCursor
+		}
+
+		want := []string{"small", "random", "repeated"}
+		if desc {
+			want = []string{"repeated", "random", "small"}
+		}
+
+		require.Len(t, got, len(want))
+		for i, item := range got {
+			require.Equal(t, want[i], item.Name, "desc: %t", desc)
+			require.Equal(t, int64(len(docs[item.Name])), item.Size)
+		}
+	}
+}
+
+func TestParseCompression(t *testing.T) {
+	for _, v := range []string{"", "gzip", "zstd"} {
+		got, err := ParseCompression(v)
+		require.NoError(t, err)
+		require.Equal(t, Compression(v), got)
+	}
+
+	_, err := ParseCompression("brotli")
+	require.Error(t, err)
+}
@@ -0,0 +1,256 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/x1unix/docusearch/internal/services/lock"
+)
+
+// BlobLockPrefix is lock key prefix used to serialize operations on the same blob.
+const BlobLockPrefix = "blob:"
+
+// BlobRef links a document to content blob.
+type BlobRef struct {
+	// Hash is hex-encoded SHA-256 checksum of blob contents.
+	Hash string `json:"hash"`
+
+	// Size is blob size in bytes.
+	Size int64 `json:"size"`
+
+	// CreatedAt is document write time.
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// BlobRefStore keeps document to blob references and blob reference counters.
+type BlobRefStore interface {
+	// GetRef returns document blob reference.
+	//
+	// Should return fs.ErrNotExist if document doesn't exist.
+	GetRef(ctx context.Context, name string) (*BlobRef, error)
+
+	// SetRef creates or replaces document blob reference.
+	SetRef(ctx context.Context, name string, ref BlobRef) error
+
+	// RemoveRef removes document blob reference.
+	RemoveRef(ctx context.Context, name string) error
+
+	// ListRefs returns all document references.
+	ListRefs(ctx context.Context) (map[string]BlobRef, error)
+
+	// IncrBlobRefs increments number of blob references and returns a new value.
+	IncrBlobRefs(ctx context.Context, hash string) (int64, error)
+
+	// DecrBlobRefs decrements number of blob references and returns a new value.
+	//
+	// Counter is removed when it reaches zero.
+	DecrBlobRefs(ctx context.Context, hash string) (int64, error)
+}
+
+// DedupDocumentStore is content-addressed document storage.
+//
+// Document contents are stored once per unique SHA-256 checksum in blob storage
+// and each document name references a blob. Blob is removed when the last
+// document referencing it is removed.
+//
+// Document versioning is not supported, only current document version is available.
+type DedupDocumentStore struct {
+	blobs  DocumentStore
+	refs   BlobRefStore
+	locker lock.Locker
+}
+
+// NewDedupDocumentStore constructs a new deduplicating document store.
+//
+// Blobs are stored in blobs storage using checksum as name.
+// Locker is used to serialize reference counting of the same blob.
+func NewDedupDocumentStore(blobs DocumentStore, refs BlobRefStore, locker lock.Locker) *DedupDocumentStore {
+	return &DedupDocumentStore{blobs: blobs, refs: refs, locker: locker}
+}
+
+// AddDocument implements DocumentStore
+func (d DedupDocumentStore) AddDocument(ctx context.Context, name string, data io.Reader) error {
+	_, err := d.refs.GetRef(ctx, name)
+	if err == nil {
+		return fs.ErrExist
+	}
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to get document reference: %w", err)
+	}
+
+	return d.writeDocument(ctx, name, data)
+}
+
+// ReplaceDocument implements DocumentStore
+func (d DedupDocumentStore) ReplaceDocument(ctx context.Context, name string, data io.Reader) error {
+	return d.writeDocument(ctx, name, data)
+}
+
+// RemoveDocument implements DocumentStore
+func (d DedupDocumentStore) RemoveDocument(ctx context.Context, name string) error {
+	ref, err := d.refs.GetRef(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if err := d.refs.RemoveRef(ctx, name); err != nil {
+		return fmt.Errorf("failed to remove document reference: %w", err)
+	}
+
+	return d.releaseBlob(ctx, ref.Hash)
+}
+
+// GetDocument implements DocumentStore
+func (d DedupDocumentStore) GetDocument(name string) (io.ReadCloser, error) {
+	ref, err := d.refs.GetRef(context.Background(), name)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.blobs.GetDocument(ref.Hash)
+}
+
+// List implements DocumentStore
+func (d DedupDocumentStore) List(ctx context.Context, opts ListOptions) (*ListResult, error) {
+	refs, err := d.refs.ListRefs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list document references: %w", err)
+	}
+
+	items := make([]DocumentInfo, 0, len(refs))
+	for name, ref := range refs {
+		if !strings.HasPrefix(name, opts.Prefix) {
+			continue
+		}
+
+		items = append(items, DocumentInfo{Name: name, Size: ref.Size, UploadedAt: ref.CreatedAt})
+	}
+
+	return paginateDocuments(items, opts)
+}
+
+// ListVersions implements DocumentStore
+func (d DedupDocumentStore) ListVersions(ctx context.Context, name string) ([]VersionInfo, error) {
+	ref, err := d.refs.GetRef(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return []VersionInfo{{Version: 1, Size: ref.Size, CreatedAt: ref.CreatedAt}}, nil
+}
+
+// GetDocumentVersion implements DocumentStore
+func (d DedupDocumentStore) GetDocumentVersion(name string, version int) (io.ReadCloser, error) {
+	if version != 1 {
+		return nil, fs.ErrNotExist
+	}
+
+	return d.GetDocument(name)
+}
+
+// writeDocument stores document blob and points document reference to it.
+func (d DedupDocumentStore) writeDocument(ctx context.Context, name string, data io.Reader) error {
+	// Contents are spooled to a temporary file as checksum is required before blob write.
+	spool, err := newSpoolFile("docusearch-blob-*")
+	if err != nil {
+		return err
+	}
+
+	defer spool.Close() //nolint:errcheck
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(spool, hash), data)
+	if err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	spooled, err := spool.Reader()
+	if err != nil {
+		return err
+	}
+
+	ref := BlobRef{
+		Hash:      hex.EncodeToString(hash.Sum(nil)),
+		Size:      size,
+		CreatedAt: time.Now(),
+	}
+
+	if err := d.acquireBlob(ctx, ref.Hash, spooled); err != nil {
+		return err
+	}
+
+	prevRef, err := d.refs.GetRef(ctx, name)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		_ = d.releaseBlob(ctx, ref.Hash)
+		return fmt.Errorf("failed to get document reference: %w", err)
+	}
+
+	if err := d.refs.SetRef(ctx, name, ref); err != nil {
+		_ = d.releaseBlob(ctx, ref.Hash)
+		return fmt.Errorf("failed to save document reference: %w", err)
+	}
+
+	if prevRef == nil {
+		return nil
+	}
+
+	return d.releaseBlob(ctx, prevRef.Hash)
+}
+
+// acquireBlob increments blob references counter and stores blob if it's new.
+func (d DedupDocumentStore) acquireBlob(ctx context.Context, hash string, data io.Reader) error {
+	unlock, err := d.locker.Lock(ctx, BlobLockPrefix+hash)
+	if err != nil {
+		return fmt.Errorf("failed to acquire blob lock: %w", err)
+	}
+
+	defer unlock() //nolint:errcheck
+	refCount, err := d.refs.IncrBlobRefs(ctx, hash)
+	if err != nil {
+		return fmt.Errorf("failed to update blob references: %w", err)
+	}
+
+	if refCount > 1 {
+		// Blob with the same contents already exists.
+		return nil
+	}
+
+	if err := d.blobs.ReplaceDocument(ctx, hash, data); err != nil {
+		_, _ = d.refs.DecrBlobRefs(ctx, hash)
+		return fmt.Errorf("failed to store blob: %w", err)
+	}
+
+	return nil
+}
+
+// releaseBlob decrements blob references counter and removes blob if it's not referenced anymore.
+func (d DedupDocumentStore) releaseBlob(ctx context.Context, hash string) error {
+	unlock, err := d.locker.Lock(ctx, BlobLockPrefix+hash)
+	if err != nil {
+		return fmt.Errorf("failed to acquire blob lock: %w", err)
+	}
+
+	defer unlock() //nolint:errcheck
+	refCount, err := d.refs.DecrBlobRefs(ctx, hash)
+	if err != nil {
+		return fmt.Errorf("failed to update blob references: %w", err)
+	}
+
+	if refCount > 0 {
+		return nil
+	}
+
+	if err := d.blobs.RemoveDocument(ctx, hash); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove blob: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package store
+
+import (
+	"context"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/x1unix/docusearch/internal/services/extract"
+	"github.com/x1unix/docusearch/internal/services/lock"
+	"github.com/x1unix/docusearch/internal/services/search"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestDedupDocumentStore(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "test-store-dedup-*")
+	require.NoError(t, err, "failed to create temp dir")
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tmpDir), "failed to remove temp dir")
+	}()
+
+	srv := miniredis.RunT(t)
+	conn := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	defer conn.Close()
+
+	ctx := context.TODO()
+	s := NewDedupDocumentStore(NewFileDocumentStore(tmpDir), NewRedisBlobRefStore(conn), lock.NewMemoryLocker())
+	assertBlobs := func(t *testing.T, want int) {
+		t.Helper()
+		entries, err := os.ReadDir(tmpDir)
+		require.NoError(t, err)
+		var got int
+		for _, e := range entries {
+			if !e.IsDir() {
+				got++
+			}
+		}
+		require.Equal(t, want, got, "blobs count mismatch")
+	}
+	assertContents := func(t *testing.T, name, want string) {
+		t.Helper()
+		r, err := s.GetDocument(name)
+		require.NoError(t, err)
+		defer r.Close()
+		got, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, want, string(got))
+	}
+
+	// Identical contents should be stored once
+	require.NoError(t, s.AddDocument(ctx, "a", strings.NewReader("foo")))
+	require.NoError(t, s.AddDocument(ctx, "b", strings.NewReader("foo")))
+	require.ErrorIs(t, s.AddDocument(ctx, "a", strings.NewReader("bar")), fs.ErrExist)
+	assertBlobs(t, 1)
+	assertContents(t, "a", "foo")
+	assertContents(t, "b", "foo")
+	_, err = os.Stat(filepath.Join(tmpDir, "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"))
+	require.NoError(t, err, "blob should be named by checksum")
+
+	result, err := s.List(ctx, ListOptions{SortBy: SortByName})
+	require.NoError(t, err)
+	require.Len(t, result.Items, 2)
+	require.Equal(t, "a", result.Items[0].Name)
+	require.Equal(t, int64(3), result.Items[0].Size)
+
+	// Replaced document should release previous blob only for itself
+	require.NoError(t, s.ReplaceDocument(ctx, "a", strings.NewReader("bar")))
+	assertBlobs(t, 2)
+	assertContents(t, "a", "bar")
+	assertContents(t, "b", "foo")
+
+	// Replace with the same contents shouldn't drop blob
+	require.NoError(t, s.ReplaceDocument(ctx, "a", strings.NewReader("bar")))
+	assertBlobs(t, 2)
+	assertContents(t, "a", "bar")
+
+	// Blob should be removed with the last reference
+	require.NoError(t, s.RemoveDocument(ctx, "a"))
+	require.ErrorIs(t, s.RemoveDocument(ctx, "a"), fs.ErrNotExist)
+	_, err = s.GetDocument("a")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	assertBlobs(t, 1)
+
+	require.NoError(t, s.AddDocument(ctx, "c", strings.NewReader("foo")))
+	require.NoError(t, s.RemoveDocument(ctx, "b"))
+	assertBlobs(t, 1)
+	assertContents(t, "c", "foo")
+	require.NoError(t, s.RemoveDocument(ctx, "c"))
+	assertBlobs(t, 0)
+	require.False(t, srv.Exists(blobCountsKey), "reference counters should be removed")
+}
+
+func TestSyncedDocumentStore_DedupContent(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "test-store-dedup-*")
+	require.NoError(t, err, "failed to create temp dir")
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tmpDir), "failed to remove temp dir")
+	}()
+
+	srv := miniredis.RunT(t)
+	conn := redis.NewClient(&redis.Options{Addr: srv.Addr()})
+	defer conn.Close()
+
+	ctx := context.TODO()
+	locker := lock.NewMemoryLocker()
+	dedupStore := NewDedupDocumentStore(NewFileDocumentStore(tmpDir), NewRedisBlobRefStore(conn), locker)
+	searchProvider := search.NewRedisProvider(zaptest.NewLogger(t), conn)
+	s := NewSyncedDocumentStore(zaptest.NewLogger(t), dedupStore, NewRedisMetadataStore(conn),
+		searchProvider, locker, TextIndexConfig{DedupContent: true})
+
+	var extracted int
+	s.extractor.Register("text/x-test", extract.ExtractorFunc(func(data []byte) (*extract.Content, error) {
+		extracted++
+		return &extract.Content{Text: string(data)}, nil
+	}))
+
+	opts := WriteOptions{ContentType: "text/x-test"}
+	assertFound := func(t *testing.T, word string, want ...string) {
+		t.Helper()
+		got, err := searchProvider.SearchDocumentsByWord(ctx, word)
+		require.NoError(t, err)
+		require.ElementsMatch(t, want, got)
+	}
+
+	// Identical contents should be indexed once
+	_, err = s.AddDocument(ctx, "a", strings.NewReader("foo"), opts)
+	require.NoError(t, err)
+	_, err = s.AddDocument(ctx, "b", strings.NewReader("foo"), opts)
+	require.NoError(t, err)
+	require.Equal(t, 1, extracted)
+	assertFound(t, "foo", "a", "b")
+
+	// Identical contents of other type are indexed separately
+	_, err = s.AddDocument(ctx, "c.txt", strings.NewReader("foo"), WriteOptions{})
+	require.NoError(t, err)
+	assertFound(t, "foo", "a", "b", "c.txt")
+
+	_, err = s.ReplaceDocument(ctx, "a", strings.NewReader("bar"), opts)
+	require.NoError(t, err)
+	require.Equal(t, 2, extracted)
+	assertFound(t, "foo", "b", "c.txt")
+	assertFound(t, "bar", "a")
+
+	require.NoError(t, s.RemoveDocument(ctx, "b", Precondition{}))
+	assertFound(t, "foo", "c.txt")
+
+	// Removed content should be indexed again
+	_, err = s.AddDocument(ctx, "b", strings.NewReader("foo"), opts)
+	require.NoError(t, err)
+	require.Equal(t, 3, extracted)
+	assertFound(t, "foo", "b", "c.txt")
+}
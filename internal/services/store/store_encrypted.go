@@ -0,0 +1,512 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+
+	"github.com/x1unix/docusearch/internal/services/lock"
+)
+
+// encryptedMagic is signature of encrypted document header.
+//
+// Header consists of signature, format version, key ID length, key ID and document key salt.
+// Header is authenticated as additional data of each chunk.
+var encryptedMagic = []byte("\x89DSE")
+
+const (
+	encryptedFormatVersion = 2
+
+	// encryptedChunkSize is size of plain text chunk sealed separately.
+	encryptedChunkSize = 64 * 1024
+
+	// documentSaltSize is size of random salt used to derive document key.
+	documentSaltSize = 32
+
+	// chunkNonceSize is size of chunk nonce, which consists of chunk counter and last chunk flag.
+	//
+	// Nonce is unique as each document is encrypted by a separate key.
+	chunkNonceSize = 12
+
+	// reencryptBatchSize is number of documents processed per listing page during re-encryption.
+	reencryptBatchSize = 100
+)
+
+var (
+	// ErrDecryptionFailed is returned when document is corrupted or can't be decrypted by a key.
+	ErrDecryptionFailed = errors.New("failed to decrypt document: message authentication failed")
+
+	// ErrRewriteNotSupported is returned when document storage doesn't support contents rewrite.
+	ErrRewriteNotSupported = errors.New("document storage doesn't support documents rewrite")
+)
+
+// DocumentRewriter is implemented by document stores which allow to rewrite stored revisions in place.
+type DocumentRewriter interface {
+	// RewriteDocument replaces contents of a document revision without creating a new revision.
+	//
+	// Revision modification time is preserved.
+	// Should return fs.ErrNotExist if item or revision doesn't exist.
+	RewriteDocument(ctx context.Context, name string, version int, data io.Reader) error
+}
+
+// EncryptedDocumentStore is document storage wrapper which encrypts documents at rest.
+//
+// Documents are split into chunks sealed with AES-GCM, so documents of any size
+// are encrypted and decrypted as a stream. Each document header contains ID of a key
+// used for encryption, which allows key rotation, and a random salt used to derive document key.
+//
+// Documents stored before encryption was enabled are returned as is.
+type EncryptedDocumentStore struct {
+	store DocumentStore
+	keys  *KeyRing
+}
+
+// NewEncryptedDocumentStore wraps document store with encryption using keys from key ring.
+func NewEncryptedDocumentStore(store DocumentStore, keys *KeyRing) *EncryptedDocumentStore {
+	return &EncryptedDocumentStore{store: store, keys: keys}
+}
+
+// AddDocument implements DocumentStore
+func (e EncryptedDocumentStore) AddDocument(ctx context.Context, name string, data io.Reader) error {
+	r, err := e.encryptReader(data)
+	if err != nil {
+		return err
+	}
+
+	return e.store.AddDocument(ctx, name, r)
+}
+
+// ReplaceDocument implements DocumentStore
+func (e EncryptedDocumentStore) ReplaceDocument(ctx context.Context, name string, data io.Reader) error {
+	r, err := e.encryptReader(data)
+	if err != nil {
+		return err
+	}
+
+	return e.store.ReplaceDocument(ctx, name, r)
+}
+
+// RemoveDocument implements DocumentStore
+func (e EncryptedDocumentStore) RemoveDocument(ctx context.Context, name string) error {
+	return e.store.RemoveDocument(ctx, name)
+}
+
+// GetDocument implements DocumentStore
+func (e EncryptedDocumentStore) GetDocument(name string) (io.ReadCloser, error) {
+	r, err := e.store.GetDocument(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.decryptReader(r)
+}
+
+// List implements DocumentStore
+func (e EncryptedDocumentStore) List(ctx context.Context, opts ListOptions) (*ListResult, error) {
+	return listDocumentSizes(ctx, e.store, opts, func(item DocumentInfo) (int64, error) {
+		headerSize, err := e.headerSize(func() (io.ReadCloser, error) {
+			return e.store.GetDocument(item.Name)
+		})
+		if err != nil {
+			return 0, err
+		}
+
+		return plainTextSize(item.Size, headerSize), nil
+	})
+}
+
+// ListVersions implements DocumentStore
+func (e EncryptedDocumentStore) ListVersions(ctx context.Context, name string) ([]VersionInfo, error) {
+	versions, err := e.store.ListVersions(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, v := range versions {
+		headerSize, err := e.headerSize(func() (io.ReadCloser, error) {
+			return e.store.GetDocumentVersion(name, v.Version)
+		})
+		if err != nil {
+			continue
+		}
+
+		versions[i].Size = plainTextSize(v.Size, headerSize)
+	}
+
+	return versions, nil
+}
+
+// GetDocumentVersion implements DocumentStore
+func (e EncryptedDocumentStore) GetDocumentVersion(name string, version int) (io.ReadCloser, error) {
+	r, err := e.store.GetDocumentVersion(name, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.decryptReader(r)
+}
+
+// ReencryptDocument encrypts all document revisions which are not encrypted using the active key.
+//
+// Underlying storage should implement DocumentRewriter.
+// Returns number of rewritten revisions.
+func (e EncryptedDocumentStore) ReencryptDocument(ctx context.Context, name string) (int, error) {
+	rw, ok := e.store.(DocumentRewriter)
+	if !ok {
+		return 0, ErrRewriteNotSupported
+	}
+
+	versions, err := e.store.ListVersions(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+
+	var rewritten int
+	for _, v := range versions {
+		ok, err := e.reencryptVersion(ctx, rw, name, v.Version)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				// Version was removed by retention policy.
+				continue
+			}
+
+			return rewritten, fmt.Errorf("failed to re-encrypt version %d: %w", v.Version, err)
+		}
+
+		if ok {
+			rewritten++
+		}
+	}
+
+	return rewritten, nil
+}
+
+// ReencryptAll re-encrypts all stored documents using the active key.
+//
+// Each document is locked using lockPrefix and document name as a key
+// to avoid conflicts with concurrent writes.
+// Returns number of rewritten revisions.
+func (e EncryptedDocumentStore) ReencryptAll(ctx context.Context, locker lock.Locker, lockPrefix string) (int, error) {
+	var (
+		rewritten int
+		cursor    string
+	)
+
+	for {
+		page, err := e.store.List(ctx, ListOptions{
+			SortBy: SortByName,
+			Cursor: cursor,
+			Limit:  reencryptBatchSize,
+		})
+		if err != nil {
+			return rewritten, fmt.Errorf("failed to list documents: %w", err)
+		}
+
+		for _, item := range page.Items {
+			n, err := e.reencryptLocked(ctx, locker, lockPrefix+item.Name, item.Name)
+			rewritten += n
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return rewritten, fmt.Errorf("failed to re-encrypt document %q: %w", item.Name, err)
+			}
+		}
+
+		if page.NextCursor == "" {
+			return rewritten, nil
+		}
+
+		cursor = page.NextCursor
+	}
+}
+
+func (e EncryptedDocumentStore) reencryptLocked(ctx context.Context, locker lock.Locker, key, name string) (int, error) {
+	unlock, err := locker.Lock(ctx, key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to acquire document lock: %w", err)
+	}
+
+	defer unlock() //nolint:errcheck
+	return e.ReencryptDocument(ctx, name)
+}
+
+// reencryptVersion rewrites document revision if it's not encrypted using the active key.
+func (e EncryptedDocumentStore) reencryptVersion(ctx context.Context, rw DocumentRewriter, name string, version int) (bool, error) {
+	r, err := e.store.GetDocumentVersion(name, version)
+	if err != nil {
+		return false, err
+	}
+
+	br := bufio.NewReader(r)
+	header, err := readEncryptedHeader(br)
+	if err != nil {
+		_ = r.Close()
+		return false, err
+	}
+
+	if header != nil && header.keyID == e.keys.ActiveKey() {
+		return false, r.Close()
+	}
+
+	plain := readCloser{Reader: br, closeFn: r.Close}
+	if header != nil {
+		plain, err = e.openReader(br, header, r.Close)
+		if err != nil {
+			_ = r.Close()
+			return false, err
+		}
+	}
+
+	defer plain.Close()
+	data, err := e.encryptReader(plain)
+	if err != nil {
+		return false, err
+	}
+
+	if err := rw.RewriteDocument(ctx, name, version, data); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// headerSize returns encrypted document header size.
+func (e EncryptedDocumentStore) headerSize(open func() (io.ReadCloser, error)) (int, error) {
+	r, err := open()
+	if err != nil {
+		return 0, err
+	}
+
+	defer r.Close()
+	header, err := readEncryptedHeader(bufio.NewReader(r))
+	if err != nil {
+		return 0, err
+	}
+
+	if header == nil {
+		return 0, nil
+	}
+
+	return len(header.raw), nil
+}
+
+// encryptReader returns reader which encrypts data using the active key.
+func (e EncryptedDocumentStore) encryptReader(data io.Reader) (io.Reader, error) {
+	keyID := e.keys.ActiveKey()
+	if keyID == "" {
+		return nil, errors.New("encryption key is not set")
+	}
+
+	salt := make([]byte, documentSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate document key salt: %w", err)
+	}
+
+	aead, err := e.keys.documentCipher(keyID, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, 0, len(encryptedMagic)+2+len(keyID)+documentSaltSize)
+	raw = append(raw, encryptedMagic...)
+	raw = append(raw, encryptedFormatVersion, byte(len(keyID)))
+	raw = append(raw, keyID...)
+	raw = append(raw, salt...)
+	return &chunkedReader{
+		src:    bufio.NewReaderSize(data, encryptedChunkSize),
+		aead:   aead,
+		header: &encryptedHeader{raw: raw, keyID: keyID, salt: salt},
+		buff:   make([]byte, encryptedChunkSize, encryptedChunkSize+aead.Overhead()),
+		out:    raw,
+		seal:   true,
+	}, nil
+}
+
+// decryptReader returns reader which decrypts stored document.
+func (e EncryptedDocumentStore) decryptReader(r io.ReadCloser) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	header, err := readEncryptedHeader(br)
+	if err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+
+	if header == nil {
+		// Document was stored before encryption was enabled.
+		return readCloser{Reader: br, closeFn: r.Close}, nil
+	}
+
+	dr, err := e.openReader(br, header, r.Close)
+	if err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+
+	return dr, nil
+}
+
+func (e EncryptedDocumentStore) openReader(r io.Reader, header *encryptedHeader, closeFn func() error) (readCloser, error) {
+	aead, err := e.keys.documentCipher(header.keyID, header.salt)
+	if err != nil {
+		return readCloser{}, err
+	}
+
+	chunkSize := encryptedChunkSize + aead.Overhead()
+	return readCloser{
+		Reader: &chunkedReader{
+			src:    bufio.NewReaderSize(r, chunkSize),
+			aead:   aead,
+			header: header,
+			buff:   make([]byte, chunkSize),
+		},
+		closeFn: closeFn,
+	}, nil
+}
+
+// encryptedHeader is parsed encrypted document header.
+type encryptedHeader struct {
+	raw   []byte
+	keyID string
+	salt  []byte
+}
+
+// readEncryptedHeader reads encrypted document header.
+//
+// Returns nil header without consuming data if document is not encrypted.
+func readEncryptedHeader(r *bufio.Reader) (*encryptedHeader, error) {
+	fixedSize := len(encryptedMagic) + 2
+	prefix, _ := r.Peek(fixedSize)
+	if len(prefix) < fixedSize || !bytes.HasPrefix(prefix, encryptedMagic) {
+		return nil, nil
+	}
+
+	if v := prefix[len(encryptedMagic)]; v != encryptedFormatVersion {
+		return nil, fmt.Errorf("unsupported encrypted document format version %d", v)
+	}
+
+	keyIDLen := int(prefix[len(encryptedMagic)+1])
+	raw := make([]byte, fixedSize+keyIDLen+documentSaltSize)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return nil, fmt.Errorf("failed to read encrypted document header: %w", err)
+	}
+
+	return &encryptedHeader{
+		raw:   raw,
+		keyID: string(raw[fixedSize : fixedSize+keyIDLen]),
+		salt:  raw[fixedSize+keyIDLen:],
+	}, nil
+}
+
+// chunkNonce returns nonce of a chunk.
+func chunkNonce(counter uint32, last bool) []byte {
+	nonce := make([]byte, chunkNonceSize)
+	binary.BigEndian.PutUint32(nonce[chunkNonceSize-5:], counter)
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+
+	return nonce
+}
+
+// chunkedReader encrypts or decrypts a stream in chunks.
+//
+// The last chunk is sealed with a different nonce, so truncated document can't be decrypted.
+type chunkedReader struct {
+	src     *bufio.Reader
+	aead    cipher.AEAD
+	header  *encryptedHeader
+	buff    []byte
+	out     []byte
+	counter uint32
+	seal    bool
+	done    bool
+}
+
+// Read implements io.Reader
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	for len(c.out) == 0 {
+		if c.done {
+			return 0, io.EOF
+		}
+
+		if err := c.nextChunk(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, c.out)
+	c.out = c.out[n:]
+	return n, nil
+}
+
+func (c *chunkedReader) nextChunk() error {
+	n, err := io.ReadFull(c.src, c.buff[:cap(c.buff)-c.overhead()])
+	switch err {
+	case nil:
+		_, err = c.src.Peek(1)
+		c.done = err == io.EOF
+		if err != nil && err != io.EOF {
+			return err
+		}
+	case io.EOF, io.ErrUnexpectedEOF:
+		c.done = true
+		if !c.seal && n == 0 {
+			// Sealed chunk can't be empty, so the last chunk is missing.
+			return ErrDecryptionFailed
+		}
+	default:
+		return err
+	}
+
+	if c.counter == math.MaxUint32 {
+		return errors.New("encrypted document is too large")
+	}
+
+	nonce := chunkNonce(c.counter, c.done)
+	c.counter++
+	if c.seal {
+		c.out = c.aead.Seal(c.buff[:0], nonce, c.buff[:n], c.header.raw)
+		return nil
+	}
+
+	c.out, err = c.aead.Open(c.buff[:0], nonce, c.buff[:n], c.header.raw)
+	if err != nil {
+		return ErrDecryptionFailed
+	}
+
+	return nil
+}
+
+// overhead returns difference between sealed and plain text chunk size.
+func (c *chunkedReader) overhead() int {
+	if c.seal {
+		return c.aead.Overhead()
+	}
+
+	return 0
+}
+
+// plainTextSize returns document size without encryption overhead.
+func plainTextSize(size int64, headerSize int) int64 {
+	if headerSize == 0 {
+		return size
+	}
+
+	chunkSize := int64(encryptedChunkSize + aesGCMOverhead)
+	body := size - int64(headerSize)
+	chunks := body / chunkSize
+	if body%chunkSize != 0 || chunks == 0 {
+		chunks++
+	}
+
+	return body - chunks*aesGCMOverhead
+}
+
+// aesGCMOverhead is AES-GCM authentication tag size.
+const aesGCMOverhead = 16
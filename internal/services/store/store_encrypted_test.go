@@ -0,0 +1,265 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base64"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/x1unix/docusearch/internal/services/lock"
+)
+
+func newTestKeyRing(t *testing.T, ids ...string) *KeyRing {
+	t.Helper()
+	keys := NewKeyRing()
+	for _, id := range ids {
+		// Key is derived from ID, so the same key can be added to different key rings.
+		key := sha256.Sum256([]byte(id))
+		require.NoError(t, keys.AddKey(id, key[:]))
+	}
+
+	return keys
+}
+
+func readDocument(t *testing.T, s DocumentStore, name string) []byte {
+	t.Helper()
+	r, err := s.GetDocument(name)
+	require.NoError(t, err)
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	return data
+}
+
+func TestEncryptedDocumentStore(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	cases := map[string]int{
+		"empty":          0,
+		"small":          100,
+		"single chunk":   encryptedChunkSize,
+		"multiple chunk": encryptedChunkSize*2 + 10,
+		"exact chunks":   encryptedChunkSize * 3,
+	}
+
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "test-store-encrypted-*")
+	require.NoError(t, err, "failed to create temp dir")
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tmpDir), "failed to remove temp dir")
+	}()
+
+	ctx := context.TODO()
+	s := NewEncryptedDocumentStore(NewFileDocumentStore(tmpDir), newTestKeyRing(t, "k1"))
+	for n, size := range cases {
+		t.Run(n, func(t *testing.T) {
+			contents := make([]byte, size)
+			rnd.Read(contents)
+			require.NoError(t, s.AddDocument(ctx, n, bytes.NewReader(contents)))
+
+			raw, err := ioutil.ReadFile(filepath.Join(tmpDir, n))
+			require.NoError(t, err)
+			require.True(t, bytes.HasPrefix(raw, encryptedMagic), "missing encrypted document header")
+			if size > 0 {
+				require.NotContains(t, string(raw), string(contents))
+			}
+
+			require.Equal(t, contents, readDocument(t, s, n))
+
+			list, err := s.List(ctx, ListOptions{Prefix: n})
+			require.NoError(t, err)
+			require.Len(t, list.Items, 1)
+			require.Equal(t, int64(size), list.Items[0].Size)
+		})
+	}
+}
+
+func TestEncryptedDocumentStore_DocumentKey(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "test-store-encrypted-*")
+	require.NoError(t, err, "failed to create temp dir")
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tmpDir), "failed to remove temp dir")
+	}()
+
+	ctx := context.TODO()
+	s := NewEncryptedDocumentStore(NewFileDocumentStore(tmpDir), newTestKeyRing(t, "k1"))
+	var salts [][]byte
+	for _, name := range []string{"a", "b"} {
+		require.NoError(t, s.AddDocument(ctx, name, strings.NewReader("contents")))
+		raw, err := ioutil.ReadFile(filepath.Join(tmpDir, name))
+		require.NoError(t, err)
+
+		// Header carries a salt of document key
+		header, err := readEncryptedHeader(bufio.NewReader(bytes.NewReader(raw)))
+		require.NoError(t, err)
+		require.NotNil(t, header)
+		require.Equal(t, "k1", header.keyID)
+		require.Len(t, header.salt, documentSaltSize)
+		require.Equal(t, header.salt, raw[len(header.raw)-documentSaltSize:len(header.raw)])
+		require.NotEqual(t, make([]byte, documentSaltSize), header.salt)
+		salts = append(salts, header.salt)
+
+		// Key ring key is not used to encrypt document directly
+		key := sha256.Sum256([]byte("k1"))
+		block, err := aes.NewCipher(key[:])
+		require.NoError(t, err)
+		aead, err := cipher.NewGCM(block)
+		require.NoError(t, err)
+		_, err = aead.Open(nil, chunkNonce(0, true), raw[len(header.raw):], header.raw)
+		require.Error(t, err)
+		require.Equal(t, []byte("contents"), readDocument(t, s, name))
+	}
+
+	require.NotEqual(t, salts[0], salts[1], "documents should be encrypted with different keys")
+}
+
+func TestEncryptedDocumentStore_Tampering(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "test-store-encrypted-*")
+	require.NoError(t, err, "failed to create temp dir")
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tmpDir), "failed to remove temp dir")
+	}()
+
+	ctx := context.TODO()
+	fileStore := NewFileDocumentStore(tmpDir)
+	s := NewEncryptedDocumentStore(fileStore, newTestKeyRing(t, "k1"))
+	contents := strings.Repeat("a", encryptedChunkSize*2)
+	require.NoError(t, s.AddDocument(ctx, "doc", strings.NewReader(contents)))
+	raw, err := ioutil.ReadFile(filepath.Join(tmpDir, "doc"))
+	require.NoError(t, err)
+
+	cases := map[string][]byte{
+		"truncated at chunk boundary": raw[:len(raw)-encryptedChunkSize-aesGCMOverhead],
+		"truncated":                   raw[:len(raw)-10],
+		"modified":                    append(append([]byte{}, raw[:100]...), append([]byte{raw[100] ^ 1}, raw[101:]...)...),
+	}
+
+	for n, data := range cases {
+		t.Run(n, func(t *testing.T) {
+			require.NoError(t, fileStore.ReplaceDocument(ctx, "doc", bytes.NewReader(data)))
+			r, err := s.GetDocument("doc")
+			require.NoError(t, err)
+			defer r.Close()
+			_, err = ioutil.ReadAll(r)
+			require.ErrorIs(t, err, ErrDecryptionFailed)
+		})
+	}
+
+	// Document encrypted with removed key can't be read
+	require.NoError(t, fileStore.ReplaceDocument(ctx, "doc", bytes.NewReader(raw)))
+	_, err = NewEncryptedDocumentStore(fileStore, newTestKeyRing(t, "k2")).GetDocument("doc")
+	require.EqualError(t, err, `document is encrypted with unknown key "k1"`)
+}
+
+func TestEncryptedDocumentStore_Reencrypt(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "test-store-encrypted-*")
+	require.NoError(t, err, "failed to create temp dir")
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tmpDir), "failed to remove temp dir")
+	}()
+
+	ctx := context.TODO()
+	fileStore := NewFileDocumentStore(tmpDir).WithVersioning(2)
+	require.NoError(t, fileStore.AddDocument(ctx, "legacy", strings.NewReader("plain")))
+
+	oldStore := NewEncryptedDocumentStore(fileStore, newTestKeyRing(t, "old"))
+	require.NoError(t, oldStore.AddDocument(ctx, "doc", strings.NewReader("v1")))
+	require.NoError(t, oldStore.ReplaceDocument(ctx, "doc", strings.NewReader("v2")))
+	require.Equal(t, []byte("plain"), readDocument(t, oldStore, "legacy"))
+
+	versionsBefore, err := fileStore.ListVersions(ctx, "doc")
+	require.NoError(t, err)
+
+	// Rotate key, old key is still available for reads
+	s := NewEncryptedDocumentStore(fileStore, newTestKeyRing(t, "new", "old"))
+	rewritten, err := s.ReencryptAll(ctx, lock.NewMemoryLocker(), "")
+	require.NoError(t, err)
+	require.Equal(t, 3, rewritten)
+
+	rewritten, err = s.ReencryptAll(ctx, lock.NewMemoryLocker(), "")
+	require.NoError(t, err)
+	require.Zero(t, rewritten, "documents should not be rewritten twice")
+
+	// Old key is not required anymore
+	s = NewEncryptedDocumentStore(fileStore, newTestKeyRing(t, "new"))
+	require.Equal(t, []byte("plain"), readDocument(t, s, "legacy"))
+	require.Equal(t, []byte("v2"), readDocument(t, s, "doc"))
+
+	r, err := s.GetDocumentVersion("doc", 1)
+	require.NoError(t, err)
+	got, err := ioutil.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.Equal(t, "v1", string(got))
+
+	versionsAfter, err := s.ListVersions(ctx, "doc")
+	require.NoError(t, err)
+	require.Len(t, versionsAfter, len(versionsBefore))
+	for i, v := range versionsAfter {
+		require.Equal(t, versionsBefore[i].Version, v.Version)
+		require.Equal(t, versionsBefore[i].CreatedAt, v.CreatedAt, "revision time should be preserved")
+		require.Equal(t, int64(2), v.Size)
+	}
+}
+
+func TestParseKeyRing(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 16))
+	cases := map[string]struct {
+		keys      []string
+		activeKey string
+		err       string
+	}{
+		"empty": {},
+		"multiple keys": {
+			keys:      []string{"b:" + key, "a:" + key},
+			activeKey: "b",
+		},
+		"missing id": {
+			keys: []string{key},
+			err:  `invalid encryption key #1: key should be in "<id>:<base64 key>" format`,
+		},
+		"invalid key size": {
+			keys: []string{"a:" + key, "b:Zm9v"},
+			err:  "invalid encryption key #2: crypto/aes: invalid key size 3",
+		},
+		"duplicate id": {
+			keys: []string{"a:" + key, "a:" + key},
+			err:  `invalid encryption key #2: duplicate key ID "a"`,
+		},
+	}
+
+	for n, c := range cases {
+		t.Run(n, func(t *testing.T) {
+			got, err := ParseKeyRing(c.keys)
+			if c.err != "" {
+				require.EqualError(t, err, c.err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, c.activeKey, got.ActiveKey())
+			require.Equal(t, len(c.keys) == 0, got.IsEmpty())
+		})
+	}
+}
+
+func TestKeyRing_ReadKeys(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	keys := NewKeyRing()
+	require.NoError(t, keys.ReadKeys(strings.NewReader("# rotated 2021-12-01\n\nnew:"+key+"\nold: "+key+"\n")))
+	require.Equal(t, "new", keys.ActiveKey())
+	require.Len(t, keys.keys, 2)
+
+	err := keys.ReadKeys(strings.NewReader("\nfoo:bar\n"))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid encryption key at line 2")
+}
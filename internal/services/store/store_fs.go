@@ -2,27 +2,57 @@ package store
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 )
 
+// tmpDirName is name of directory inside storage used to prepare files before replace.
+const tmpDirName = ".tmp"
+
+// TrashDirName is name of directory inside storage which can be used to keep removed documents.
+const TrashDirName = ".trash"
+
+// BlobsDirName is name of directory inside storage which can be used to keep deduplicated document blobs.
+const BlobsDirName = ".blobs"
+
+// versionsDirName is name of directory inside storage which contains previous document revisions.
+const versionsDirName = ".versions"
+
 // FileDocumentStore is filesystem document storage.
+//
+// When versioning is enabled, previous document revisions are kept
+// in "<storage>/.versions/<name>/<version>" files.
+//
+// Documents and revisions are kept in hashed sub-directories if sharding is enabled.
 type FileDocumentStore struct {
-	storageDir string
+	storageDir  string
+	maxVersions int
+	shardLevels int
 }
 
 // AddDocument implements DocumentStore
 func (f FileDocumentStore) AddDocument(_ context.Context, name string, data io.Reader) error {
+	if _, err := f.migrateDocument(name); err != nil {
+		return err
+	}
+
 	// Pre-create directory if not exists
-	if err := os.MkdirAll(f.storageDir, os.ModeSticky|os.ModePerm); err != nil {
+	docPath := f.documentPath(name)
+	if err := os.MkdirAll(filepath.Dir(docPath), os.ModeSticky|os.ModePerm); err != nil {
 		return fmt.Errorf("failed to create storage directory: %w", err)
 	}
 
 	// Create a file but check if file already exists.
 	// os.OpenFile returns fs.ErrExist if file already exists.
-	fd, err := os.OpenFile(filepath.Join(f.storageDir, name), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+	fd, err := os.OpenFile(docPath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
 	if err != nil {
 		return err
 	}
@@ -30,22 +60,333 @@ func (f FileDocumentStore) AddDocument(_ context.Context, name string, data io.R
 	defer fd.Close()
 	_, err = io.Copy(fd, data)
 	if err != nil {
+		// Don't leave partially written document in storage.
+		_ = fd.Close()
+		_ = os.Remove(fd.Name())
 		return fmt.Errorf("failed to write file: %w", err)
 	}
 
 	return nil
 }
 
+// ReplaceDocument implements DocumentStore
+func (f FileDocumentStore) ReplaceDocument(_ context.Context, name string, data io.Reader) error {
+	// Document is written into a temporary file first and then moved into place,
+	// so readers never observe partially written document.
+	tmpName, err := f.writeTempFile(data)
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmpName) //nolint:errcheck
+	if _, err := f.migrateDocument(name); err != nil {
+		return err
+	}
+
+	if f.maxVersions > 0 {
+		if err := f.keepVersion(name); err != nil {
+			return err
+		}
+	}
+
+	docPath := f.documentPath(name)
+	if err := os.MkdirAll(filepath.Dir(docPath), os.ModeSticky|os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	if err := os.Rename(tmpName, docPath); err != nil {
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+
+	return nil
+}
+
+// RewriteDocument implements DocumentRewriter
+func (f FileDocumentStore) RewriteDocument(_ context.Context, name string, version int, data io.Reader) error {
+	filePath, err := f.versionPath(name, version)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return err
+	}
+
+	tmpName, err := f.writeTempFile(data)
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmpName) //nolint:errcheck
+	if err := os.Chtimes(tmpName, info.ModTime(), info.ModTime()); err != nil {
+		return fmt.Errorf("failed to preserve file modification time: %w", err)
+	}
+
+	// Hard link of current revision kept as a previous version is not affected by rename.
+	if err := os.Rename(tmpName, filePath); err != nil {
+		return fmt.Errorf("failed to replace file: %w", err)
+	}
+
+	return nil
+}
+
+// writeTempFile writes data into a temporary file inside storage and returns file name.
+func (f FileDocumentStore) writeTempFile(data io.Reader) (string, error) {
+	tmpDir := filepath.Join(f.storageDir, tmpDirName)
+	if err := os.MkdirAll(tmpDir, os.ModeSticky|os.ModePerm); err != nil {
+		return "", fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	fd, err := ioutil.TempFile(tmpDir, "doc-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
+	}
+
+	_, err = io.Copy(fd, data)
+	if closeErr := fd.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		_ = os.Remove(fd.Name())
+		return "", fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return fd.Name(), nil
+}
+
 // RemoveDocument implements DocumentStore
 func (f FileDocumentStore) RemoveDocument(_ context.Context, name string) error {
+	if _, err := f.migrateDocument(name); err != nil {
+		return err
+	}
+
 	// os.Remove returns fs.ErrNotExists if file not exists.
-	return os.Remove(filepath.Join(f.storageDir, name))
+	if err := os.Remove(f.documentPath(name)); err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(f.versionsDir(name)); err != nil {
+		return fmt.Errorf("failed to remove document versions: %w", err)
+	}
+
+	return nil
 }
 
 // GetDocument implements DocumentStore
 func (f FileDocumentStore) GetDocument(name string) (io.ReadCloser, error) {
 	// os.Open returns fs.ErrNotExists if file not exists.
-	return os.Open(filepath.Join(f.storageDir, name))
+	return os.Open(f.locateDocument(name))
+}
+
+// List implements DocumentStore
+func (f FileDocumentStore) List(_ context.Context, opts ListOptions) (*ListResult, error) {
+	// Storage root also contains documents which are not moved into sharded layout yet.
+	items, err := readDocumentsDir(f.storageDir, opts.Prefix, []DocumentInfo{})
+	if err != nil {
+		return nil, err
+	}
+
+	if f.shardLevels > 0 {
+		items, err = f.readShards(filepath.Join(f.storageDir, shardsDirName), 1, opts.Prefix, items)
+		if err != nil {
+			return nil, err
+		}
+
+		items = uniqueDocuments(items)
+	}
+
+	return paginateDocuments(items, opts)
+}
+
+// readDocumentsDir appends documents from directory to items list.
+func readDocumentsDir(dir, prefix string, items []DocumentInfo) ([]DocumentInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// Storage directory is created on first upload.
+			return items, nil
+		}
+
+		return nil, fmt.Errorf("failed to read storage directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), prefix) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				// File was removed during listing.
+				continue
+			}
+
+			return nil, fmt.Errorf("failed to get file info of %q: %w", entry.Name(), err)
+		}
+
+		items = append(items, DocumentInfo{
+			Name:       entry.Name(),
+			Size:       info.Size(),
+			UploadedAt: info.ModTime(),
+		})
+	}
+
+	return items, nil
+}
+
+// ListVersions implements DocumentStore
+func (f FileDocumentStore) ListVersions(_ context.Context, name string) ([]VersionInfo, error) {
+	current, err := os.Stat(f.locateDocument(name))
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := f.storedVersions(name)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]VersionInfo, 0, len(versions)+1)
+	for _, v := range versions {
+		info, err := os.Stat(filepath.Join(f.locateVersionsDir(name), strconv.Itoa(v)))
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				// Version was removed by retention policy during listing.
+				continue
+			}
+
+			return nil, fmt.Errorf("failed to get version %d info: %w", v, err)
+		}
+
+		items = append(items, VersionInfo{Version: v, Size: info.Size(), CreatedAt: info.ModTime()})
+	}
+
+	return append(items, VersionInfo{
+		Version:   nextVersion(versions),
+		Size:      current.Size(),
+		CreatedAt: current.ModTime(),
+	}), nil
+}
+
+// GetDocumentVersion implements DocumentStore
+func (f FileDocumentStore) GetDocumentVersion(name string, version int) (io.ReadCloser, error) {
+	filePath, err := f.versionPath(name, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(filePath)
+}
+
+// WithVersioning enables keeping up to maxVersions previous revisions of replaced documents.
+//
+// Zero value disables versioning.
+func (f *FileDocumentStore) WithVersioning(maxVersions int) *FileDocumentStore {
+	f.maxVersions = maxVersions
+	return f
+}
+
+// versionsDir returns directory of previous document revisions.
+func (f FileDocumentStore) versionsDir(name string) string {
+	return filepath.Join(f.storageDir, versionsDirName, f.shardDir(name), name)
+}
+
+// versionPath returns file path of document revision.
+func (f FileDocumentStore) versionPath(name string, version int) (string, error) {
+	versions, err := f.storedVersions(name)
+	if err != nil {
+		return "", err
+	}
+
+	if version == nextVersion(versions) {
+		return f.locateDocument(name), nil
+	}
+
+	if version <= 0 {
+		return "", fs.ErrNotExist
+	}
+
+	return filepath.Join(f.locateVersionsDir(name), strconv.Itoa(version)), nil
+}
+
+// storedVersions returns sorted list of previous document revision numbers.
+func (f FileDocumentStore) storedVersions(name string) ([]int, error) {
+	entries, err := os.ReadDir(f.locateVersionsDir(name))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("failed to read document versions: %w", err)
+	}
+
+	versions := make([]int, 0, len(entries))
+	for _, entry := range entries {
+		v, err := strconv.Atoi(entry.Name())
+		if err != nil || entry.IsDir() {
+			continue
+		}
+
+		versions = append(versions, v)
+	}
+
+	sort.Ints(versions)
+	return versions, nil
+}
+
+// keepVersion saves current document contents as a previous revision
+// and removes the oldest revisions which exceed retention limit.
+//
+// Document should be in current storage layout.
+func (f FileDocumentStore) keepVersion(name string) error {
+	docPath := f.documentPath(name)
+	if _, err := os.Stat(docPath); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// New document, nothing to keep.
+			return nil
+		}
+
+		return err
+	}
+
+	versions, err := f.storedVersions(name)
+	if err != nil {
+		return err
+	}
+
+	dir := f.versionsDir(name)
+	if err := os.MkdirAll(dir, os.ModeSticky|os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create versions directory: %w", err)
+	}
+
+	// Hard link keeps current file readable until it is replaced.
+	version := nextVersion(versions)
+	if err := os.Link(docPath, filepath.Join(dir, strconv.Itoa(version))); err != nil {
+		return fmt.Errorf("failed to save document version: %w", err)
+	}
+
+	versions = append(versions, version)
+	for len(versions) > f.maxVersions {
+		if err := os.Remove(filepath.Join(dir, strconv.Itoa(versions[0]))); err != nil {
+			return fmt.Errorf("failed to remove old document version: %w", err)
+		}
+
+		versions = versions[1:]
+	}
+
+	return nil
+}
+
+// nextVersion returns version number which follows the last stored revision.
+func nextVersion(versions []int) int {
+	if len(versions) == 0 {
+		return 1
+	}
+
+	return versions[len(versions)-1] + 1
 }
 
 func NewFileDocumentStore(storageDir string) *FileDocumentStore {
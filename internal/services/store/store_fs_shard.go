@@ -0,0 +1,254 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/x1unix/docusearch/internal/services/lock"
+)
+
+// MaxShardLevels is max number of directory levels of sharded storage layout.
+const MaxShardLevels = 4
+
+// shardsDirName is name of directory inside storage which contains sharded layout directories.
+//
+// Shards are not kept in storage root, as flat layout documents like "ab" would collide with them.
+const shardsDirName = ".shards"
+
+// migrateBatchSize is number of directory entries read per batch during layout migration.
+const migrateBatchSize = 1000
+
+// WithSharding enables hashed fan-out directory layout with specified number of directory levels.
+//
+// Each level is named after a byte of document name SHA-256 checksum,
+// e.g. document is stored as "<storage>/.shards/ab/cd/<name>" with 2 levels.
+//
+// Documents stored in flat layout remain readable and are moved into
+// sharded layout on write or by MigrateToShards.
+// Zero value disables sharding.
+func (f *FileDocumentStore) WithSharding(levels int) *FileDocumentStore {
+	f.shardLevels = levels
+	return f
+}
+
+// MigrateToShards moves documents stored in flat layout into sharded layout.
+//
+// Storage stays available during migration. Each document is locked using lockPrefix
+// and document name as a key to avoid conflicts with concurrent writes.
+//
+// Returns number of moved documents.
+func (f FileDocumentStore) MigrateToShards(ctx context.Context, locker lock.Locker, lockPrefix string) (int, error) {
+	if f.shardLevels == 0 {
+		return 0, errors.New("sharding is not enabled")
+	}
+
+	dir, err := os.Open(f.storageDir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, nil
+		}
+
+		return 0, fmt.Errorf("failed to open storage directory: %w", err)
+	}
+
+	defer dir.Close()
+	var moved int
+	for {
+		entries, err := dir.ReadDir(migrateBatchSize)
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() {
+				continue
+			}
+
+			ok, err := f.migrateLocked(ctx, locker, lockPrefix+entry.Name(), entry.Name())
+			if err != nil {
+				return moved, fmt.Errorf("failed to move document %q: %w", entry.Name(), err)
+			}
+
+			if ok {
+				moved++
+			}
+		}
+
+		if err == io.EOF {
+			return moved, nil
+		}
+
+		if err != nil {
+			return moved, fmt.Errorf("failed to read storage directory: %w", err)
+		}
+	}
+}
+
+func (f FileDocumentStore) migrateLocked(ctx context.Context, locker lock.Locker, key, name string) (bool, error) {
+	unlock, err := locker.Lock(ctx, key)
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire document lock: %w", err)
+	}
+
+	defer unlock() //nolint:errcheck
+	return f.migrateDocument(name)
+}
+
+// migrateDocument moves document and its revisions stored in flat layout into sharded layout.
+//
+// Returns false if document is not stored in flat layout.
+func (f FileDocumentStore) migrateDocument(name string) (bool, error) {
+	if f.shardLevels == 0 {
+		return false, nil
+	}
+
+	flatPath := filepath.Join(f.storageDir, name)
+	if !isRegularFile(flatPath) {
+		return false, nil
+	}
+
+	// Revisions are moved first as readers look for revisions in both layouts.
+	flatVersionsDir := filepath.Join(f.storageDir, versionsDirName, name)
+	if isDirectory(flatVersionsDir) {
+		if err := moveFile(flatVersionsDir, f.versionsDir(name)); err != nil {
+			return false, fmt.Errorf("failed to move document versions: %w", err)
+		}
+	}
+
+	if err := moveFile(flatPath, f.documentPath(name)); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// shardDir returns relative sharded layout directory of a document.
+func (f FileDocumentStore) shardDir(name string) string {
+	if f.shardLevels == 0 {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(name))
+	levels := make([]string, 0, f.shardLevels+1)
+	levels = append(levels, shardsDirName)
+	for _, b := range sum[:f.shardLevels] {
+		levels = append(levels, hex.EncodeToString([]byte{b}))
+	}
+
+	return filepath.Join(levels...)
+}
+
+// documentPath returns document file path in current storage layout.
+func (f FileDocumentStore) documentPath(name string) string {
+	return filepath.Join(f.storageDir, f.shardDir(name), name)
+}
+
+// locateDocument returns document file path, looking up documents
+// which are not moved into sharded layout yet.
+func (f FileDocumentStore) locateDocument(name string) string {
+	docPath := f.documentPath(name)
+	if f.shardLevels == 0 || isRegularFile(docPath) {
+		return docPath
+	}
+
+	if flatPath := filepath.Join(f.storageDir, name); isRegularFile(flatPath) {
+		return flatPath
+	}
+
+	// Document might be moved into sharded layout during lookup.
+	return docPath
+}
+
+// locateVersionsDir returns directory of previous document revisions,
+// looking up documents which are not moved into sharded layout yet.
+func (f FileDocumentStore) locateVersionsDir(name string) string {
+	dir := f.versionsDir(name)
+	if f.shardLevels == 0 || isDirectory(dir) {
+		return dir
+	}
+
+	if flatDir := filepath.Join(f.storageDir, versionsDirName, name); isDirectory(flatDir) {
+		return flatDir
+	}
+
+	return dir
+}
+
+// readShards appends documents from sharded layout directories to items list.
+func (f FileDocumentStore) readShards(dir string, level int, prefix string, items []DocumentInfo) ([]DocumentInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return items, nil
+		}
+
+		return nil, fmt.Errorf("failed to read storage directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || !isShardDirName(entry.Name()) {
+			continue
+		}
+
+		subDir := filepath.Join(dir, entry.Name())
+		if level == f.shardLevels {
+			items, err = readDocumentsDir(subDir, prefix, items)
+		} else {
+			items, err = f.readShards(subDir, level+1, prefix, items)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return items, nil
+}
+
+// uniqueDocuments removes duplicate documents which were moved between layouts during listing.
+func uniqueDocuments(items []DocumentInfo) []DocumentInfo {
+	seen := make(map[string]struct{}, len(items))
+	out := items[:0]
+	for _, item := range items {
+		if _, ok := seen[item.Name]; ok {
+			continue
+		}
+
+		seen[item.Name] = struct{}{}
+		out = append(out, item)
+	}
+
+	return out
+}
+
+func isShardDirName(name string) bool {
+	if len(name) != 2 || strings.ToLower(name) != name {
+		return false
+	}
+
+	_, err := hex.DecodeString(name)
+	return err == nil
+}
+
+// moveFile moves file or directory, creating destination parent directory.
+func moveFile(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModeSticky|os.ModePerm); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	return os.Rename(src, dst)
+}
+
+func isRegularFile(name string) bool {
+	info, err := os.Stat(name)
+	return err == nil && info.Mode().IsRegular()
+}
+
+func isDirectory(name string) bool {
+	info, err := os.Stat(name)
+	return err == nil && info.IsDir()
+}
@@ -4,14 +4,19 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"io"
 	"io/fs"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/x1unix/docusearch/internal/services/lock"
 )
 
 func TestFileDocumentStore_AddDocument(t *testing.T) {
@@ -36,7 +41,9 @@ func TestFileDocumentStore_AddDocument(t *testing.T) {
 
 	tmpDir, err := ioutil.TempDir(os.TempDir(), "test-store-fs-*")
 	require.NoError(t, err, "failed to create temp dir")
-	defer assert.NoError(t, os.RemoveAll(tmpDir), "failed to remove temp dir")
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tmpDir), "failed to remove temp dir")
+	}()
 
 	for n, c := range cases {
 		t.Run(n, func(t *testing.T) {
@@ -60,6 +67,155 @@ func TestFileDocumentStore_AddDocument(t *testing.T) {
 	}
 }
 
+func TestFileDocumentStore_AddDocument_Cleanup(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "test-store-fs-*")
+	require.NoError(t, err, "failed to create temp dir")
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tmpDir), "failed to remove temp dir")
+	}()
+
+	wantErr := errors.New("connection reset")
+	r := io.MultiReader(bytes.NewReader([]byte("partial")), iotest.ErrReader(wantErr))
+
+	s := NewFileDocumentStore(tmpDir)
+	err = s.AddDocument(context.TODO(), "partial", r)
+	require.Error(t, err)
+	require.True(t, errors.Is(err, wantErr))
+
+	_, err = os.Stat(filepath.Join(tmpDir, "partial"))
+	require.Truef(t, errors.Is(err, fs.ErrNotExist), "partial file should be removed (got: %v)", err)
+}
+
+func TestFileDocumentStore_ReplaceDocument(t *testing.T) {
+	cases := map[string]struct {
+		name    string
+		data    []byte
+		wantErr error
+		preRun  func(t *testing.T, testdir string) error
+	}{
+		"creates file if not exists": {
+			name: "new",
+			data: []byte{0x50, 0x4B, 0x03, 0x04},
+		},
+		"replaces existing file": {
+			name: "exist",
+			data: []byte("new contents"),
+			preRun: func(t *testing.T, testdir string) error {
+				return ioutil.WriteFile(filepath.Join(testdir, "exist"), []byte("old contents"), 0777)
+			},
+		},
+	}
+
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "test-store-fs-*")
+	require.NoError(t, err, "failed to create temp dir")
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tmpDir), "failed to remove temp dir")
+	}()
+
+	for n, c := range cases {
+		t.Run(n, func(t *testing.T) {
+			if c.preRun != nil {
+				require.NoError(t, c.preRun(t, tmpDir), "preRun failed")
+			}
+
+			s := NewFileDocumentStore(tmpDir)
+			err := s.ReplaceDocument(context.TODO(), c.name, bytes.NewBuffer(c.data))
+			if c.wantErr != nil {
+				require.Error(t, err)
+				require.True(t, errors.Is(err, c.wantErr))
+				return
+			}
+			require.NoError(t, err)
+
+			got, err := ioutil.ReadFile(filepath.Join(tmpDir, c.name))
+			require.NoError(t, err, "failed to read created file")
+			require.Equal(t, c.data, got, "created file and input mismatch")
+
+			tmpFiles, err := ioutil.ReadDir(filepath.Join(tmpDir, tmpDirName))
+			require.NoError(t, err)
+			require.Empty(t, tmpFiles, "temporary files should be removed")
+		})
+	}
+}
+
+func TestFileDocumentStore_ReplaceDocument_Cleanup(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "test-store-fs-*")
+	require.NoError(t, err, "failed to create temp dir")
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tmpDir), "failed to remove temp dir")
+	}()
+
+	want := []byte("old contents")
+	require.NoError(t, ioutil.WriteFile(filepath.Join(tmpDir, "doc"), want, 0777))
+
+	wantErr := errors.New("connection reset")
+	r := io.MultiReader(bytes.NewReader([]byte("partial")), iotest.ErrReader(wantErr))
+
+	s := NewFileDocumentStore(tmpDir)
+	err = s.ReplaceDocument(context.TODO(), "doc", r)
+	require.Error(t, err)
+	require.True(t, errors.Is(err, wantErr))
+
+	got, err := ioutil.ReadFile(filepath.Join(tmpDir, "doc"))
+	require.NoError(t, err)
+	require.Equal(t, want, got, "original document should stay intact")
+}
+
+func TestFileDocumentStore_Versions(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "test-store-fs-*")
+	require.NoError(t, err, "failed to create temp dir")
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tmpDir), "failed to remove temp dir")
+	}()
+
+	ctx := context.TODO()
+	s := NewFileDocumentStore(tmpDir).WithVersioning(2)
+	_, err = s.ListVersions(ctx, "doc")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	require.NoError(t, s.AddDocument(ctx, "doc", strings.NewReader("v1")))
+	for _, data := range []string{"v2", "v3", "v4"} {
+		require.NoError(t, s.ReplaceDocument(ctx, "doc", strings.NewReader(data)))
+	}
+
+	// Only 2 previous versions should be kept
+	versions, err := s.ListVersions(ctx, "doc")
+	require.NoError(t, err)
+	got := make([]int, 0, len(versions))
+	for _, v := range versions {
+		got = append(got, v.Version)
+		require.Equal(t, int64(2), v.Size)
+	}
+	require.Equal(t, []int{2, 3, 4}, got)
+
+	for _, v := range got {
+		r, err := s.GetDocumentVersion("doc", v)
+		require.NoError(t, err)
+		data, err := ioutil.ReadAll(r)
+		require.NoError(t, r.Close())
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("v%d", v), string(data))
+	}
+
+	for _, v := range []int{0, 1, 5} {
+		_, err = s.GetDocumentVersion("doc", v)
+		require.ErrorIs(t, err, fs.ErrNotExist, "version %d", v)
+	}
+
+	// Versions should be removed with document
+	require.NoError(t, s.RemoveDocument(ctx, "doc"))
+	require.NoError(t, s.AddDocument(ctx, "doc", strings.NewReader("new")))
+	versions, err = s.ListVersions(ctx, "doc")
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	require.Equal(t, 1, versions[0].Version)
+
+	// Versions directory should be hidden from listing
+	result, err := s.List(ctx, ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, result.Items, 1)
+}
+
 func TestFileDocumentStore_RemoveDocument(t *testing.T) {
 	cases := map[string]struct {
 		name    string
@@ -81,7 +237,9 @@ func TestFileDocumentStore_RemoveDocument(t *testing.T) {
 
 	tmpDir, err := ioutil.TempDir(os.TempDir(), "test-store-fs-*")
 	require.NoError(t, err, "failed to create temp dir")
-	defer assert.NoError(t, os.RemoveAll(tmpDir), "failed to remove temp dir")
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tmpDir), "failed to remove temp dir")
+	}()
 
 	for n, c := range cases {
 		t.Run(n, func(t *testing.T) {
@@ -128,7 +286,9 @@ func TestFileDocumentStore_GetDocument(t *testing.T) {
 
 	tmpDir, err := ioutil.TempDir(os.TempDir(), "test-store-fs-*")
 	require.NoError(t, err, "failed to create temp dir")
-	defer assert.NoError(t, os.RemoveAll(tmpDir), "failed to remove temp dir")
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tmpDir), "failed to remove temp dir")
+	}()
 
 	for n, c := range cases {
 		t.Run(n, func(t *testing.T) {
@@ -151,3 +311,137 @@ func TestFileDocumentStore_GetDocument(t *testing.T) {
 		})
 	}
 }
+
+func TestFileDocumentStore_List(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "test-store-fs-*")
+	require.NoError(t, err, "failed to create temp dir")
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tmpDir), "failed to remove temp dir")
+	}()
+
+	s := NewFileDocumentStore(filepath.Join(tmpDir, "uploads"))
+	got, err := s.List(context.TODO(), ListOptions{})
+	require.NoError(t, err, "should not fail if storage dir doesn't exist yet")
+	require.Empty(t, got.Items)
+
+	files := map[string]string{
+		"foo":    "foo",
+		"foobar": "foobar",
+		"bar":    "bar",
+	}
+	for name, data := range files {
+		require.NoError(t, s.AddDocument(context.TODO(), name, strings.NewReader(data)))
+	}
+
+	// Temporary directory used by ReplaceDocument should not be listed.
+	require.NoError(t, s.ReplaceDocument(context.TODO(), "bar", strings.NewReader("barbaz")))
+
+	got, err = s.List(context.TODO(), ListOptions{SortBy: SortBySize, Desc: true})
+	require.NoError(t, err)
+	require.Len(t, got.Items, 3)
+	require.Equal(t, "foobar", got.Items[0].Name)
+	require.Equal(t, "bar", got.Items[1].Name)
+	require.Equal(t, int64(6), got.Items[1].Size)
+	require.Equal(t, "foo", got.Items[2].Name)
+
+	got, err = s.List(context.TODO(), ListOptions{Prefix: "foo"})
+	require.NoError(t, err)
+	require.Len(t, got.Items, 2)
+	require.Equal(t, "foo", got.Items[0].Name)
+	require.Equal(t, "foobar", got.Items[1].Name)
+}
+
+func TestFileDocumentStore_Sharding(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "test-store-fs-*")
+	require.NoError(t, err, "failed to create temp dir")
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tmpDir), "failed to remove temp dir")
+	}()
+
+	ctx := context.TODO()
+	s := NewFileDocumentStore(tmpDir).WithVersioning(2).WithSharding(2)
+	require.NoError(t, s.AddDocument(ctx, "foo", strings.NewReader("foo")))
+	require.ErrorIs(t, s.AddDocument(ctx, "foo", strings.NewReader("foo")), fs.ErrExist)
+	require.NoError(t, s.ReplaceDocument(ctx, "foo", strings.NewReader("foo2")))
+
+	shardDir := s.shardDir("foo")
+	require.Equal(t, []string{shardsDirName}, strings.Split(shardDir, string(filepath.Separator))[:1])
+	require.Len(t, strings.Split(shardDir, string(filepath.Separator)), 3)
+	require.FileExists(t, filepath.Join(tmpDir, shardDir, "foo"))
+	require.FileExists(t, filepath.Join(tmpDir, versionsDirName, shardDir, "foo", "1"))
+	require.NoFileExists(t, filepath.Join(tmpDir, "foo"))
+	require.Equal(t, []byte("foo2"), readDocument(t, s, "foo"))
+
+	versions, err := s.ListVersions(ctx, "foo")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+
+	list, err := s.List(ctx, ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+	require.Equal(t, "foo", list.Items[0].Name)
+
+	require.NoError(t, s.RemoveDocument(ctx, "foo"))
+	_, err = s.GetDocument("foo")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	require.NoDirExists(t, filepath.Join(tmpDir, versionsDirName, shardDir, "foo"))
+}
+
+func TestFileDocumentStore_MigrateToShards(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "test-store-fs-*")
+	require.NoError(t, err, "failed to create temp dir")
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tmpDir), "failed to remove temp dir")
+	}()
+
+	ctx := context.TODO()
+	s := NewFileDocumentStore(tmpDir).WithVersioning(2).WithSharding(1)
+
+	// Flat layout document named as a shard directory doesn't collide with it
+	shardName := filepath.Base(s.shardDir("a"))
+	flat := NewFileDocumentStore(tmpDir).WithVersioning(2)
+	for _, name := range []string{"a", "b", "c", shardName} {
+		require.NoError(t, flat.AddDocument(ctx, name, strings.NewReader(name)))
+		require.NoError(t, flat.ReplaceDocument(ctx, name, strings.NewReader(name+"2")))
+	}
+
+	// Documents in both layouts are available before migration
+	require.NoError(t, s.ReplaceDocument(ctx, "a", strings.NewReader("a3")))
+	require.NoFileExists(t, filepath.Join(tmpDir, "a"))
+	require.Equal(t, []byte("b2"), readDocument(t, s, "b"))
+
+	versions, err := s.ListVersions(ctx, "b")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+
+	list, err := s.List(ctx, ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 4)
+
+	moved, err := s.MigrateToShards(ctx, lock.NewMemoryLocker(), "")
+	require.NoError(t, err)
+	require.Equal(t, 3, moved)
+
+	moved, err = s.MigrateToShards(ctx, lock.NewMemoryLocker(), "")
+	require.NoError(t, err)
+	require.Zero(t, moved)
+
+	for name, want := range map[string]string{"a": "a3", "b": "b2", "c": "c2", shardName: shardName + "2"} {
+		require.FileExists(t, s.documentPath(name))
+		require.Equal(t, []byte(want), readDocument(t, s, name))
+
+		r, err := s.GetDocumentVersion(name, 1)
+		require.NoError(t, err)
+		got, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, r.Close())
+		require.Equal(t, name, string(got))
+	}
+
+	list, err = s.List(ctx, ListOptions{})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 4)
+
+	_, err = flat.MigrateToShards(ctx, lock.NewMemoryLocker(), "")
+	require.Error(t, err, "migration requires sharding to be enabled")
+}
@@ -0,0 +1,214 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// S3DocumentStore is document storage backed by S3-compatible object storage.
+//
+// Documents are stored as "<prefix><name>" objects in a bucket.
+// Objects in nested "directories" of prefix are not listed.
+//
+// Document versioning is not supported, only current document version is available.
+type S3DocumentStore struct {
+	client   s3iface.S3API
+	uploader *s3manager.Uploader
+	bucket   string
+	prefix   string
+}
+
+// NewS3DocumentStore constructs a new S3 document store.
+//
+// Prefix is prepended to document name to get object key.
+func NewS3DocumentStore(client s3iface.S3API, bucket, prefix string) *S3DocumentStore {
+	return &S3DocumentStore{
+		client:   client,
+		uploader: s3manager.NewUploaderWithClient(client),
+		bucket:   bucket,
+		prefix:   prefix,
+	}
+}
+
+// AddDocument implements DocumentStore
+func (s S3DocumentStore) AddDocument(ctx context.Context, name string, data io.Reader) error {
+	// S3 has no conditional writes, concurrent writes are serialized by document locks.
+	_, err := s.head(ctx, name)
+	if err == nil {
+		return fs.ErrExist
+	}
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return s.put(ctx, name, data)
+}
+
+// ReplaceDocument implements DocumentStore
+func (s S3DocumentStore) ReplaceDocument(ctx context.Context, name string, data io.Reader) error {
+	return s.put(ctx, name, data)
+}
+
+// RemoveDocument implements DocumentStore
+func (s S3DocumentStore) RemoveDocument(ctx context.Context, name string) error {
+	// Delete succeeds for missing objects, so existence is checked explicitly.
+	if _, err := s.head(ctx, name); err != nil {
+		return err
+	}
+
+	_, err := s.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(name)),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove object: %w", mapS3Error(err))
+	}
+
+	return nil
+}
+
+// GetDocument implements DocumentStore
+func (s S3DocumentStore) GetDocument(name string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(name)),
+	})
+	if err != nil {
+		return nil, mapS3Error(err)
+	}
+
+	return out.Body, nil
+}
+
+// List implements DocumentStore
+func (s S3DocumentStore) List(ctx context.Context, opts ListOptions) (*ListResult, error) {
+	items := []DocumentInfo{}
+	input := &s3.ListObjectsV2Input{
+		Bucket:    aws.String(s.bucket),
+		Prefix:    aws.String(s.key(opts.Prefix)),
+		Delimiter: aws.String("/"),
+	}
+
+	err := s.client.ListObjectsV2PagesWithContext(ctx, input, func(page *s3.ListObjectsV2Output, _ bool) bool {
+		for _, obj := range page.Contents {
+			items = append(items, DocumentInfo{
+				Name:       strings.TrimPrefix(aws.StringValue(obj.Key), s.prefix),
+				Size:       aws.Int64Value(obj.Size),
+				UploadedAt: aws.TimeValue(obj.LastModified),
+			})
+		}
+
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", mapS3Error(err))
+	}
+
+	return paginateDocuments(items, opts)
+}
+
+// ListVersions implements DocumentStore
+func (s S3DocumentStore) ListVersions(ctx context.Context, name string) ([]VersionInfo, error) {
+	out, err := s.head(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return []VersionInfo{{
+		Version:   1,
+		Size:      aws.Int64Value(out.ContentLength),
+		CreatedAt: aws.TimeValue(out.LastModified),
+	}}, nil
+}
+
+// GetDocumentVersion implements DocumentStore
+func (s S3DocumentStore) GetDocumentVersion(name string, version int) (io.ReadCloser, error) {
+	if version != 1 {
+		return nil, fs.ErrNotExist
+	}
+
+	return s.GetDocument(name)
+}
+
+func (s S3DocumentStore) key(name string) string {
+	return s.prefix + name
+}
+
+func (s S3DocumentStore) head(ctx context.Context, name string) (*s3.HeadObjectOutput, error) {
+	out, err := s.client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(name)),
+	})
+	if err != nil {
+		return nil, mapS3Error(err)
+	}
+
+	return out, nil
+}
+
+// put uploads document contents, using multipart upload for large documents.
+func (s S3DocumentStore) put(ctx context.Context, name string, data io.Reader) error {
+	body := &errorTrackingReader{r: data}
+	_, err := s.uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(s.key(name)),
+		Body:   body,
+	})
+	if err == nil {
+		return nil
+	}
+
+	if body.err != nil {
+		// SDK errors don't support unwrapping, so read error is returned as is.
+		return fmt.Errorf("failed to read document: %w", body.err)
+	}
+
+	return fmt.Errorf("failed to upload object: %w", mapS3Error(err))
+}
+
+// errorTrackingReader keeps the last read error of underlying reader except io.EOF.
+type errorTrackingReader struct {
+	r   io.Reader
+	err error
+}
+
+// Read implements io.Reader
+func (r *errorTrackingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+
+	return n, err
+}
+
+// mapS3Error maps S3 "not found" errors to fs.ErrNotExist.
+func mapS3Error(err error) error {
+	var reqErr awserr.RequestFailure
+	if errors.As(err, &reqErr) && reqErr.StatusCode() == http.StatusNotFound {
+		if reqErr.Code() == s3.ErrCodeNoSuchBucket {
+			return err
+		}
+
+		return fs.ErrNotExist
+	}
+
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && awsErr.Code() == s3.ErrCodeNoSuchKey {
+		return fs.ErrNotExist
+	}
+
+	return err
+}
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	"github.com/stretchr/testify/require"
+)
+
+const testBucket = "documents"
+
+// newTestS3Client starts in-process S3 server and returns a client connected to it.
+func newTestS3Client(t *testing.T) *s3.S3 {
+	t.Helper()
+	backend := s3mem.New()
+	require.NoError(t, backend.CreateBucket(testBucket))
+	srv := httptest.NewServer(gofakes3.New(backend).Server())
+	t.Cleanup(srv.Close)
+
+	sess, err := session.NewSession(aws.NewConfig().
+		WithEndpoint(srv.URL).
+		WithRegion("us-east-1").
+		WithS3ForcePathStyle(true).
+		WithCredentials(credentials.NewStaticCredentials("key", "secret", "")))
+	require.NoError(t, err)
+	return s3.New(sess)
+}
+
+func TestS3DocumentStore(t *testing.T) {
+	ctx := context.TODO()
+	client := newTestS3Client(t)
+	s := NewS3DocumentStore(client, testBucket, "docs/")
+	assertContents := func(t *testing.T, name, want string) {
+		t.Helper()
+		r, err := s.GetDocument(name)
+		require.NoError(t, err)
+		defer r.Close()
+		got, err := ioutil.ReadAll(r)
+		require.NoError(t, err)
+		require.Equal(t, want, string(got))
+	}
+
+	// Object outside of prefix and in nested prefix should be ignored
+	other := NewS3DocumentStore(client, testBucket, "")
+	require.NoError(t, other.AddDocument(ctx, "a", strings.NewReader("other")))
+	trash := NewS3DocumentStore(client, testBucket, "docs/.trash/")
+	require.NoError(t, trash.AddDocument(ctx, "a", strings.NewReader("trash")))
+
+	_, err := s.GetDocument("a")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	require.ErrorIs(t, s.RemoveDocument(ctx, "a"), fs.ErrNotExist)
+	_, err = s.ListVersions(ctx, "a")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	require.NoError(t, s.AddDocument(ctx, "a", strings.NewReader("foo")))
+	require.ErrorIs(t, s.AddDocument(ctx, "a", strings.NewReader("bar")), fs.ErrExist)
+	assertContents(t, "a", "foo")
+
+	require.NoError(t, s.ReplaceDocument(ctx, "a", strings.NewReader("foobar")))
+	require.NoError(t, s.ReplaceDocument(ctx, "b", strings.NewReader("b")))
+	assertContents(t, "a", "foobar")
+
+	versions, err := s.ListVersions(ctx, "a")
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	require.Equal(t, 1, versions[0].Version)
+	require.Equal(t, int64(6), versions[0].Size)
+
+	_, err = s.GetDocumentVersion("a", 2)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	list, err := s.List(ctx, ListOptions{SortBy: SortBySize, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+	require.Equal(t, "b", list.Items[0].Name)
+	require.Equal(t, int64(1), list.Items[0].Size)
+	require.False(t, list.Items[0].UploadedAt.IsZero())
+
+	list, err = s.List(ctx, ListOptions{SortBy: SortBySize, Limit: 1, Cursor: list.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+	require.Equal(t, "a", list.Items[0].Name)
+	require.Empty(t, list.NextCursor)
+
+	list, err = s.List(ctx, ListOptions{Prefix: "b"})
+	require.NoError(t, err)
+	require.Len(t, list.Items, 1)
+
+	require.NoError(t, s.RemoveDocument(ctx, "a"))
+	_, err = s.GetDocument("a")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	// Other objects should be kept
+	r, err := other.GetDocument("a")
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	r, err = trash.GetDocument("a")
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+}
+
+func TestS3DocumentStore_Errors(t *testing.T) {
+	ctx := context.TODO()
+	client := newTestS3Client(t)
+
+	// Missing bucket isn't a missing document
+	s := NewS3DocumentStore(client, "missing", "")
+	_, err := s.GetDocument("a")
+	require.Error(t, err)
+	require.False(t, errors.Is(err, fs.ErrNotExist))
+
+	// Read error should be kept for callers
+	readErr := errors.New("read error")
+	s = NewS3DocumentStore(client, testBucket, "")
+	err = s.ReplaceDocument(ctx, "a", &failingReader{err: readErr})
+	require.ErrorIs(t, err, readErr)
+	_, err = s.GetDocument("a")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+type failingReader struct {
+	err error
+}
+
+func (r failingReader) Read(_ []byte) (int, error) {
+	return 0, r.err
+}
@@ -3,9 +3,17 @@ package store
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"io/fs"
+	"time"
 
+	"github.com/x1unix/docusearch/internal/services/extract"
+	"github.com/x1unix/docusearch/internal/services/lock"
 	"github.com/x1unix/docusearch/internal/services/search"
 	"github.com/x1unix/docusearch/internal/utils/collections"
 	"go.uber.org/zap"
@@ -13,61 +21,534 @@ import (
 
 type TextIndexConfig struct {
 	IgnoreCommonWords bool
+
+	// FieldPaths is list of JSON and CSV document field paths indexed as separate fields.
+	//
+	// All fields are indexed if list is empty.
+	FieldPaths []string
+
+	// DedupContent enables indexing of identical document contents only once.
+	//
+	// Takes effect only if search provider implements search.ContentIndex.
+	DedupContent bool
 }
 
 // initBufferSize is initial buffer size for document parse buffer
 const initBufferSize = 500 * 1024 // 500KB
 
 // SyncedDocumentStore is facade over document storage implementation
-// that keeps search index in sync on file upload/delete.
+// that keeps search index and documents metadata in sync on file upload/delete.
+//
+// Indexed text is extracted from documents according to their content type,
+// while documents are stored unchanged.
+//
+// Write operations on the same document are serialized using a per-document lock.
 type SyncedDocumentStore struct {
 	log            *zap.Logger
 	store          DocumentStore
+	metaStore      MetadataStore
 	searchProvider search.Provider
+	contentIndex   search.ContentIndex
+	locker         lock.Locker
+	trash          *Trash
+	quotas         *Quotas
+	extractor      *extract.Pipeline
 	filterList     collections.StringsSet
 }
 
-func NewSyncedDocumentStore(log *zap.Logger, store DocumentStore, searchProvider search.Provider, cfg TextIndexConfig) *SyncedDocumentStore {
-	s := &SyncedDocumentStore{log: log, store: store, searchProvider: searchProvider}
+// NewSyncedDocumentStore constructs a new synced document store.
+//
+// In-process lock is used if locker is nil.
+func NewSyncedDocumentStore(log *zap.Logger, store DocumentStore, metaStore MetadataStore, searchProvider search.Provider,
+	locker lock.Locker, cfg TextIndexConfig) *SyncedDocumentStore {
+	if locker == nil {
+		locker = lock.NewMemoryLocker()
+	}
+
+	s := &SyncedDocumentStore{
+		log:            log,
+		store:          store,
+		metaStore:      metaStore,
+		searchProvider: searchProvider,
+		locker:         locker,
+		extractor:      extract.DefaultPipeline(),
+	}
 	if cfg.IgnoreCommonWords {
 		s.filterList = search.EnglishCommonVerbs
 	}
 
+	if contentIndex, ok := searchProvider.(search.ContentIndex); ok && cfg.DedupContent {
+		s.contentIndex = contentIndex
+	}
+
+	if len(cfg.FieldPaths) > 0 {
+		s.extractor.
+			Register(extract.MIMETypeJSON, extract.NewJSONExtractor(cfg.FieldPaths)).
+			Register(extract.MIMETypeCSV, extract.NewCSVExtractor(cfg.FieldPaths))
+	}
+
 	return s
 }
 
-// AddDocument implements DocumentStore
-func (s SyncedDocumentStore) AddDocument(ctx context.Context, name string, data io.Reader) error {
-	buff := new(bytes.Buffer)
-	buff.Grow(initBufferSize)
+// AddDocument stores a new document and adds it to search index.
+//
+// Returns fs.ErrExist if item already exists
+// and ErrPreconditionFailed if document doesn't satisfy write precondition.
+func (s SyncedDocumentStore) AddDocument(ctx context.Context, name string, data io.Reader, opts WriteOptions) (*Metadata, error) {
+	unlock, err := s.lockDocument(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer unlock()
+	if _, err := s.checkPrecondition(ctx, name, opts.Precondition); err != nil {
+		return nil, err
+	}
+
+	data, err = s.limitQuota(ctx, name, data, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := newDocumentBuffer()
+	if err := s.store.AddDocument(ctx, name, doc.tee(data)); err != nil {
+		s.releaseQuota(ctx, name, nil)
+		return nil, err
+	}
+
+	now := time.Now()
+	meta := doc.metadata(name, opts)
+	meta.CreatedAt = now
+	meta.UpdatedAt = now
+	s.updateUsage(ctx, name, usageDelta(meta, nil))
+	if err := s.indexDocument(ctx, name, doc, meta); err != nil {
+		return nil, err
+	}
 
-	teeReader := io.TeeReader(data, buff)
-	if err := s.store.AddDocument(ctx, name, teeReader); err != nil {
+	return meta, nil
+}
+
+// ReplaceDocument creates or replaces a document and updates search index.
+//
+// Returns ErrPreconditionFailed if document doesn't satisfy write precondition.
+func (s SyncedDocumentStore) ReplaceDocument(ctx context.Context, name string, data io.Reader, opts WriteOptions) (*Metadata, error) {
+	unlock, err := s.lockDocument(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer unlock()
+	prevMeta, err := s.checkPrecondition(ctx, name, opts.Precondition)
+	if err != nil {
+		return nil, err
+	}
+
+	if prevMeta == nil && s.quotas != nil {
+		// Size of document uploaded before metadata support is required to update namespace usage.
+		prevMeta, err = s.metadata(ctx, name)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	data, err = s.limitQuota(ctx, name, data, prevMeta)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := newDocumentBuffer()
+	if err := s.store.ReplaceDocument(ctx, name, doc.tee(data)); err != nil {
+		s.releaseQuota(ctx, name, prevMeta)
+		return nil, err
+	}
+
+	now := time.Now()
+	meta := doc.metadata(name, opts)
+	meta.CreatedAt = now
+	meta.UpdatedAt = now
+	s.updateUsage(ctx, name, usageDelta(meta, prevMeta))
+	if prevMeta != nil {
+		meta.CreatedAt = prevMeta.CreatedAt
+		if opts.Labels == nil {
+			meta.Labels = prevMeta.Labels
+		}
+
+		if opts.ExpiresAt == nil {
+			meta.ExpiresAt = prevMeta.ExpiresAt
+		}
+
+		if opts.Analyzer == "" && prevMeta.Analyzer != "" {
+			meta.Analyzer = prevMeta.Analyzer
+		}
+	}
+
+	if err := s.metaStore.SaveMetadata(ctx, name, meta); err != nil {
+		return nil, fmt.Errorf("failed to save document metadata: %w", err)
+	}
+
+	if err := s.indexWords(ctx, name, doc, meta, true); err != nil {
+		return nil, fmt.Errorf("failed to update document index: %w", err)
+	}
+
+	if opts.Labels == nil {
+		// Labels weren't changed
+		return meta, nil
+	}
+
+	if err := s.searchProvider.UpdateDocumentLabels(ctx, name, meta.Labels); err != nil {
+		return nil, fmt.Errorf("failed to index document labels: %w", err)
+	}
+
+	return meta, nil
+}
+
+// RemoveDocument removes document from storage and search index.
+//
+// Document is moved to trash if trash is enabled.
+//
+// Returns fs.ErrNotExist if item doesn't exist
+// and ErrPreconditionFailed if document doesn't satisfy precondition.
+func (s SyncedDocumentStore) RemoveDocument(ctx context.Context, name string, cond Precondition) error {
+	unlock, err := s.lockDocument(ctx, name)
+	if err != nil {
 		return err
 	}
 
-	words := search.WordsFromString(buff.String(), s.filterList)
-	if err := s.searchProvider.AddDocumentRef(ctx, name, words); err != nil {
-		return fmt.Errorf("failed to index document: %w", err)
+	defer unlock()
+	if _, err := s.checkPrecondition(ctx, name, cond); err != nil {
+		return err
 	}
 
-	return nil
+	return s.removeDocument(ctx, name, true)
 }
 
-// RemoveDocument implements DocumentStore
-func (s SyncedDocumentStore) RemoveDocument(ctx context.Context, name string) error {
+// removeDocument removes document from storage, search index and metadata storage.
+//
+// Document is moved to trash if trash is enabled and keepInTrash is set.
+// Caller should hold document lock.
+func (s SyncedDocumentStore) removeDocument(ctx context.Context, name string, keepInTrash bool) error {
+	var size int64
+	if s.quotas != nil {
+		// Size of removed document is required to update namespace usage.
+		meta, err := s.metadata(ctx, name)
+		if err != nil {
+			return err
+		}
+
+		size = meta.Size
+	}
+
+	if s.trash != nil && keepInTrash {
+		if err := s.moveToTrash(ctx, name); err != nil {
+			return err
+		}
+	}
+
 	if err := s.store.RemoveDocument(ctx, name); err != nil {
 		return err
 	}
 
+	s.updateUsage(ctx, name, Usage{Documents: -1, Bytes: -size})
 	if err := s.searchProvider.RemoveDocumentRef(ctx, name); err != nil {
 		return fmt.Errorf("failed to remove document from search index: %w", err)
 	}
 
+	if err := s.metaStore.RemoveMetadata(ctx, name); err != nil {
+		return fmt.Errorf("failed to remove document metadata: %w", err)
+	}
+
 	return nil
 }
 
-// GetDocument implements DocumentStore
+// GetDocument returns document reader by name.
+//
+// Returns fs.ErrNotExist if item doesn't exist.
 func (s SyncedDocumentStore) GetDocument(name string) (io.ReadCloser, error) {
 	return s.store.GetDocument(name)
 }
+
+// GetEncodedDocument returns document reader using one of accepted content encodings
+// if storage keeps documents encoded.
+//
+// Returns decoded document and empty encoding if document encoding is not accepted.
+// Returns fs.ErrNotExist if item doesn't exist.
+func (s SyncedDocumentStore) GetEncodedDocument(name string, acceptEncodings []string) (io.ReadCloser, string, error) {
+	if encStore, ok := s.store.(EncodedDocumentReader); ok {
+		return encStore.GetEncodedDocument(name, acceptEncodings)
+	}
+
+	r, err := s.store.GetDocument(name)
+	return r, "", err
+}
+
+// ListVersions returns list of kept document revisions.
+//
+// Returns fs.ErrNotExist if item doesn't exist.
+func (s SyncedDocumentStore) ListVersions(ctx context.Context, name string) ([]VersionInfo, error) {
+	return s.store.ListVersions(ctx, name)
+}
+
+// GetDocumentVersion returns reader of specified document revision.
+//
+// Returns fs.ErrNotExist if item or revision doesn't exist.
+func (s SyncedDocumentStore) GetDocumentVersion(name string, version int) (io.ReadCloser, error) {
+	return s.store.GetDocumentVersion(name, version)
+}
+
+// GetMetadata returns document metadata.
+//
+// Metadata is calculated and saved on demand under document lock for documents
+// uploaded before metadata support was introduced.
+//
+// Returns fs.ErrNotExist if item doesn't exist or expired.
+func (s SyncedDocumentStore) GetMetadata(ctx context.Context, name string) (*Metadata, error) {
+	meta, err := s.metaStore.GetMetadata(ctx, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		meta, err = s.lockedMetadata(ctx, name)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if meta.IsExpired(time.Now()) {
+		// Expired document might be not removed yet.
+		return nil, fs.ErrNotExist
+	}
+
+	return meta, nil
+}
+
+// lockedMetadata acquires document lock and returns document metadata,
+// calculating it from contents if it's missing.
+//
+// Lock prevents saving metadata of outdated contents if document is replaced concurrently.
+func (s SyncedDocumentStore) lockedMetadata(ctx context.Context, name string) (*Metadata, error) {
+	unlock, err := s.lockDocument(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer unlock()
+	return s.metadata(ctx, name)
+}
+
+// metadata returns document metadata, calculating it from contents if it's missing.
+//
+// Caller should hold document lock.
+func (s SyncedDocumentStore) metadata(ctx context.Context, name string) (*Metadata, error) {
+	meta, err := s.metaStore.GetMetadata(ctx, name)
+	if err == nil {
+		return meta, nil
+	}
+
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	r, err := s.store.GetDocument(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+	doc := newDocumentBuffer()
+	if _, err := io.Copy(io.Discard, doc.tee(r)); err != nil {
+		return nil, fmt.Errorf("failed to read document: %w", err)
+	}
+
+	now := time.Now()
+	meta = doc.metadata(name, WriteOptions{})
+	meta.CreatedAt = now
+	meta.UpdatedAt = now
+	if err := s.metaStore.SaveMetadata(ctx, name, meta); err != nil {
+		return nil, fmt.Errorf("failed to save document metadata: %w", err)
+	}
+
+	return meta, nil
+}
+
+// indexDocument saves metadata of a new document and adds document to search index.
+func (s SyncedDocumentStore) indexDocument(ctx context.Context, name string, doc *documentBuffer, meta *Metadata) error {
+	if err := s.metaStore.SaveMetadata(ctx, name, meta); err != nil {
+		return fmt.Errorf("failed to save document metadata: %w", err)
+	}
+
+	if err := s.indexWords(ctx, name, doc, meta, false); err != nil {
+		return fmt.Errorf("failed to index document: %w", err)
+	}
+
+	if len(meta.Labels) == 0 {
+		return nil
+	}
+
+	if err := s.searchProvider.UpdateDocumentLabels(ctx, name, meta.Labels); err != nil {
+		return fmt.Errorf("failed to index document labels: %w", err)
+	}
+
+	return nil
+}
+
+// indexWords adds words of document text to search index, replacing previously indexed words.
+//
+// If content index is enabled, words of identical contents are extracted and indexed only once.
+func (s SyncedDocumentStore) indexWords(ctx context.Context, name string, doc *documentBuffer, meta *Metadata, replace bool) error {
+	if s.contentIndex != nil {
+		key := contentKey(meta)
+		indexed, err := s.contentIndex.LinkDocumentContent(ctx, name, key)
+		if err != nil || indexed {
+			return err
+		}
+
+		return s.contentIndex.AddContentRef(ctx, key, s.documentWords(name, doc, meta))
+	}
+
+	words := s.documentWords(name, doc, meta)
+	if replace {
+		return s.searchProvider.UpdateDocumentRef(ctx, name, words)
+	}
+
+	return s.searchProvider.AddDocumentRef(ctx, name, words)
+}
+
+// contentKey returns search index key of document contents.
+//
+// Indexed words also depend on content type and analyzer, so identical contents
+// of different types are indexed separately.
+func contentKey(meta *Metadata) string {
+	analyzer := meta.Analyzer
+	if analyzer == "" {
+		analyzer = search.AnalyzerText
+	}
+
+	return meta.SHA256 + ":" + analyzer + ":" + meta.ContentType
+}
+
+// documentWords returns words of document text for search index.
+//
+// Text is extracted according to document content type.
+// Words of document fields, e.g. title, are also indexed as field terms.
+// Document is indexed without words if its text can't be extracted.
+func (s SyncedDocumentStore) documentWords(name string, doc *documentBuffer, meta *Metadata) []string {
+	content, err := s.extractor.Extract(meta.ContentType, doc.buff.Bytes())
+	if err != nil {
+		s.log.Warn("failed to extract document text", zap.String("name", name),
+			zap.String("content_type", meta.ContentType), zap.Error(err))
+		return nil
+	}
+
+	words := search.AnalyzeWords(meta.Analyzer, content.Text, s.filterList)
+	for field, text := range content.Fields {
+		for _, word := range search.AnalyzeWords(meta.Analyzer, text, s.filterList) {
+			words = append(words, search.FieldTerm(field, word))
+		}
+	}
+
+	return words
+}
+
+// lockDocument acquires document lock and returns a function to release it.
+func (s SyncedDocumentStore) lockDocument(ctx context.Context, name string) (func(), error) {
+	unlock, err := s.locker.Lock(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire document lock: %w", err)
+	}
+
+	return func() {
+		if err := unlock(); err != nil {
+			s.log.Error("failed to release document lock", zap.String("name", name), zap.Error(err))
+		}
+	}, nil
+}
+
+// checkPrecondition checks if document satisfies a precondition and returns current document metadata.
+//
+// Expired document is removed and treated as missing.
+// Returns nil metadata if document doesn't exist.
+func (s SyncedDocumentStore) checkPrecondition(ctx context.Context, name string, cond Precondition) (*Metadata, error) {
+	var (
+		meta *Metadata
+		err  error
+	)
+
+	if cond.IsEmpty() {
+		meta, err = s.metaStore.GetMetadata(ctx, name)
+	} else {
+		// Metadata might be missing for old documents and should be calculated to check checksum.
+		meta, err = s.metadata(ctx, name)
+	}
+
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to get document metadata: %w", err)
+		}
+
+		meta = nil
+	}
+
+	if meta != nil && meta.IsExpired(time.Now()) {
+		if err := s.removeExpired(ctx, name); err != nil {
+			return nil, fmt.Errorf("failed to remove expired document: %w", err)
+		}
+
+		meta = nil
+	}
+
+	if err := cond.Check(meta); err != nil {
+		return nil, err
+	}
+
+	return meta, nil
+}
+
+// List returns a page of stored documents list.
+func (s SyncedDocumentStore) List(ctx context.Context, opts ListOptions) (*ListResult, error) {
+	return s.store.List(ctx, opts)
+}
+
+// documentBuffer collects document contents and checksum while document is written to storage.
+type documentBuffer struct {
+	buff *bytes.Buffer
+	hash hash.Hash
+}
+
+func newDocumentBuffer() *documentBuffer {
+	buff := new(bytes.Buffer)
+	buff.Grow(initBufferSize)
+	return &documentBuffer{buff: buff, hash: sha256.New()}
+}
+
+// tee returns a reader which writes to buffer everything it reads from passed reader.
+func (d documentBuffer) tee(r io.Reader) io.Reader {
+	return io.TeeReader(r, io.MultiWriter(d.buff, d.hash))
+}
+
+// metadata returns document metadata without timestamps.
+func (d documentBuffer) metadata(name string, opts WriteOptions) *Metadata {
+	contentType := opts.ContentType
+	if contentType == "" {
+		contentType = extract.DetectContentType(name, d.buff.Bytes())
+	}
+
+	charset := extract.DetectCharset(contentType, d.buff.Bytes())
+	if charset != "" {
+		// Content type is served with document, so charset is required to display text correctly.
+		contentType = extract.WithCharset(contentType, charset)
+	}
+
+	analyzer := opts.Analyzer
+	if detected := search.DetectAnalyzer(name); analyzer == "" && detected != search.AnalyzerText {
+		// Default analyzer is stored only if it was set explicitly.
+		analyzer = detected
+	}
+
+	return &Metadata{
+		Size:        int64(d.buff.Len()),
+		SHA256:      hex.EncodeToString(d.hash.Sum(nil)),
+		ContentType: contentType,
+		Charset:     charset,
+		Analyzer:    analyzer,
+		Labels:      opts.Labels,
+		ExpiresAt:   opts.ExpiresAt,
+	}
+}
@@ -1,4 +1,4 @@
-package store
+package store_test
 
 import (
 	"context"
@@ -7,64 +7,98 @@ import (
 	"io"
 	"io/fs"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/x1unix/docusearch/internal/services/search"
+	"github.com/x1unix/docusearch/internal/services/store"
 	"github.com/x1unix/docusearch/internal/services/store/mocks"
 	"go.uber.org/zap/zaptest"
 )
 
 //go:generate mockgen, ctrl -destination ./mocks/search.go -package mocks github.com/x1unix/docusearch/internal/services/search Provider
 //go:generate mockgen -destination ./mocks/store.go -package mocks github.com/x1unix/docusearch/internal/services/store DocumentStore
+//go:generate mockgen -destination ./mocks/meta.go -package mocks github.com/x1unix/docusearch/internal/services/store MetadataStore
 
 func TestSyncedDocumentStore_AddDocument(t *testing.T) {
 	cases := map[string]struct {
 		name    string
 		data    io.Reader
 		wantErr string
-		cfg     TextIndexConfig
+		cfg     store.TextIndexConfig
+		opts    store.WriteOptions
 
 		wantErrFn   func(err error) bool
-		newStoreFn  func(t *testing.T, ctrl *gomock.Controller) DocumentStore
+		newStoreFn  func(t *testing.T, ctrl *gomock.Controller) store.DocumentStore
+		newMetaFn   func(t *testing.T, ctrl *gomock.Controller) store.MetadataStore
 		newSearchFn func(t *testing.T, ctrl *gomock.Controller) search.Provider
 	}{
 		"should update document and words index on save": {
 			name: "correct",
 			data: strings.NewReader("The quick brown fox jumps over the lazy dog"),
-			cfg:  TextIndexConfig{IgnoreCommonWords: true},
+			cfg:  store.TextIndexConfig{IgnoreCommonWords: true},
+			opts: store.WriteOptions{Labels: map[string]string{"kind": "pangram"}},
 
-			newStoreFn: func(t *testing.T, ctrl *gomock.Controller) DocumentStore {
-				store := mocks.NewMockDocumentStore(ctrl)
-				store.EXPECT().
+			newStoreFn: func(t *testing.T, ctrl *gomock.Controller) store.DocumentStore {
+				storeMock := mocks.NewMockDocumentStore(ctrl)
+				storeMock.EXPECT().
 					AddDocument(gomock.Any(), "correct", matchReaderContents(t, []byte("The quick brown fox jumps over the lazy dog"))).
 					Return(nil)
-				return store
+				return storeMock
 			},
 
+			newMetaFn: func(t *testing.T, ctrl *gomock.Controller) store.MetadataStore {
+				ms := mocks.NewMockMetadataStore(ctrl)
+				ms.EXPECT().GetMetadata(gomock.Any(), "correct").Return(nil, fs.ErrNotExist)
+				ms.EXPECT().SaveMetadata(gomock.Any(), "correct", matchMetadata(t, store.Metadata{
+					Size:        43,
+					SHA256:      "d7a8fbb307d7809469ca9abcb0082e4f8d5651e46d3cdb762d02d0bf37c9e592",
+					ContentType: "text/plain; charset=utf-8",
+					Charset:     "utf-8",
+					Labels:      map[string]string{"kind": "pangram"},
+				})).Return(nil)
+				return ms
+			},
 			newSearchFn: func(t *testing.T, ctrl *gomock.Controller) search.Provider {
 				sp := mocks.NewMockProvider(ctrl)
 				expectWords := search.WordsFromString("The quick brown fox jumps over the lazy dog", search.EnglishCommonVerbs)
 				sp.EXPECT().AddDocumentRef(gomock.Any(), "correct", stringsContentsMatch(t, expectWords)).Return(nil)
+				sp.EXPECT().UpdateDocumentLabels(gomock.Any(), "correct", map[string]string{"kind": "pangram"}).Return(nil)
 				return sp
 			},
 		},
 		"should respect index settings": {
 			name: "correct",
 			data: strings.NewReader("The quick brown fox jumps over the lazy dog"),
-			cfg:  TextIndexConfig{IgnoreCommonWords: false},
+			cfg:  store.TextIndexConfig{IgnoreCommonWords: false},
+			opts: store.WriteOptions{ContentType: "text/markdown"},
 
-			newStoreFn: func(t *testing.T, ctrl *gomock.Controller) DocumentStore {
-				store := mocks.NewMockDocumentStore(ctrl)
-				store.EXPECT().
+			newStoreFn: func(t *testing.T, ctrl *gomock.Controller) store.DocumentStore {
+				storeMock := mocks.NewMockDocumentStore(ctrl)
+				storeMock.EXPECT().
 					AddDocument(gomock.Any(), "correct", matchReaderContents(t, []byte("The quick brown fox jumps over the lazy dog"))).
 					Return(nil)
-				return store
+				return storeMock
 			},
 
+			newMetaFn: func(t *testing.T, ctrl *gomock.Controller) store.MetadataStore {
+				ms := mocks.NewMockMetadataStore(ctrl)
+				ms.EXPECT().GetMetadata(gomock.Any(), "correct").Return(nil, fs.ErrNotExist)
+				ms.EXPECT().SaveMetadata(gomock.Any(), "correct", matchMetadata(t, store.Metadata{
+					Size:        43,
+					SHA256:      "d7a8fbb307d7809469ca9abcb0082e4f8d5651e46d3cdb762d02d0bf37c9e592",
+					ContentType: "text/markdown; charset=utf-8",
+					Charset:     "utf-8",
+				})).Return(nil)
+				return ms
+			},
 			newSearchFn: func(t *testing.T, ctrl *gomock.Controller) search.Provider {
 				sp := mocks.NewMockProvider(ctrl)
 				expectWords := search.WordsFromString("The quick brown fox jumps over the lazy dog", nil)
@@ -72,18 +106,85 @@ func TestSyncedDocumentStore_AddDocument(t *testing.T) {
 				return sp
 			},
 		},
+		"should index configured fields of structured documents": {
+			name: "book.json",
+			data: strings.NewReader(`{"title":"Metamorphosis","author":{"name":"Kafka"},"year":1915}`),
+			cfg:  store.TextIndexConfig{FieldPaths: []string{"Author"}},
+
+			newStoreFn: func(t *testing.T, ctrl *gomock.Controller) store.DocumentStore {
+				storeMock := mocks.NewMockDocumentStore(ctrl)
+				storeMock.EXPECT().
+					AddDocument(gomock.Any(), "book.json", matchReaderContents(t, []byte(`{"title":"Metamorphosis","author":{"name":"Kafka"},"year":1915}`))).
+					Return(nil)
+				return storeMock
+			},
+
+			newMetaFn: func(t *testing.T, ctrl *gomock.Controller) store.MetadataStore {
+				ms := mocks.NewMockMetadataStore(ctrl)
+				ms.EXPECT().GetMetadata(gomock.Any(), "book.json").Return(nil, fs.ErrNotExist)
+				ms.EXPECT().SaveMetadata(gomock.Any(), "book.json", matchMetadata(t, store.Metadata{
+					Size:        63,
+					SHA256:      "e087645b829ae45ca8214f6e4427c2324e55fa3888e4e077fbb125bea08b5974",
+					ContentType: "application/json; charset=utf-8",
+					Charset:     "utf-8",
+				})).Return(nil)
+				return ms
+			},
+			newSearchFn: func(t *testing.T, ctrl *gomock.Controller) search.Provider {
+				sp := mocks.NewMockProvider(ctrl)
+				expectWords := []string{"metamorphosis", "kafka", "1915", search.FieldTerm("author", "kafka")}
+				sp.EXPECT().AddDocumentRef(gomock.Any(), "book.json", stringsContentsMatch(t, expectWords)).Return(nil)
+				return sp
+			},
+		},
+		"should index source code with code analyzer": {
+			name: "main.go",
+			data: strings.NewReader("func SearchDocuments() {}"),
+
+			newStoreFn: func(t *testing.T, ctrl *gomock.Controller) store.DocumentStore {
+				storeMock := mocks.NewMockDocumentStore(ctrl)
+				storeMock.EXPECT().
+					AddDocument(gomock.Any(), "main.go", matchReaderContents(t, []byte("func SearchDocuments() {}"))).
+					Return(nil)
+				return storeMock
+			},
+
+			newMetaFn: func(t *testing.T, ctrl *gomock.Controller) store.MetadataStore {
+				ms := mocks.NewMockMetadataStore(ctrl)
+				ms.EXPECT().GetMetadata(gomock.Any(), "main.go").Return(nil, fs.ErrNotExist)
+				ms.EXPECT().SaveMetadata(gomock.Any(), "main.go", matchMetadata(t, store.Metadata{
+					Size:        25,
+					SHA256:      "d91fe8f128bc1eed029ffb769361ae8eb8adae8bd8c41a03e6bcb345bb80bf68",
+					ContentType: "text/plain; charset=utf-8",
+					Charset:     "utf-8",
+					Analyzer:    search.AnalyzerCode,
+				})).Return(nil)
+				return ms
+			},
+			newSearchFn: func(t *testing.T, ctrl *gomock.Controller) search.Provider {
+				sp := mocks.NewMockProvider(ctrl)
+				expectWords := []string{"func", "searchdocuments", "search", "documents"}
+				sp.EXPECT().AddDocumentRef(gomock.Any(), "main.go", stringsContentsMatch(t, expectWords)).Return(nil)
+				return sp
+			},
+		},
 		"should raise errors from inner storage": {
 			name: "bad",
 			data: strings.NewReader("foobar"),
 			wantErrFn: func(err error) bool {
 				return errors.Is(err, fs.ErrExist)
 			},
-			newStoreFn: func(t *testing.T, ctrl *gomock.Controller) DocumentStore {
-				store := mocks.NewMockDocumentStore(ctrl)
-				store.EXPECT().
+			newStoreFn: func(t *testing.T, ctrl *gomock.Controller) store.DocumentStore {
+				storeMock := mocks.NewMockDocumentStore(ctrl)
+				storeMock.EXPECT().
 					AddDocument(gomock.Any(), "bad", matchReaderContents(t, []byte("foobar"))).
 					Return(fs.ErrExist)
-				return store
+				return storeMock
+			},
+			newMetaFn: func(t *testing.T, ctrl *gomock.Controller) store.MetadataStore {
+				ms := mocks.NewMockMetadataStore(ctrl)
+				ms.EXPECT().GetMetadata(gomock.Any(), "bad").Return(&store.Metadata{Size: 3}, nil)
+				return ms
 			},
 			newSearchFn: func(t *testing.T, ctrl *gomock.Controller) search.Provider {
 				return nil
@@ -94,9 +195,196 @@ func TestSyncedDocumentStore_AddDocument(t *testing.T) {
 	for n, c := range cases {
 		t.Run(n, func(t *testing.T) {
 			ctrl := gomock.NewController(t)
-			syncStore := NewSyncedDocumentStore(zaptest.NewLogger(t), c.newStoreFn(t, ctrl), c.newSearchFn(t, ctrl), c.cfg)
+			syncStore := store.NewSyncedDocumentStore(zaptest.NewLogger(t), c.newStoreFn(t, ctrl), c.newMetaFn(t, ctrl),
+				c.newSearchFn(t, ctrl), nil, c.cfg)
 
-			err := syncStore.AddDocument(context.TODO(), c.name, c.data)
+			_, err := syncStore.AddDocument(context.TODO(), c.name, c.data, c.opts)
+			if c.wantErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.wantErr)
+				return
+			}
+
+			if c.wantErrFn != nil {
+				require.Error(t, err)
+				require.True(t, c.wantErrFn(err))
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestSyncedDocumentStore_ReplaceDocument(t *testing.T) {
+	cases := map[string]struct {
+		name    string
+		data    io.Reader
+		opts    store.WriteOptions
+		wantErr string
+
+		wantErrFn   func(err error) bool
+		newStoreFn  func(t *testing.T, ctrl *gomock.Controller) store.DocumentStore
+		newMetaFn   func(t *testing.T, ctrl *gomock.Controller) store.MetadataStore
+		newSearchFn func(t *testing.T, ctrl *gomock.Controller) search.Provider
+	}{
+		"should replace document and update words index": {
+			name: "correct",
+			data: strings.NewReader("The quick brown fox jumps over the lazy dog"),
+			newMetaFn: func(t *testing.T, ctrl *gomock.Controller) store.MetadataStore {
+				ms := mocks.NewMockMetadataStore(ctrl)
+				ms.EXPECT().GetMetadata(gomock.Any(), "correct").Return(&store.Metadata{
+					CreatedAt: time.Unix(1000, 0),
+					Labels:    map[string]string{"team": "payments"},
+				}, nil)
+				ms.EXPECT().SaveMetadata(gomock.Any(), "correct", matchMetadata(t, store.Metadata{
+					Size:        43,
+					SHA256:      "d7a8fbb307d7809469ca9abcb0082e4f8d5651e46d3cdb762d02d0bf37c9e592",
+					ContentType: "text/plain; charset=utf-8",
+					Charset:     "utf-8",
+					CreatedAt:   time.Unix(1000, 0),
+					Labels:      map[string]string{"team": "payments"},
+				})).Return(nil)
+				return ms
+			},
+			newStoreFn: func(t *testing.T, ctrl *gomock.Controller) store.DocumentStore {
+				storeMock := mocks.NewMockDocumentStore(ctrl)
+				storeMock.EXPECT().
+					ReplaceDocument(gomock.Any(), "correct", matchReaderContents(t, []byte("The quick brown fox jumps over the lazy dog"))).
+					Return(nil)
+				return storeMock
+			},
+			newSearchFn: func(t *testing.T, ctrl *gomock.Controller) search.Provider {
+				sp := mocks.NewMockProvider(ctrl)
+				expectWords := search.WordsFromString("The quick brown fox jumps over the lazy dog", nil)
+				sp.EXPECT().UpdateDocumentRef(gomock.Any(), "correct", stringsContentsMatch(t, expectWords)).Return(nil)
+				return sp
+			},
+		},
+		"should keep analyzer if it wasn't passed": {
+			name: "notes.txt",
+			data: strings.NewReader("SearchDocuments"),
+			newMetaFn: func(t *testing.T, ctrl *gomock.Controller) store.MetadataStore {
+				ms := mocks.NewMockMetadataStore(ctrl)
+				ms.EXPECT().GetMetadata(gomock.Any(), "notes.txt").Return(&store.Metadata{
+					CreatedAt: time.Unix(1000, 0),
+					Analyzer:  search.AnalyzerCode,
+				}, nil)
+				ms.EXPECT().SaveMetadata(gomock.Any(), "notes.txt", matchMetadata(t, store.Metadata{
+					Size:        15,
+					SHA256:      "810ff897647b2105fedab6bff6a39e8cd1c87cca79948b46fd163336f8d72a5e",
+					ContentType: "text/plain; charset=utf-8",
+					Charset:     "utf-8",
+					CreatedAt:   time.Unix(1000, 0),
+					Analyzer:    search.AnalyzerCode,
+				})).Return(nil)
+				return ms
+			},
+			newStoreFn: func(t *testing.T, ctrl *gomock.Controller) store.DocumentStore {
+				storeMock := mocks.NewMockDocumentStore(ctrl)
+				storeMock.EXPECT().ReplaceDocument(gomock.Any(), "notes.txt", matchReaderContents(t, []byte("SearchDocuments"))).Return(nil)
+				return storeMock
+			},
+			newSearchFn: func(t *testing.T, ctrl *gomock.Controller) search.Provider {
+				sp := mocks.NewMockProvider(ctrl)
+				expectWords := []string{"searchdocuments", "search", "documents"}
+				sp.EXPECT().UpdateDocumentRef(gomock.Any(), "notes.txt", stringsContentsMatch(t, expectWords)).Return(nil)
+				return sp
+			},
+		},
+		"should update labels index if labels were passed": {
+			name: "labeled",
+			data: strings.NewReader("foobar"),
+			opts: store.WriteOptions{Labels: map[string]string{}},
+			newMetaFn: func(t *testing.T, ctrl *gomock.Controller) store.MetadataStore {
+				ms := mocks.NewMockMetadataStore(ctrl)
+				ms.EXPECT().GetMetadata(gomock.Any(), "labeled").Return(&store.Metadata{
+					Labels: map[string]string{"team": "payments"},
+				}, nil)
+				ms.EXPECT().SaveMetadata(gomock.Any(), "labeled", gomock.Any()).Return(nil)
+				return ms
+			},
+			newStoreFn: func(t *testing.T, ctrl *gomock.Controller) store.DocumentStore {
+				storeMock := mocks.NewMockDocumentStore(ctrl)
+				storeMock.EXPECT().ReplaceDocument(gomock.Any(), "labeled", matchReaderContents(t, []byte("foobar"))).Return(nil)
+				return storeMock
+			},
+			newSearchFn: func(t *testing.T, ctrl *gomock.Controller) search.Provider {
+				sp := mocks.NewMockProvider(ctrl)
+				sp.EXPECT().UpdateDocumentRef(gomock.Any(), "labeled", []string{"foobar"}).Return(nil)
+				sp.EXPECT().UpdateDocumentLabels(gomock.Any(), "labeled", map[string]string{}).Return(nil)
+				return sp
+			},
+		},
+		"should fail if precondition not satisfied": {
+			name: "foobar",
+			data: strings.NewReader("foobar"),
+			opts: store.WriteOptions{Precondition: store.Precondition{IfNoneMatch: []string{store.AnyChecksum}}},
+			wantErrFn: func(err error) bool {
+				return errors.Is(err, store.ErrPreconditionFailed)
+			},
+			newMetaFn: func(t *testing.T, ctrl *gomock.Controller) store.MetadataStore {
+				ms := mocks.NewMockMetadataStore(ctrl)
+				ms.EXPECT().GetMetadata(gomock.Any(), "foobar").Return(&store.Metadata{SHA256: "bar"}, nil)
+				return ms
+			},
+			newStoreFn: func(t *testing.T, ctrl *gomock.Controller) store.DocumentStore {
+				return nil
+			},
+			newSearchFn: func(t *testing.T, ctrl *gomock.Controller) search.Provider {
+				return nil
+			},
+		},
+		"should return index update error": {
+			name:    "foobar",
+			data:    strings.NewReader("foobar"),
+			wantErr: "failed to update document index: test",
+			newMetaFn: func(t *testing.T, ctrl *gomock.Controller) store.MetadataStore {
+				ms := mocks.NewMockMetadataStore(ctrl)
+				ms.EXPECT().GetMetadata(gomock.Any(), "foobar").Return(nil, fs.ErrNotExist)
+				ms.EXPECT().SaveMetadata(gomock.Any(), "foobar", gomock.Any()).Return(nil)
+				return ms
+			},
+			newStoreFn: func(t *testing.T, ctrl *gomock.Controller) store.DocumentStore {
+				storeMock := mocks.NewMockDocumentStore(ctrl)
+				storeMock.EXPECT().ReplaceDocument(gomock.Any(), "foobar", gomock.Any()).Return(nil)
+				return storeMock
+			},
+			newSearchFn: func(t *testing.T, ctrl *gomock.Controller) search.Provider {
+				sp := mocks.NewMockProvider(ctrl)
+				sp.EXPECT().UpdateDocumentRef(gomock.Any(), "foobar", gomock.Any()).Return(errors.New("test"))
+				return sp
+			},
+		},
+		"should not touch index if document wasn't stored": {
+			name: "bad",
+			data: strings.NewReader("foobar"),
+			wantErrFn: func(err error) bool {
+				return errors.Is(err, fs.ErrPermission)
+			},
+			newMetaFn: func(t *testing.T, ctrl *gomock.Controller) store.MetadataStore {
+				ms := mocks.NewMockMetadataStore(ctrl)
+				ms.EXPECT().GetMetadata(gomock.Any(), "bad").Return(nil, fs.ErrNotExist)
+				return ms
+			},
+			newStoreFn: func(t *testing.T, ctrl *gomock.Controller) store.DocumentStore {
+				storeMock := mocks.NewMockDocumentStore(ctrl)
+				storeMock.EXPECT().ReplaceDocument(gomock.Any(), "bad", gomock.Any()).Return(fs.ErrPermission)
+				return storeMock
+			},
+			newSearchFn: func(t *testing.T, ctrl *gomock.Controller) search.Provider {
+				return nil
+			},
+		},
+	}
+
+	for n, c := range cases {
+		t.Run(n, func(t *testing.T) {
+			ctrl := gomock.NewController(t)
+			syncStore := store.NewSyncedDocumentStore(zaptest.NewLogger(t), c.newStoreFn(t, ctrl), c.newMetaFn(t, ctrl),
+				c.newSearchFn(t, ctrl), nil, store.TextIndexConfig{})
+
+			_, err := syncStore.ReplaceDocument(context.TODO(), c.name, c.data, c.opts)
 			if c.wantErr != "" {
 				require.Error(t, err)
 				require.Contains(t, err.Error(), c.wantErr)
@@ -117,19 +405,45 @@ func TestSyncedDocumentStore_AddDocument(t *testing.T) {
 func TestSyncedDocumentStore_RemoveDocument(t *testing.T) {
 	cases := map[string]struct {
 		name    string
+		cond    store.Precondition
 		wantErr string
 
 		wantErrFn   func(err error) bool
-		newStoreFn  func(t *testing.T, ctrl *gomock.Controller) DocumentStore
+		newStoreFn  func(t *testing.T, ctrl *gomock.Controller) store.DocumentStore
+		newMetaFn   func(t *testing.T, ctrl *gomock.Controller) store.MetadataStore
 		newSearchFn func(t *testing.T, ctrl *gomock.Controller) search.Provider
 	}{
+		"should remove document from index and metadata": {
+			name: "foobar",
+			newStoreFn: func(t *testing.T, ctrl *gomock.Controller) store.DocumentStore {
+				storeMock := mocks.NewMockDocumentStore(ctrl)
+				storeMock.EXPECT().RemoveDocument(gomock.Any(), "foobar").Return(nil)
+				return storeMock
+			},
+			newMetaFn: func(t *testing.T, ctrl *gomock.Controller) store.MetadataStore {
+				ms := mocks.NewMockMetadataStore(ctrl)
+				ms.EXPECT().GetMetadata(gomock.Any(), "foobar").Return(&store.Metadata{SHA256: "bar"}, nil)
+				ms.EXPECT().RemoveMetadata(gomock.Any(), "foobar").Return(nil)
+				return ms
+			},
+			newSearchFn: func(t *testing.T, ctrl *gomock.Controller) search.Provider {
+				sp := mocks.NewMockProvider(ctrl)
+				sp.EXPECT().RemoveDocumentRef(gomock.Any(), "foobar").Return(nil)
+				return sp
+			},
+		},
 		"should remove document from index": {
 			name:    "foobar",
 			wantErr: "failed to remove document from search index: test",
-			newStoreFn: func(t *testing.T, ctrl *gomock.Controller) DocumentStore {
-				store := mocks.NewMockDocumentStore(ctrl)
-				store.EXPECT().RemoveDocument(gomock.Any(), "foobar").Return(nil)
-				return store
+			newStoreFn: func(t *testing.T, ctrl *gomock.Controller) store.DocumentStore {
+				storeMock := mocks.NewMockDocumentStore(ctrl)
+				storeMock.EXPECT().RemoveDocument(gomock.Any(), "foobar").Return(nil)
+				return storeMock
+			},
+			newMetaFn: func(t *testing.T, ctrl *gomock.Controller) store.MetadataStore {
+				ms := mocks.NewMockMetadataStore(ctrl)
+				ms.EXPECT().GetMetadata(gomock.Any(), "foobar").Return(nil, fs.ErrNotExist)
+				return ms
 			},
 			newSearchFn: func(t *testing.T, ctrl *gomock.Controller) search.Provider {
 				sp := mocks.NewMockProvider(ctrl)
@@ -137,28 +451,77 @@ func TestSyncedDocumentStore_RemoveDocument(t *testing.T) {
 				return sp
 			},
 		},
+		"should check precondition": {
+			name: "foobar",
+			cond: store.Precondition{IfMatch: []string{"foo"}},
+			wantErrFn: func(err error) bool {
+				return errors.Is(err, store.ErrPreconditionFailed)
+			},
+			newStoreFn: func(t *testing.T, ctrl *gomock.Controller) store.DocumentStore {
+				return nil
+			},
+			newMetaFn: func(t *testing.T, ctrl *gomock.Controller) store.MetadataStore {
+				ms := mocks.NewMockMetadataStore(ctrl)
+				ms.EXPECT().GetMetadata(gomock.Any(), "foobar").Return(&store.Metadata{SHA256: "bar"}, nil)
+				return ms
+			},
+			newSearchFn: func(t *testing.T, ctrl *gomock.Controller) search.Provider {
+				return nil
+			},
+		},
 		"should stop if document wasn't removed properly": {
 			name: "foobar",
 			wantErrFn: func(err error) bool {
 				return errors.Is(err, fs.ErrNotExist)
 			},
-			newStoreFn: func(t *testing.T, ctrl *gomock.Controller) DocumentStore {
-				store := mocks.NewMockDocumentStore(ctrl)
-				store.EXPECT().RemoveDocument(gomock.Any(), "foobar").Return(fs.ErrNotExist)
-				return store
+			newStoreFn: func(t *testing.T, ctrl *gomock.Controller) store.DocumentStore {
+				storeMock := mocks.NewMockDocumentStore(ctrl)
+				storeMock.EXPECT().RemoveDocument(gomock.Any(), "foobar").Return(fs.ErrNotExist)
+				return storeMock
+			},
+			newMetaFn: func(t *testing.T, ctrl *gomock.Controller) store.MetadataStore {
+				ms := mocks.NewMockMetadataStore(ctrl)
+				ms.EXPECT().GetMetadata(gomock.Any(), "foobar").Return(nil, fs.ErrNotExist)
+				return ms
 			},
 			newSearchFn: func(t *testing.T, ctrl *gomock.Controller) search.Provider {
 				return nil
 			},
 		},
+		"should treat expired document as missing": {
+			name: "foobar",
+			wantErrFn: func(err error) bool {
+				return errors.Is(err, fs.ErrNotExist)
+			},
+			newStoreFn: func(t *testing.T, ctrl *gomock.Controller) store.DocumentStore {
+				storeMock := mocks.NewMockDocumentStore(ctrl)
+				gomock.InOrder(
+					storeMock.EXPECT().RemoveDocument(gomock.Any(), "foobar").Return(nil),
+					storeMock.EXPECT().RemoveDocument(gomock.Any(), "foobar").Return(fs.ErrNotExist),
+				)
+				return storeMock
+			},
+			newMetaFn: func(t *testing.T, ctrl *gomock.Controller) store.MetadataStore {
+				expiresAt := time.Now().Add(-time.Minute)
+				ms := mocks.NewMockMetadataStore(ctrl)
+				ms.EXPECT().GetMetadata(gomock.Any(), "foobar").Return(&store.Metadata{ExpiresAt: &expiresAt}, nil)
+				ms.EXPECT().RemoveMetadata(gomock.Any(), "foobar").Return(nil)
+				return ms
+			},
+			newSearchFn: func(t *testing.T, ctrl *gomock.Controller) search.Provider {
+				sp := mocks.NewMockProvider(ctrl)
+				sp.EXPECT().RemoveDocumentRef(gomock.Any(), "foobar").Return(nil)
+				return sp
+			},
+		},
 	}
 
 	for n, c := range cases {
 		t.Run(n, func(t *testing.T) {
 			ctrl := gomock.NewController(t)
-			syncedStore := NewSyncedDocumentStore(zaptest.NewLogger(t), c.newStoreFn(t, ctrl),
-				c.newSearchFn(t, ctrl), TextIndexConfig{})
-			err := syncedStore.RemoveDocument(context.TODO(), c.name)
+			syncedStore := store.NewSyncedDocumentStore(zaptest.NewLogger(t), c.newStoreFn(t, ctrl),
+				c.newMetaFn(t, ctrl), c.newSearchFn(t, ctrl), nil, store.TextIndexConfig{})
+			err := syncedStore.RemoveDocument(context.TODO(), c.name, c.cond)
 			if c.wantErr != "" {
 				require.Error(t, err)
 				require.Contains(t, err.Error(), c.wantErr)
@@ -181,11 +544,75 @@ func TestSyncedDocumentStore_GetDocument(t *testing.T) {
 	ctrl := gomock.NewController(t)
 	storeMock := mocks.NewMockDocumentStore(ctrl)
 	storeMock.EXPECT().GetDocument("testdoc").Return(nil, errors.New(wantErr))
-	syncStore := NewSyncedDocumentStore(nil, storeMock, nil, TextIndexConfig{})
+	syncStore := store.NewSyncedDocumentStore(nil, storeMock, nil, nil, nil, store.TextIndexConfig{})
 	_, err := syncStore.GetDocument("testdoc")
 	require.EqualError(t, err, wantErr)
 }
 
+func TestSyncedDocumentStore_GetMetadata(t *testing.T) {
+	t.Run("should return stored metadata", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		want := &store.Metadata{Size: 3, SHA256: "foo"}
+		metaMock := mocks.NewMockMetadataStore(ctrl)
+		metaMock.EXPECT().GetMetadata(gomock.Any(), "testdoc").Return(want, nil)
+
+		syncStore := store.NewSyncedDocumentStore(nil, nil, metaMock, nil, nil, store.TextIndexConfig{})
+		got, err := syncStore.GetMetadata(context.TODO(), "testdoc")
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	})
+
+	t.Run("should calculate missing metadata", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		storeMock := mocks.NewMockDocumentStore(ctrl)
+		storeMock.EXPECT().GetDocument("testdoc").
+			Return(ioutil.NopCloser(strings.NewReader("The quick brown fox jumps over the lazy dog")), nil)
+
+		want := store.Metadata{
+			Size:        43,
+			SHA256:      "d7a8fbb307d7809469ca9abcb0082e4f8d5651e46d3cdb762d02d0bf37c9e592",
+			ContentType: "text/plain; charset=utf-8",
+			Charset:     "utf-8",
+		}
+		metaMock := mocks.NewMockMetadataStore(ctrl)
+		// Metadata is checked again after document lock is acquired.
+		metaMock.EXPECT().GetMetadata(gomock.Any(), "testdoc").Return(nil, fs.ErrNotExist).Times(2)
+		metaMock.EXPECT().SaveMetadata(gomock.Any(), "testdoc", matchMetadata(t, want)).Return(nil)
+
+		syncStore := store.NewSyncedDocumentStore(nil, storeMock, metaMock, nil, nil, store.TextIndexConfig{})
+		got, err := syncStore.GetMetadata(context.TODO(), "testdoc")
+		require.NoError(t, err)
+		require.Equal(t, want.SHA256, got.SHA256)
+	})
+
+	t.Run("should return error if document not exists", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		storeMock := mocks.NewMockDocumentStore(ctrl)
+		storeMock.EXPECT().GetDocument("testdoc").Return(nil, fs.ErrNotExist)
+		metaMock := mocks.NewMockMetadataStore(ctrl)
+		metaMock.EXPECT().GetMetadata(gomock.Any(), "testdoc").Return(nil, fs.ErrNotExist).Times(2)
+
+		syncStore := store.NewSyncedDocumentStore(nil, storeMock, metaMock, nil, nil, store.TextIndexConfig{})
+		_, err := syncStore.GetMetadata(context.TODO(), "testdoc")
+		require.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("should not calculate metadata saved while waiting for lock", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		want := &store.Metadata{Size: 3, SHA256: "foo"}
+		metaMock := mocks.NewMockMetadataStore(ctrl)
+		gomock.InOrder(
+			metaMock.EXPECT().GetMetadata(gomock.Any(), "testdoc").Return(nil, fs.ErrNotExist),
+			metaMock.EXPECT().GetMetadata(gomock.Any(), "testdoc").Return(want, nil),
+		)
+
+		syncStore := store.NewSyncedDocumentStore(nil, mocks.NewMockDocumentStore(ctrl), metaMock, nil, nil, store.TextIndexConfig{})
+		got, err := syncStore.GetMetadata(context.TODO(), "testdoc")
+		require.NoError(t, err)
+		require.Equal(t, want, got)
+	})
+}
+
 type readerMatcher struct {
 	t    *testing.T
 	want []byte
@@ -233,3 +660,338 @@ func (m stringsContentsMatcher) Matches(v interface{}) bool {
 func (m stringsContentsMatcher) String() string {
 	return fmt.Sprintln(m.want)
 }
+
+// metadataMatcher matches document metadata ignoring modification time.
+type metadataMatcher struct {
+	t    *testing.T
+	want store.Metadata
+}
+
+func matchMetadata(t *testing.T, want store.Metadata) gomock.Matcher {
+	return metadataMatcher{t: t, want: want}
+}
+
+func (m metadataMatcher) Matches(v interface{}) bool {
+	got, ok := v.(*store.Metadata)
+	if !ok {
+		return false
+	}
+
+	if m.want.CreatedAt.IsZero() {
+		assert.False(m.t, got.CreatedAt.IsZero(), "creation time is empty")
+	} else {
+		assert.True(m.t, m.want.CreatedAt.Equal(got.CreatedAt), "creation time mismatch")
+	}
+
+	assert.False(m.t, got.UpdatedAt.IsZero(), "update time is empty")
+	gotCopy := *got
+	gotCopy.CreatedAt, gotCopy.UpdatedAt = m.want.CreatedAt, m.want.UpdatedAt
+	return assert.Equal(m.t, m.want, gotCopy)
+}
+
+func (m metadataMatcher) String() string {
+	return fmt.Sprintf("metadata: %+v", m.want)
+}
+
+func TestSyncedDocumentStore_ConcurrentWrites(t *testing.T) {
+	const (
+		docName = "doc.txt"
+		rounds  = 50
+	)
+
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "docsearch-test-*")
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tmpDir))
+	}()
+
+	ctx := context.TODO()
+	fileStore := store.NewFileDocumentStore(tmpDir)
+	metaStore := newMemoryMetaStore()
+	index := newMemoryIndex()
+	syncStore := store.NewSyncedDocumentStore(zaptest.NewLogger(t), fileStore, metaStore, index, nil,
+		store.TextIndexConfig{})
+
+	for i := 0; i < rounds; i++ {
+		_, err := syncStore.ReplaceDocument(ctx, docName, strings.NewReader("foo bar"), store.WriteOptions{})
+		require.NoError(t, err)
+
+		// Concurrent delete and upload of the same document should not interleave.
+		wg := new(sync.WaitGroup)
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_ = syncStore.RemoveDocument(ctx, docName, store.Precondition{})
+		}()
+		go func() {
+			defer wg.Done()
+			_, _ = syncStore.AddDocument(ctx, docName, strings.NewReader("foo bar"), store.WriteOptions{})
+		}()
+		wg.Wait()
+
+		_, err = os.Stat(filepath.Join(tmpDir, docName))
+		fileExists := err == nil
+		_, err = metaStore.GetMetadata(ctx, docName)
+		metaExists := err == nil
+		indexed := index.hasDocument(docName)
+		require.Equal(t, fileExists, indexed, "round %d: file exists - %t, indexed - %t", i, fileExists, indexed)
+		require.Equal(t, fileExists, metaExists, "round %d: file exists - %t, meta exists - %t", i, fileExists, metaExists)
+	}
+}
+
+func TestSyncedDocumentStore_Trash(t *testing.T) {
+	const docName = "doc.txt"
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "docsearch-test-*")
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tmpDir))
+	}()
+
+	newTrash := func(retention time.Duration) *store.Trash {
+		return store.NewTrash(store.NewFileDocumentStore(filepath.Join(tmpDir, store.TrashDirName)),
+			newMemoryMetaStore(), retention)
+	}
+
+	ctx := context.TODO()
+	metaStore := newMemoryMetaStore()
+	index := newMemoryIndex()
+	syncStore := store.NewSyncedDocumentStore(zaptest.NewLogger(t), store.NewFileDocumentStore(tmpDir), metaStore,
+		index, nil, store.TextIndexConfig{}).WithTrash(newTrash(time.Hour))
+
+	labels := map[string]string{"kind": "test"}
+	origMeta, err := syncStore.AddDocument(ctx, docName, strings.NewReader("foo bar"), store.WriteOptions{Labels: labels})
+	require.NoError(t, err)
+
+	// Removed document should be hidden
+	require.NoError(t, syncStore.RemoveDocument(ctx, docName, store.Precondition{}))
+	require.False(t, index.hasDocument(docName))
+	_, err = syncStore.GetDocument(docName)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = syncStore.GetMetadata(ctx, docName)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	require.ErrorIs(t, syncStore.RemoveDocument(ctx, docName, store.Precondition{}), fs.ErrNotExist)
+
+	// Documents are kept until retention period passes.
+	purged, err := syncStore.PurgeTrash(ctx)
+	require.NoError(t, err)
+	require.Zero(t, purged)
+
+	// Restored document should be indexed again with the same metadata
+	meta, err := syncStore.RestoreDocument(ctx, docName)
+	require.NoError(t, err)
+	require.Equal(t, origMeta.SHA256, meta.SHA256)
+	require.Equal(t, origMeta.CreatedAt.Unix(), meta.CreatedAt.Unix())
+	require.Equal(t, labels, meta.Labels)
+	require.Nil(t, meta.DeletedAt)
+	require.True(t, index.hasDocument(docName))
+	require.Equal(t, labels, index.labels[docName])
+	_, err = syncStore.RestoreDocument(ctx, docName)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	// Restore shouldn't overwrite a document uploaded after removal
+	require.NoError(t, syncStore.RemoveDocument(ctx, docName, store.Precondition{}))
+	_, err = syncStore.AddDocument(ctx, docName, strings.NewReader("baz"), store.WriteOptions{})
+	require.NoError(t, err)
+	_, err = syncStore.RestoreDocument(ctx, docName)
+	require.ErrorIs(t, err, fs.ErrExist)
+
+	// Expired documents should be purged
+	syncStore.WithTrash(newTrash(time.Nanosecond))
+	purged, err = syncStore.PurgeTrash(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, purged)
+	_, err = syncStore.RestoreDocument(ctx, docName)
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestSyncedDocumentStore_TrashExpiry(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "docsearch-test-*")
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tmpDir))
+	}()
+
+	ctx := context.TODO()
+	trashMetaStore := newMemoryMetaStore()
+	trash := store.NewTrash(store.NewFileDocumentStore(filepath.Join(tmpDir, store.TrashDirName)),
+		trashMetaStore, time.Hour)
+	syncStore := store.NewSyncedDocumentStore(zaptest.NewLogger(t), store.NewFileDocumentStore(tmpDir),
+		newMemoryMetaStore(), newMemoryIndex(), nil, store.TextIndexConfig{}).WithTrash(trash)
+
+	// Restored document should keep expiration time
+	expiresAt := time.Now().Add(time.Hour)
+	_, err = syncStore.AddDocument(ctx, "doc", strings.NewReader("foo"), store.WriteOptions{ExpiresAt: &expiresAt})
+	require.NoError(t, err)
+	require.NoError(t, syncStore.RemoveDocument(ctx, "doc", store.Precondition{}))
+	meta, err := syncStore.RestoreDocument(ctx, "doc")
+	require.NoError(t, err)
+	require.NotNil(t, meta.ExpiresAt)
+	require.Equal(t, expiresAt.Unix(), meta.ExpiresAt.Unix())
+
+	// Document which expired in trash can't be restored
+	require.NoError(t, syncStore.RemoveDocument(ctx, "doc", store.Precondition{}))
+	trashMeta, err := trashMetaStore.GetMetadata(ctx, "doc")
+	require.NoError(t, err)
+	expired := time.Now().Add(-time.Second)
+	trashMeta.ExpiresAt = &expired
+	require.NoError(t, trashMetaStore.SaveMetadata(ctx, "doc", trashMeta))
+	_, err = syncStore.RestoreDocument(ctx, "doc")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = syncStore.GetDocument("doc")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	// Expired documents should be removed without moving to trash
+	_, err = syncStore.AddDocument(ctx, "expired", strings.NewReader("bar"), store.WriteOptions{ExpiresAt: &expired})
+	require.NoError(t, err)
+	removed, err := syncStore.RemoveExpired(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, removed)
+	_, err = trashMetaStore.GetMetadata(ctx, "expired")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	_, err = syncStore.RestoreDocument(ctx, "expired")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestSyncedDocumentStore_Expiry(t *testing.T) {
+	tmpDir, err := ioutil.TempDir(os.TempDir(), "docsearch-test-*")
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, os.RemoveAll(tmpDir))
+	}()
+
+	ctx := context.TODO()
+	metaStore := newMemoryMetaStore()
+	index := newMemoryIndex()
+	syncStore := store.NewSyncedDocumentStore(zaptest.NewLogger(t), store.NewFileDocumentStore(tmpDir), metaStore,
+		index, nil, store.TextIndexConfig{})
+
+	expired := time.Now().Add(-time.Second)
+	notExpired := time.Now().Add(time.Hour)
+	_, err = syncStore.AddDocument(ctx, "expired", strings.NewReader("foo"), store.WriteOptions{ExpiresAt: &expired})
+	require.NoError(t, err)
+	_, err = syncStore.AddDocument(ctx, "alive", strings.NewReader("bar"), store.WriteOptions{ExpiresAt: &notExpired})
+	require.NoError(t, err)
+
+	// Expired document should be hidden before removal
+	_, err = syncStore.GetMetadata(ctx, "expired")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	meta, err := syncStore.GetMetadata(ctx, "alive")
+	require.NoError(t, err)
+	require.Equal(t, notExpired.Unix(), meta.ExpiresAt.Unix())
+
+	removed, err := syncStore.RemoveExpired(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, removed)
+	require.False(t, index.hasDocument("expired"))
+	require.True(t, index.hasDocument("alive"))
+	_, err = os.Stat(filepath.Join(tmpDir, "expired"))
+	require.ErrorIs(t, err, fs.ErrNotExist)
+
+	// Expiration time should be kept on replace
+	meta, err = syncStore.ReplaceDocument(ctx, "alive", strings.NewReader("baz"), store.WriteOptions{})
+	require.NoError(t, err)
+	require.Equal(t, notExpired.Unix(), meta.ExpiresAt.Unix())
+
+	// Expired document can be replaced by a new upload before removal
+	_, err = syncStore.ReplaceDocument(ctx, "alive", strings.NewReader("baz"), store.WriteOptions{ExpiresAt: &expired})
+	require.NoError(t, err)
+	_, err = syncStore.AddDocument(ctx, "alive", strings.NewReader("new"), store.WriteOptions{})
+	require.NoError(t, err)
+	meta, err = syncStore.GetMetadata(ctx, "alive")
+	require.NoError(t, err)
+	require.Nil(t, meta.ExpiresAt)
+}
+
+// memoryIndex is in-memory search index stub which widens race window on document removal.
+type memoryIndex struct {
+	search.Provider
+	mu     sync.Mutex
+	docs   map[string][]string
+	labels map[string]map[string]string
+}
+
+func newMemoryIndex() *memoryIndex {
+	return &memoryIndex{docs: map[string][]string{}, labels: map[string]map[string]string{}}
+}
+
+func (m *memoryIndex) UpdateDocumentLabels(_ context.Context, docId string, labels map[string]string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.labels[docId] = labels
+	return nil
+}
+
+func (m *memoryIndex) hasDocument(docId string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.docs[docId]
+	return ok
+}
+
+func (m *memoryIndex) AddDocumentRef(_ context.Context, docId string, words []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.docs[docId] = words
+	return nil
+}
+
+func (m *memoryIndex) UpdateDocumentRef(ctx context.Context, docId string, words []string) error {
+	return m.AddDocumentRef(ctx, docId, words)
+}
+
+func (m *memoryIndex) RemoveDocumentRef(_ context.Context, docId string) error {
+	time.Sleep(time.Millisecond)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.docs, docId)
+	delete(m.labels, docId)
+	return nil
+}
+
+// memoryMetaStore is in-memory metadata store stub.
+type memoryMetaStore struct {
+	mu    sync.Mutex
+	items map[string]store.Metadata
+}
+
+func newMemoryMetaStore() *memoryMetaStore {
+	return &memoryMetaStore{items: map[string]store.Metadata{}}
+}
+
+func (m *memoryMetaStore) GetMetadata(_ context.Context, name string) (*store.Metadata, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	meta, ok := m.items[name]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+
+	return &meta, nil
+}
+
+func (m *memoryMetaStore) SaveMetadata(_ context.Context, name string, meta *store.Metadata) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items[name] = *meta
+	return nil
+}
+
+func (m *memoryMetaStore) RemoveMetadata(_ context.Context, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.items, name)
+	return nil
+}
+
+func (m *memoryMetaStore) ListExpired(_ context.Context, before time.Time, limit int) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.items))
+	for name, meta := range m.items {
+		if len(names) < limit && meta.IsExpired(before) {
+			names = append(names, name)
+		}
+	}
+
+	return names, nil
+}
@@ -0,0 +1,263 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultPurgeInterval is default interval between trash purges.
+const DefaultPurgeInterval = time.Hour
+
+// purgeBatchSize is number of trash items processed per listing page.
+const purgeBatchSize = 100
+
+// Trash keeps removed documents for a retention period until they are purged.
+//
+// Removed documents are stored in a separate document and metadata storage,
+// so they are not visible for search and document reads.
+type Trash struct {
+	store     DocumentStore
+	metaStore MetadataStore
+	retention time.Duration
+}
+
+// NewTrash constructs a new trash.
+//
+// Documents are kept in trash store at least for retention period.
+func NewTrash(store DocumentStore, metaStore MetadataStore, retention time.Duration) *Trash {
+	return &Trash{store: store, metaStore: metaStore, retention: retention}
+}
+
+// WithTrash enables moving removed documents to trash instead of permanent removal.
+func (s *SyncedDocumentStore) WithTrash(trash *Trash) *SyncedDocumentStore {
+	s.trash = trash
+	return s
+}
+
+// moveToTrash moves document contents and metadata into trash.
+func (s SyncedDocumentStore) moveToTrash(ctx context.Context, name string) error {
+	meta, err := s.metadata(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	r, err := s.store.GetDocument(name)
+	if err != nil {
+		return err
+	}
+
+	defer r.Close()
+	if err := s.trash.store.ReplaceDocument(ctx, name, r); err != nil {
+		return fmt.Errorf("failed to move document to trash: %w", err)
+	}
+
+	deletedAt := time.Now()
+	meta.DeletedAt = &deletedAt
+	if err := s.trash.metaStore.SaveMetadata(ctx, name, meta); err != nil {
+		return fmt.Errorf("failed to save trash metadata: %w", err)
+	}
+
+	return nil
+}
+
+// RestoreDocument restores a removed document from trash and adds it back to search index.
+//
+// Document expiration time is kept, so documents which expired in trash can't be restored.
+//
+// Returns fs.ErrNotExist if document is not in trash or expired
+// and fs.ErrExist if a document with the same name was uploaded after removal.
+func (s SyncedDocumentStore) RestoreDocument(ctx context.Context, name string) (*Metadata, error) {
+	if s.trash == nil {
+		return nil, fs.ErrNotExist
+	}
+
+	unlock, err := s.lockDocument(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer unlock()
+	r, err := s.trash.store.GetDocument(name)
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+	prevMeta, err := s.trash.metaStore.GetMetadata(ctx, name)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to get trash metadata: %w", err)
+		}
+
+		prevMeta = nil
+	}
+
+	var opts WriteOptions
+	if prevMeta != nil {
+		if prevMeta.IsExpired(time.Now()) {
+			return nil, fs.ErrNotExist
+		}
+
+		opts.ContentType = prevMeta.ContentType
+		opts.Labels = prevMeta.Labels
+		opts.Analyzer = prevMeta.Analyzer
+		opts.ExpiresAt = prevMeta.ExpiresAt
+	}
+
+	data, err := s.limitQuota(ctx, name, r, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := newDocumentBuffer()
+	if err := s.store.AddDocument(ctx, name, doc.tee(data)); err != nil {
+		s.releaseQuota(ctx, name, nil)
+		return nil, err
+	}
+
+	now := time.Now()
+	meta := doc.metadata(name, opts)
+	meta.CreatedAt = now
+	meta.UpdatedAt = now
+	s.updateUsage(ctx, name, usageDelta(meta, nil))
+	if prevMeta != nil {
+		meta.CreatedAt = prevMeta.CreatedAt
+		meta.UpdatedAt = prevMeta.UpdatedAt
+	}
+
+	if err := s.indexDocument(ctx, name, doc, meta); err != nil {
+		return nil, err
+	}
+
+	if err := s.removeFromTrash(ctx, name); err != nil {
+		return nil, err
+	}
+
+	return meta, nil
+}
+
+// PurgeTrash permanently removes documents which were kept in trash longer than retention period.
+//
+// Returns number of purged documents.
+func (s SyncedDocumentStore) PurgeTrash(ctx context.Context) (int, error) {
+	if s.trash == nil {
+		return 0, nil
+	}
+
+	var (
+		purged int
+		cursor string
+	)
+
+	for {
+		page, err := s.trash.store.List(ctx, ListOptions{
+			SortBy: SortByName,
+			Cursor: cursor,
+			Limit:  purgeBatchSize,
+		})
+		if err != nil {
+			return purged, fmt.Errorf("failed to list trash: %w", err)
+		}
+
+		for _, item := range page.Items {
+			ok, err := s.purgeTrashItem(ctx, item)
+			if err != nil {
+				return purged, err
+			}
+
+			if ok {
+				purged++
+			}
+		}
+
+		if page.NextCursor == "" {
+			return purged, nil
+		}
+
+		cursor = page.NextCursor
+	}
+}
+
+// RunTrashPurger periodically purges expired documents from trash until context is cancelled.
+func (s SyncedDocumentStore) RunTrashPurger(ctx context.Context, interval time.Duration) {
+	if s.trash == nil {
+		return
+	}
+
+	if interval <= 0 {
+		interval = DefaultPurgeInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			purged, err := s.PurgeTrash(ctx)
+			if err != nil {
+				s.log.Error("failed to purge trash", zap.Error(err))
+			}
+
+			if purged > 0 {
+				s.log.Info("purged removed documents", zap.Int("count", purged))
+			}
+		}
+	}
+}
+
+// purgeTrashItem removes a document from trash if its retention period has passed.
+func (s SyncedDocumentStore) purgeTrashItem(ctx context.Context, item DocumentInfo) (bool, error) {
+	unlock, err := s.lockDocument(ctx, item.Name)
+	if err != nil {
+		return false, err
+	}
+
+	defer unlock()
+
+	// Document is written to trash on removal, so write time can be used if metadata is missing.
+	deletedAt := item.UploadedAt
+	meta, err := s.trash.metaStore.GetMetadata(ctx, item.Name)
+	switch {
+	case err == nil:
+		if meta.DeletedAt != nil {
+			deletedAt = *meta.DeletedAt
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return false, fmt.Errorf("failed to get trash metadata: %w", err)
+	}
+
+	if time.Since(deletedAt) < s.trash.retention {
+		return false, nil
+	}
+
+	if err := s.removeFromTrash(ctx, item.Name); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			// Document was restored after listing.
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+// removeFromTrash removes document contents and metadata from trash.
+func (s SyncedDocumentStore) removeFromTrash(ctx context.Context, name string) error {
+	if err := s.trash.store.RemoveDocument(ctx, name); err != nil {
+		return err
+	}
+
+	if err := s.trash.metaStore.RemoveMetadata(ctx, name); err != nil {
+		return fmt.Errorf("failed to remove trash metadata: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	usageDocumentsKey = "usage:documents"
+	usageBytesKey     = "usage:bytes"
+	usageTrackedKey   = "usage:tracked"
+)
+
+// RedisUsageStore is Redis-based namespace usage storage.
+//
+// Number of documents and total size of namespaces are stored
+// in "usage:documents" and "usage:bytes" hashes.
+type RedisUsageStore struct {
+	conn redis.Cmdable
+}
+
+func NewRedisUsageStore(conn redis.Cmdable) *RedisUsageStore {
+	return &RedisUsageStore{conn: conn}
+}
+
+// GetUsage implements UsageStore
+func (r RedisUsageStore) GetUsage(ctx context.Context, namespace string) (Usage, error) {
+	var docsCmd, bytesCmd *redis.StringCmd
+	_, err := r.conn.Pipelined(ctx, func(p redis.Pipeliner) error {
+		docsCmd = p.HGet(ctx, usageDocumentsKey, namespace)
+		bytesCmd = p.HGet(ctx, usageBytesKey, namespace)
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return Usage{}, err
+	}
+
+	var usage Usage
+	if usage.Documents, err = usageValue(docsCmd); err != nil {
+		return Usage{}, err
+	}
+
+	if usage.Bytes, err = usageValue(bytesCmd); err != nil {
+		return Usage{}, err
+	}
+
+	return usage, nil
+}
+
+// AddUsage implements UsageStore
+func (r RedisUsageStore) AddUsage(ctx context.Context, namespace string, delta Usage) (Usage, error) {
+	var docsCmd, bytesCmd *redis.IntCmd
+	_, err := r.conn.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		docsCmd = p.HIncrBy(ctx, usageDocumentsKey, namespace, delta.Documents)
+		bytesCmd = p.HIncrBy(ctx, usageBytesKey, namespace, delta.Bytes)
+		return nil
+	})
+	if err != nil {
+		return Usage{}, err
+	}
+
+	return Usage{Documents: docsCmd.Val(), Bytes: bytesCmd.Val()}, nil
+}
+
+// ResetUsage implements UsageStore
+func (r RedisUsageStore) ResetUsage(ctx context.Context, usage map[string]Usage) error {
+	_, err := r.conn.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Del(ctx, usageDocumentsKey, usageBytesKey)
+		for ns, u := range usage {
+			p.HSet(ctx, usageDocumentsKey, ns, u.Documents)
+			p.HSet(ctx, usageBytesKey, ns, u.Bytes)
+		}
+
+		p.Set(ctx, usageTrackedKey, 1, 0)
+		return nil
+	})
+	return err
+}
+
+// IsTracked implements UsageStore
+func (r RedisUsageStore) IsTracked(ctx context.Context) (bool, error) {
+	n, err := r.conn.Exists(ctx, usageTrackedKey).Result()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+func usageValue(cmd *redis.StringCmd) (int64, error) {
+	n, err := cmd.Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+
+	return n, err
+}
@@ -29,6 +29,18 @@ func (s StringsSet) Has(val string) bool {
 	return ok
 }
 
+// Difference returns values which are present in set but absent in other set.
+func (s StringsSet) Difference(other StringsSet) []string {
+	out := make([]string, 0, len(s))
+	for str := range s {
+		if !other.Has(str) {
+			out = append(out, str)
+		}
+	}
+
+	return out
+}
+
 // ToArray returns all values as list of strings.
 func (s StringsSet) ToArray() []string {
 	out := make([]string, 0, len(s))
@@ -26,3 +26,11 @@ func TestStringsSet_Has(t *testing.T) {
 	m := NewStringsSet("foo")
 	require.True(t, m.Has("foo"))
 }
+
+func TestStringsSet_Difference(t *testing.T) {
+	a := NewStringsSet("foo", "bar", "baz")
+	b := NewStringsSet("bar", "qux")
+	require.ElementsMatch(t, []string{"foo", "baz"}, a.Difference(b))
+	require.ElementsMatch(t, []string{"qux"}, b.Difference(a))
+	require.ElementsMatch(t, []string{"foo", "bar", "baz"}, a.Difference(nil))
+}
@@ -0,0 +1,146 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/x1unix/docusearch/internal/models"
+	"github.com/x1unix/docusearch/internal/services/archive"
+	"github.com/x1unix/docusearch/internal/services/store"
+	"go.uber.org/zap"
+)
+
+// archiveEntrySeparator joins archive ID and entry path components into document ID.
+//
+// Document ID can't contain slashes, as it's a part of URL path.
+const archiveEntrySeparator = ":"
+
+type ArchiveHandler struct {
+	log            *zap.Logger
+	documentsStore store.IndexedDocumentStore
+	limits         archive.Limits
+}
+
+// NewArchiveHandler constructs a new archive upload handler.
+//
+// Limits.MaxEntrySize should match max document size.
+func NewArchiveHandler(log *zap.Logger, s store.IndexedDocumentStore, limits archive.Limits) *ArchiveHandler {
+	return &ArchiveHandler{
+		log:            log,
+		documentsStore: s,
+		limits:         limits,
+	}
+}
+
+// UploadArchive stores each file of uploaded ZIP, TAR or gzip-compressed TAR archive as a separate document.
+//
+// Each message of uploaded mbox mailbox is stored as a separate "<n>.eml" document.
+// Document ID is archive ID and entry path joined by archiveEntrySeparator, e.g. "docs:guide:intro.md".
+// Entry is reported as failed if its document ID matches ID of a previous entry, e.g. "a/b" and "a:b".
+// Existing documents are replaced on PUT request. Labels and expiration apply to all documents.
+func (h ArchiveHandler) UploadArchive(c echo.Context) error {
+	archiveID := c.Param("id")
+	replace := c.Request().Method == http.MethodPut
+
+	body, _, err := decodeRequestBody(c.Request())
+	if err != nil {
+		return err
+	}
+
+	opts, err := writeOptionsFromRequest(c.Request())
+	if err != nil {
+		return err
+	}
+
+	// Archive content type doesn't apply to entries and preconditions are not supported.
+	opts.ContentType = ""
+	opts.Precondition = store.Precondition{}
+
+	ctx := c.Request().Context()
+	rsp := models.ArchiveUploadResponse{Items: []models.ArchiveEntryResult{}}
+
+	// Paths of stored entries by document ID, as different paths like "a/b" and "a:b" map to the same ID.
+	entryPaths := make(map[string]string)
+	err = archive.Walk(body, h.limits, func(e archive.Entry) error {
+		result := models.ArchiveEntryResult{Path: e.Path, Status: models.ArchiveEntryStored}
+		if e.Err != nil {
+			result.Status, result.Error = models.ArchiveEntryFailed, e.Err.Error()
+			rsp.Items = append(rsp.Items, result)
+			return nil
+		}
+
+		result.ID = archiveEntryID(archiveID, e.Path)
+		if otherPath, ok := entryPaths[result.ID]; ok {
+			result.Status, result.Error = models.ArchiveEntryFailed,
+				fmt.Sprintf("document ID collides with %q entry", otherPath)
+			rsp.Items = append(rsp.Items, result)
+			return nil
+		}
+
+		entryPaths[result.ID] = e.Path
+		if err := h.storeEntry(ctx, result.ID, e, opts, replace); err != nil {
+			result.Status, result.Error = models.ArchiveEntryFailed, h.entryError(result.ID, err)
+		}
+
+		rsp.Items = append(rsp.Items, result)
+		return nil
+	})
+	if err != nil {
+		rsp.Message = err.Error()
+		return c.JSON(archiveErrorStatus(err), rsp)
+	}
+
+	return c.JSON(http.StatusOK, rsp)
+}
+
+func (h ArchiveHandler) storeEntry(ctx context.Context, docID string, e archive.Entry, opts store.WriteOptions, replace bool) error {
+	if replace {
+		_, err := h.documentsStore.ReplaceDocument(ctx, docID, e, opts)
+		return err
+	}
+
+	_, err := h.documentsStore.AddDocument(ctx, docID, e, opts)
+	return err
+}
+
+// entryError returns archive entry error message for upload report.
+func (h ArchiveHandler) entryError(docID string, err error) string {
+	var quotaErr store.QuotaError
+	switch {
+	case errors.Is(err, fs.ErrExist):
+		return "document already exists"
+	case errors.As(err, &quotaErr):
+		return quotaErr.Error()
+	case errors.Is(err, archive.ErrEntryTooLarge):
+		return archive.ErrEntryTooLarge.Error()
+	case errors.Is(err, archive.ErrTooLarge):
+		return archive.ErrTooLarge.Error()
+	case errors.Is(err, ErrMalformedBody):
+		return ErrMalformedBody.Error()
+	}
+
+	h.log.Error("failed to save archive entry", zap.String("id", docID), zap.Error(err))
+	return "failed to save document"
+}
+
+// archiveEntryID returns ID of document created from archive entry.
+func archiveEntryID(archiveID, entryPath string) string {
+	return archiveID + archiveEntrySeparator + strings.ReplaceAll(entryPath, "/", archiveEntrySeparator)
+}
+
+// archiveErrorStatus returns HTTP status code of archive processing error.
+func archiveErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, archive.ErrUnsupportedFormat):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, archive.ErrTooLarge), errors.Is(err, archive.ErrTooManyEntries):
+		return http.StatusRequestEntityTooLarge
+	}
+
+	return http.StatusBadRequest
+}
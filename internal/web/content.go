@@ -0,0 +1,146 @@
+package web
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/x1unix/docusearch/internal/services/store"
+)
+
+var errBackwardSeek = errors.New("backward seek is not supported")
+
+// documentETag returns strong document ETag based on contents checksum.
+func documentETag(meta *store.Metadata) string {
+	return strconv.Quote(meta.SHA256)
+}
+
+// preconditionFromRequest returns write precondition from If-Match and If-None-Match headers.
+//
+// Repeated headers are combined, as a list of tags can be sent in separate header lines.
+func preconditionFromRequest(r *http.Request) store.Precondition {
+	return store.Precondition{
+		IfMatch:     parseETags(strings.Join(r.Header.Values("If-Match"), ","), true),
+		IfNoneMatch: parseETags(strings.Join(r.Header.Values("If-None-Match"), ","), false),
+	}
+}
+
+// parseETags parses comma-separated list of entity tags and returns checksums.
+//
+// Weak tags are skipped if strong comparison is required (If-Match).
+func parseETags(str string, strong bool) []string {
+	if str == "" {
+		return nil
+	}
+
+	var out []string
+	for _, tag := range strings.Split(str, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == store.AnyChecksum {
+			out = append(out, tag)
+			continue
+		}
+
+		if strings.HasPrefix(tag, "W/") {
+			if strong {
+				continue
+			}
+			tag = tag[2:]
+		}
+
+		if v, err := strconv.Unquote(tag); err == nil {
+			out = append(out, v)
+		}
+	}
+
+	if out == nil {
+		// Header has no valid tags, so nothing can match.
+		return []string{""}
+	}
+
+	return out
+}
+
+// setDocumentHeaders sets document representation headers.
+func setDocumentHeaders(h http.Header, meta *store.Metadata) {
+	h.Set(echo.HeaderContentType, meta.ContentType)
+	h.Set("ETag", documentETag(meta))
+	formatLabels(h, meta.Labels)
+}
+
+// serveContent serves document contents using http.ServeContent
+// which handles conditional and range requests.
+//
+// Non-seekable readers support only forward seek, so multi-range requests
+// are served with a whole document as ranges can go in any order.
+func serveContent(w http.ResponseWriter, req *http.Request, modtime time.Time, r io.Reader, size int64) {
+	content, ok := r.(io.ReadSeeker)
+	if !ok {
+		content = newForwardSeeker(r, size)
+		if strings.Contains(req.Header.Get("Range"), ",") {
+			req.Header.Del("Range")
+		}
+	}
+
+	http.ServeContent(w, req, "", modtime, content)
+}
+
+// forwardSeeker adapts non-seekable document reader to io.ReadSeeker
+// required by http.ServeContent.
+//
+// Seek only moves logical offset and data is skipped on next read,
+// so only forward seek is supported which is enough for single range requests.
+type forwardSeeker struct {
+	r      io.Reader
+	size   int64
+	pos    int64
+	offset int64
+}
+
+func newForwardSeeker(r io.Reader, size int64) *forwardSeeker {
+	return &forwardSeeker{r: r, size: size}
+}
+
+// Read implements io.Reader
+func (s *forwardSeeker) Read(p []byte) (int, error) {
+	if s.offset < s.pos {
+		return 0, errBackwardSeek
+	}
+
+	if s.offset > s.pos {
+		n, err := io.CopyN(io.Discard, s.r, s.offset-s.pos)
+		s.pos += n
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := s.r.Read(p)
+	s.pos += int64(n)
+	s.offset = s.pos
+	return n, err
+}
+
+// Seek implements io.Seeker
+func (s *forwardSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.offset
+	case io.SeekEnd:
+		offset += s.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+
+	s.offset = offset
+	return offset, nil
+}
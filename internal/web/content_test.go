@@ -0,0 +1,133 @@
+package web
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/x1unix/docusearch/internal/services/store"
+)
+
+func TestForwardSeeker(t *testing.T) {
+	const data = "The quick brown fox jumps over the lazy dog"
+	s := newForwardSeeker(strings.NewReader(data), int64(len(data)))
+
+	// Same sequence as used by http.ServeContent for single range request.
+	size, err := s.Seek(0, io.SeekEnd)
+	require.NoError(t, err)
+	require.Equal(t, int64(len(data)), size)
+
+	_, err = s.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+
+	_, err = s.Seek(4, io.SeekStart)
+	require.NoError(t, err)
+
+	got := make([]byte, 5)
+	_, err = io.ReadFull(s, got)
+	require.NoError(t, err)
+	require.Equal(t, "quick", string(got))
+
+	_, err = s.Seek(1, io.SeekCurrent)
+	require.NoError(t, err)
+	rest, err := ioutil.ReadAll(io.LimitReader(s, 5))
+	require.NoError(t, err)
+	require.Equal(t, "brown", string(rest))
+
+	_, err = s.Seek(0, io.SeekStart)
+	require.NoError(t, err)
+	_, err = s.Read(got)
+	require.ErrorIs(t, err, errBackwardSeek)
+}
+
+func TestServeContent(t *testing.T) {
+	const data = "The quick brown fox jumps over the lazy dog"
+	cases := map[string]struct {
+		rangeHeader string
+		wantStatus  int
+		wantBody    string
+	}{
+		"no range": {
+			wantStatus: http.StatusOK,
+			wantBody:   data,
+		},
+		"single range": {
+			rangeHeader: "bytes=4-8",
+			wantStatus:  http.StatusPartialContent,
+			wantBody:    "quick",
+		},
+		"suffix range": {
+			rangeHeader: "bytes=-3",
+			wantStatus:  http.StatusPartialContent,
+			wantBody:    "dog",
+		},
+		"multiple ranges": {
+			rangeHeader: "bytes=10-14,4-8",
+			wantStatus:  http.StatusOK,
+			wantBody:    data,
+		},
+	}
+
+	for n, c := range cases {
+		t.Run(n, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if c.rangeHeader != "" {
+				req.Header.Set("Range", c.rangeHeader)
+			}
+
+			// Wrap reader to hide io.Seeker implementation.
+			r := struct{ io.Reader }{strings.NewReader(data)}
+			rec := httptest.NewRecorder()
+			rec.Header().Set("Content-Type", "text/plain")
+			serveContent(rec, req, time.Now(), r, int64(len(data)))
+			require.Equal(t, c.wantStatus, rec.Code)
+			require.Equal(t, c.wantBody, rec.Body.String())
+		})
+	}
+}
+
+func TestPreconditionFromRequest(t *testing.T) {
+	cases := map[string]struct {
+		header http.Header
+		want   store.Precondition
+	}{
+		"no headers": {
+			header: http.Header{},
+		},
+		"single header": {
+			header: http.Header{"If-Match": {`"a", W/"b", "c"`}},
+			want:   store.Precondition{IfMatch: []string{"a", "c"}},
+		},
+		"repeated headers": {
+			header: http.Header{
+				"If-Match":      {`"a"`, `"b", "c"`},
+				"If-None-Match": {`W/"d"`, `"e"`},
+			},
+			want: store.Precondition{
+				IfMatch:     []string{"a", "b", "c"},
+				IfNoneMatch: []string{"d", "e"},
+			},
+		},
+		"any": {
+			header: http.Header{"If-None-Match": {"*"}},
+			want:   store.Precondition{IfNoneMatch: []string{store.AnyChecksum}},
+		},
+		"invalid tags": {
+			header: http.Header{"If-Match": {"a", "b"}},
+			want:   store.Precondition{IfMatch: []string{""}},
+		},
+	}
+
+	for n, c := range cases {
+		t.Run(n, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/document/foo", nil)
+			r.Header = c.header
+			require.Equal(t, c.want, preconditionFromRequest(r))
+		})
+	}
+}
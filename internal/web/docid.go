@@ -0,0 +1,23 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// reservedIDPrefix is prefix of names reserved for internal storage directories, like ".trash" or ".versions".
+const reservedIDPrefix = "."
+
+// validateDocumentID is middleware which rejects requests with document ID
+// which might collide with internal storage directories.
+func validateDocumentID(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if strings.HasPrefix(c.Param("id"), reservedIDPrefix) {
+			return FormatHTTPError(http.StatusBadRequest, "document ID can't start with %q", reservedIDPrefix)
+		}
+
+		return next(c)
+	}
+}
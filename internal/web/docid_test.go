@@ -0,0 +1,52 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateDocumentID(t *testing.T) {
+	cases := map[string]struct {
+		id      string
+		wantErr bool
+	}{
+		"document":       {id: "doc.txt"},
+		"archive entry":  {id: "docs:.hidden"},
+		"dot file":       {id: ".hidden", wantErr: true},
+		"trash":          {id: ".trash", wantErr: true},
+		"versions":       {id: ".versions", wantErr: true},
+		"parent dir":     {id: "..", wantErr: true},
+		"temporary file": {id: ".tmp", wantErr: true},
+	}
+
+	for n, c := range cases {
+		t.Run(n, func(t *testing.T) {
+			e := echo.New()
+			ctx := e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), httptest.NewRecorder())
+			ctx.SetParamNames("id")
+			ctx.SetParamValues(c.id)
+
+			var called bool
+			err := validateDocumentID(func(echo.Context) error {
+				called = true
+				return nil
+			})(ctx)
+			if !c.wantErr {
+				require.NoError(t, err)
+				require.True(t, called)
+				return
+			}
+
+			require.Error(t, err)
+			require.False(t, called, "handler should not be called")
+			httpErr, ok := err.(*echo.HTTPError)
+			require.True(t, ok)
+			require.Equal(t, http.StatusBadRequest, httpErr.Code)
+			require.Equal(t, `document ID can't start with "."`, httpErr.Message)
+		})
+	}
+}
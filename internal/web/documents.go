@@ -1,25 +1,42 @@
 package web
 
 import (
+	"bufio"
 	"errors"
 	"io"
 	"io/fs"
+	"mime"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/x1unix/docusearch/internal/models"
+	"github.com/x1unix/docusearch/internal/services/extract"
+	"github.com/x1unix/docusearch/internal/services/search"
 	"github.com/x1unix/docusearch/internal/services/store"
 	"go.uber.org/zap"
 )
 
+const (
+	defaultListLimit = 100
+	maxListLimit     = 1000
+)
+
 type DocumentsHandler struct {
-	log            *zap.Logger
-	documentsStore store.DocumentStore
+	log             *zap.Logger
+	documentsStore  store.IndexedDocumentStore
+	maxDocumentSize int64
 }
 
-func NewDocumentsHandler(log *zap.Logger, s store.DocumentStore) *DocumentsHandler {
+// NewDocumentsHandler constructs a new documents handler.
+//
+// maxDocumentSize limits uploaded document size in bytes, zero means no limit.
+func NewDocumentsHandler(log *zap.Logger, s store.IndexedDocumentStore, maxDocumentSize int64) *DocumentsHandler {
 	return &DocumentsHandler{
-		log:            log,
-		documentsStore: s,
+		log:             log,
+		documentsStore:  s,
+		maxDocumentSize: maxDocumentSize,
 	}
 }
 
@@ -28,26 +45,103 @@ func (h DocumentsHandler) UploadDocument(c echo.Context) error {
 
 	body := c.Request().Body
 	defer body.Close()
-	if err := h.documentsStore.AddDocument(c.Request().Context(), docID, body); err != nil {
+
+	data, err := h.documentReader(c)
+	if err != nil {
+		return err
+	}
+
+	opts, err := writeOptionsFromRequest(c.Request())
+	if err != nil {
+		return err
+	}
+
+	meta, err := h.documentsStore.AddDocument(c.Request().Context(), docID, data, opts)
+	if err != nil {
 		if errors.Is(err, fs.ErrExist) {
 			return echo.NewHTTPError(http.StatusBadRequest, "item already exists")
 		}
 
+		if errors.Is(err, ErrDocumentTooLarge) {
+			return h.newTooLargeError()
+		}
+
+		if errors.Is(err, ErrMalformedBody) {
+			return ToHTTPError(http.StatusBadRequest, err)
+		}
+
+		if httpErr, ok := quotaHTTPError(err); ok {
+			return httpErr
+		}
+
+		if errors.Is(err, store.ErrPreconditionFailed) {
+			return ToHTTPError(http.StatusPreconditionFailed, err)
+		}
+
 		h.log.Error("failed to save document", zap.String("id", docID), zap.Error(err))
 		return err
 	}
 
+	c.Response().Header().Set("ETag", documentETag(meta))
+	c.Response().WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (h DocumentsHandler) ReplaceDocument(c echo.Context) error {
+	docID := c.Param("id")
+
+	body := c.Request().Body
+	defer body.Close()
+
+	data, err := h.documentReader(c)
+	if err != nil {
+		return err
+	}
+
+	opts, err := writeOptionsFromRequest(c.Request())
+	if err != nil {
+		return err
+	}
+
+	meta, err := h.documentsStore.ReplaceDocument(c.Request().Context(), docID, data, opts)
+	if err != nil {
+		if errors.Is(err, ErrDocumentTooLarge) {
+			return h.newTooLargeError()
+		}
+
+		if errors.Is(err, ErrMalformedBody) {
+			return ToHTTPError(http.StatusBadRequest, err)
+		}
+
+		if httpErr, ok := quotaHTTPError(err); ok {
+			return httpErr
+		}
+
+		if errors.Is(err, store.ErrPreconditionFailed) {
+			return ToHTTPError(http.StatusPreconditionFailed, err)
+		}
+
+		h.log.Error("failed to replace document", zap.String("id", docID), zap.Error(err))
+		return err
+	}
+
+	c.Response().Header().Set("ETag", documentETag(meta))
 	c.Response().WriteHeader(http.StatusNoContent)
 	return nil
 }
 
 func (h DocumentsHandler) DeleteDocument(c echo.Context) error {
 	docID := c.Param("id")
-	if err := h.documentsStore.RemoveDocument(c.Request().Context(), docID); err != nil {
+	cond := preconditionFromRequest(c.Request())
+	if err := h.documentsStore.RemoveDocument(c.Request().Context(), docID, cond); err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
 			return echo.NewHTTPError(http.StatusNotFound, "document not found")
 		}
 
+		if errors.Is(err, store.ErrPreconditionFailed) {
+			return ToHTTPError(http.StatusPreconditionFailed, err)
+		}
+
 		h.log.Error("failed to remove document", zap.String("id", docID), zap.Error(err))
 		return err
 	}
@@ -56,9 +150,50 @@ func (h DocumentsHandler) DeleteDocument(c echo.Context) error {
 	return nil
 }
 
+func (h DocumentsHandler) RestoreDocument(c echo.Context) error {
+	docID := c.Param("id")
+	meta, err := h.documentsStore.RestoreDocument(c.Request().Context(), docID)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return echo.NewHTTPError(http.StatusNotFound, "document not found in trash")
+		}
+
+		if errors.Is(err, fs.ErrExist) {
+			return echo.NewHTTPError(http.StatusConflict, "document with the same id already exists")
+		}
+
+		if httpErr, ok := quotaHTTPError(err); ok {
+			return httpErr
+		}
+
+		h.log.Error("failed to restore document", zap.String("id", docID), zap.Error(err))
+		return err
+	}
+
+	c.Response().Header().Set("ETag", documentETag(meta))
+	c.Response().WriteHeader(http.StatusNoContent)
+	return nil
+}
+
 func (h DocumentsHandler) GetDocument(c echo.Context) error {
 	docID := c.Param("id")
-	r, err := h.documentsStore.GetDocument(docID)
+	meta, err := h.getMetadata(c, docID)
+	if err != nil {
+		return err
+	}
+
+	if str := c.QueryParam("version"); str != "" {
+		version, err := strconv.Atoi(str)
+		if err != nil || version <= 0 {
+			return echo.NewHTTPError(http.StatusBadRequest, "version should be a positive number")
+		}
+
+		return h.getDocumentVersion(c, docID, meta, version)
+	}
+
+	// Response depends on Accept-Encoding if documents are stored compressed.
+	c.Response().Header().Add(echo.HeaderVary, echo.HeaderAcceptEncoding)
+	r, encoding, err := h.documentsStore.GetEncodedDocument(docID, acceptedEncodings(c.Request()))
 	if err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
 			return echo.NewHTTPError(http.StatusNotFound, "document not found")
@@ -69,6 +204,284 @@ func (h DocumentsHandler) GetDocument(c echo.Context) error {
 	}
 
 	defer r.Close()
-	_, err = io.Copy(c.Response(), r)
-	return err
+	if encoding != "" {
+		return serveEncodedContent(c, meta, encoding, r)
+	}
+
+	setDocumentHeaders(c.Response().Header(), meta)
+	serveContent(c.Response(), c.Request(), meta.UpdatedAt, r, meta.Size)
+	return nil
+}
+
+// getDocumentVersion serves a previous document revision.
+func (h DocumentsHandler) getDocumentVersion(c echo.Context, docID string, meta *store.Metadata, version int) error {
+	versions, err := h.documentsStore.ListVersions(c.Request().Context(), docID)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return echo.NewHTTPError(http.StatusNotFound, "document not found")
+		}
+
+		h.log.Error("failed to list document versions", zap.String("id", docID), zap.Error(err))
+		return err
+	}
+
+	info, ok := findVersion(versions, version)
+	if !ok {
+		return echo.NewHTTPError(http.StatusNotFound, "document version not found")
+	}
+
+	r, err := h.documentsStore.GetDocumentVersion(docID, version)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return echo.NewHTTPError(http.StatusNotFound, "document version not found")
+		}
+
+		h.log.Error("failed to get document version", zap.String("id", docID),
+			zap.Int("version", version), zap.Error(err))
+		return err
+	}
+
+	defer r.Close()
+
+	// Content type is tracked only for current version as well as checksum,
+	// so only Last-Modified is used for caching.
+	contentType := echo.MIMEOctetStream
+	if version == versions[len(versions)-1].Version {
+		contentType = meta.ContentType
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, contentType)
+	serveContent(c.Response(), c.Request(), info.CreatedAt, r, info.Size)
+	return nil
+}
+
+func (h DocumentsHandler) ListVersions(c echo.Context) error {
+	docID := c.Param("id")
+	if _, err := h.getMetadata(c, docID); err != nil {
+		return err
+	}
+
+	versions, err := h.documentsStore.ListVersions(c.Request().Context(), docID)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return echo.NewHTTPError(http.StatusNotFound, "document not found")
+		}
+
+		h.log.Error("failed to list document versions", zap.String("id", docID), zap.Error(err))
+		return err
+	}
+
+	rsp := models.DocumentVersionsResponse{Items: make([]models.DocumentVersion, 0, len(versions))}
+	for _, v := range versions {
+		rsp.Items = append(rsp.Items, models.DocumentVersion{
+			Version:   v.Version,
+			Size:      v.Size,
+			CreatedAt: v.CreatedAt,
+		})
+	}
+
+	return c.JSON(http.StatusOK, rsp)
+}
+
+func (h DocumentsHandler) HeadDocument(c echo.Context) error {
+	docID := c.Param("id")
+	meta, err := h.getMetadata(c, docID)
+	if err != nil {
+		return err
+	}
+
+	header := c.Response().Header()
+	setDocumentHeaders(header, meta)
+	header.Set(echo.HeaderContentLength, strconv.FormatInt(meta.Size, 10))
+	header.Set(echo.HeaderLastModified, meta.UpdatedAt.UTC().Format(http.TimeFormat))
+	header.Set("Accept-Ranges", "bytes")
+	c.Response().WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (h DocumentsHandler) GetMetadata(c echo.Context) error {
+	docID := c.Param("id")
+	meta, err := h.getMetadata(c, docID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, models.DocumentMetadata{
+		ID:          docID,
+		Size:        meta.Size,
+		SHA256:      meta.SHA256,
+		ContentType: meta.ContentType,
+		Charset:     meta.Charset,
+		Analyzer:    meta.Analyzer,
+		CreatedAt:   meta.CreatedAt,
+		UpdatedAt:   meta.UpdatedAt,
+		ExpiresAt:   meta.ExpiresAt,
+		Labels:      meta.Labels,
+	})
+}
+
+func (h DocumentsHandler) getMetadata(c echo.Context, docID string) (*store.Metadata, error) {
+	meta, err := h.documentsStore.GetMetadata(c.Request().Context(), docID)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, echo.NewHTTPError(http.StatusNotFound, "document not found")
+		}
+
+		h.log.Error("failed to get document metadata", zap.String("id", docID), zap.Error(err))
+		return nil, err
+	}
+
+	return meta, nil
+}
+
+func (h DocumentsHandler) ListDocuments(c echo.Context) error {
+	sortBy, err := store.ParseSortField(c.QueryParam("sort"))
+	if err != nil {
+		return ToHTTPError(http.StatusBadRequest, err)
+	}
+
+	var desc bool
+	switch order := c.QueryParam("order"); order {
+	case "", "asc":
+	case "desc":
+		desc = true
+	default:
+		return FormatHTTPError(http.StatusBadRequest, "unsupported sort order %q", order)
+	}
+
+	limit := defaultListLimit
+	if str := c.QueryParam("limit"); str != "" {
+		limit, err = strconv.Atoi(str)
+		if err != nil || limit <= 0 || limit > maxListLimit {
+			return FormatHTTPError(http.StatusBadRequest, "limit should be a number between 1 and %d", maxListLimit)
+		}
+	}
+
+	result, err := h.documentsStore.List(c.Request().Context(), store.ListOptions{
+		Prefix: c.QueryParam("prefix"),
+		SortBy: sortBy,
+		Desc:   desc,
+		Cursor: c.QueryParam("cursor"),
+		Limit:  limit,
+	})
+	if err != nil {
+		if errors.Is(err, store.ErrInvalidCursor) {
+			return ToHTTPError(http.StatusBadRequest, err)
+		}
+
+		h.log.Error("failed to list documents", zap.Error(err))
+		return err
+	}
+
+	rsp := models.DocumentListResponse{
+		Items:      make([]models.DocumentInfo, 0, len(result.Items)),
+		NextCursor: result.NextCursor,
+	}
+	for _, item := range result.Items {
+		rsp.Items = append(rsp.Items, models.DocumentInfo{
+			ID:         item.Name,
+			Size:       item.Size,
+			UploadedAt: item.UploadedAt,
+		})
+	}
+
+	return c.JSON(http.StatusOK, rsp)
+}
+
+// documentReader returns decoded request body reader which respects document size limit.
+func (h DocumentsHandler) documentReader(c echo.Context) (io.Reader, error) {
+	body, encoded, err := decodeRequestBody(c.Request())
+	if err != nil {
+		return nil, err
+	}
+
+	// Content-Length is checked only to reject obviously large requests early,
+	// actual limit is enforced by reader as header value might be absent or forged.
+	// Limit is applied to decoded document, so encoded body length is not checked.
+	if !encoded && h.maxDocumentSize > 0 && c.Request().ContentLength > h.maxDocumentSize {
+		return nil, h.newTooLargeError()
+	}
+
+	return rejectMailbox(c.Request(), c.Param("id"), newLimitedReader(body, h.maxDocumentSize))
+}
+
+// rejectMailbox returns an error if uploaded document is mbox mailbox,
+// as mailbox should be uploaded as archive to store each message as a separate document.
+func rejectMailbox(r *http.Request, docID string, body io.Reader) (io.Reader, error) {
+	contentType := requestContentType(r)
+	br := bufio.NewReaderSize(body, mailboxSniffLen)
+	if contentType == "" {
+		// Read errors are returned on further read of the body.
+		head, _ := br.Peek(mailboxSniffLen)
+		contentType = extract.DetectContentType(docID, head)
+	}
+
+	if mimeType, _, _ := mime.ParseMediaType(contentType); mimeType == extract.MIMETypeMbox {
+		return nil, FormatHTTPError(http.StatusUnsupportedMediaType,
+			"mbox mailbox should be uploaded to /archive/%s to store each message as a separate document", docID)
+	}
+
+	return br, nil
+}
+
+// requestContentType returns document content type from request headers.
+//
+// Returns empty string if content type should be detected from contents.
+func requestContentType(r *http.Request) string {
+	contentType := r.Header.Get(echo.HeaderContentType)
+	if contentType == echo.MIMEApplicationForm {
+		// Default content type used by curl for request body,
+		// real type will be detected from contents.
+		return ""
+	}
+
+	return contentType
+}
+
+// mailboxSniffLen is number of leading document bytes used to detect mbox mailbox.
+const mailboxSniffLen = 512
+
+// analyzerParam is query parameter which contains name of search analyzer used to index document.
+const analyzerParam = "analyzer"
+
+// writeOptionsFromRequest returns document write options from request headers and query parameters.
+func writeOptionsFromRequest(r *http.Request) (store.WriteOptions, error) {
+	labels, err := parseLabels(r.Header)
+	if err != nil {
+		return store.WriteOptions{}, err
+	}
+
+	expiresAt, err := parseExpiration(r, time.Now())
+	if err != nil {
+		return store.WriteOptions{}, err
+	}
+
+	analyzer := r.URL.Query().Get(analyzerParam)
+	if analyzer != "" && !search.IsAnalyzer(analyzer) {
+		return store.WriteOptions{}, FormatHTTPError(http.StatusBadRequest,
+			"unsupported %s %q, expected %q or %q", analyzerParam, analyzer, search.AnalyzerText, search.AnalyzerCode)
+	}
+
+	return store.WriteOptions{
+		ContentType:  requestContentType(r),
+		Labels:       labels,
+		Precondition: preconditionFromRequest(r),
+		ExpiresAt:    expiresAt,
+		Analyzer:     analyzer,
+	}, nil
+}
+
+func findVersion(versions []store.VersionInfo, version int) (store.VersionInfo, bool) {
+	for _, v := range versions {
+		if v.Version == version {
+			return v, true
+		}
+	}
+
+	return store.VersionInfo{}, false
+}
+
+func (h DocumentsHandler) newTooLargeError() error {
+	return FormatHTTPError(http.StatusRequestEntityTooLarge,
+		"document size exceeds limit of %d bytes", h.maxDocumentSize)
 }
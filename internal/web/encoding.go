@@ -0,0 +1,137 @@
+package web
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/x1unix/docusearch/internal/services/store"
+)
+
+// ErrMalformedBody is returned on read of request body which can't be decoded.
+var ErrMalformedBody = errors.New("malformed request body")
+
+// decodeRequestBody returns request body decoded according to Content-Encoding header.
+//
+// Only gzip encoding is supported. Second return value reports whether body was encoded.
+func decodeRequestBody(r *http.Request) (io.Reader, bool, error) {
+	switch enc := strings.ToLower(strings.TrimSpace(r.Header.Get(echo.HeaderContentEncoding))); enc {
+	case "", "identity":
+		return r.Body, false, nil
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, false, WrapHTTPError(http.StatusBadRequest, err, "malformed gzip request body")
+		}
+
+		return decodeErrorReader{r: gz}, true, nil
+	default:
+		return nil, false, FormatHTTPError(http.StatusUnsupportedMediaType, "unsupported content encoding %q", enc)
+	}
+}
+
+// decodeErrorReader wraps decoder errors with ErrMalformedBody.
+type decodeErrorReader struct {
+	r io.Reader
+}
+
+// Read implements io.Reader
+func (d decodeErrorReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	if err != nil && err != io.EOF {
+		return n, fmt.Errorf("%w: %s", ErrMalformedBody, err)
+	}
+
+	return n, err
+}
+
+// acceptedEncodings returns list of content encodings accepted by client from Accept-Encoding header.
+//
+// Range requests are served only for unencoded content, so nil is returned for them.
+func acceptedEncodings(r *http.Request) []string {
+	if r.Header.Get("Range") != "" {
+		return nil
+	}
+
+	var out []string
+	for _, v := range r.Header.Values(echo.HeaderAcceptEncoding) {
+		for _, item := range strings.Split(v, ",") {
+			parts := strings.Split(item, ";")
+			enc := strings.ToLower(strings.TrimSpace(parts[0]))
+			if enc == "" || enc == "*" || isEncodingRejected(parts[1:]) {
+				continue
+			}
+
+			out = append(out, enc)
+		}
+	}
+
+	return out
+}
+
+// isEncodingRejected reports whether encoding has zero quality value.
+func isEncodingRejected(params []string) bool {
+	for _, param := range params {
+		param = strings.TrimSpace(param)
+		if !strings.HasPrefix(param, "q=") {
+			continue
+		}
+
+		q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+		return err != nil || q <= 0
+	}
+
+	return false
+}
+
+// encodedDocumentETag returns ETag of encoded document representation.
+func encodedDocumentETag(meta *store.Metadata, encoding string) string {
+	return strconv.Quote(meta.SHA256 + "-" + encoding)
+}
+
+// serveEncodedContent serves encoded document contents.
+//
+// Conditional GET requests are handled as http.ServeContent doesn't work with encoded content.
+func serveEncodedContent(c echo.Context, meta *store.Metadata, encoding string, content io.Reader) error {
+	header := c.Response().Header()
+	etag := encodedDocumentETag(meta, encoding)
+	header.Set(echo.HeaderContentType, meta.ContentType)
+	header.Set(echo.HeaderContentEncoding, encoding)
+	header.Set("ETag", etag)
+	header.Set(echo.HeaderLastModified, meta.UpdatedAt.UTC().Format(http.TimeFormat))
+	formatLabels(header, meta.Labels)
+
+	if isNotModified(c.Request(), strings.Trim(etag, `"`), meta.UpdatedAt) {
+		header.Del(echo.HeaderContentType)
+		header.Del(echo.HeaderContentEncoding)
+		return c.NoContent(http.StatusNotModified)
+	}
+
+	return c.Stream(http.StatusOK, meta.ContentType, content)
+}
+
+// isNotModified checks If-None-Match and If-Modified-Since request headers.
+func isNotModified(r *http.Request, etag string, modTime time.Time) bool {
+	if v := r.Header.Get("If-None-Match"); v != "" {
+		for _, tag := range parseETags(v, false) {
+			if tag == store.AnyChecksum || tag == etag {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	t, err := http.ParseTime(r.Header.Get(echo.HeaderIfModifiedSince))
+	if err != nil {
+		return false
+	}
+
+	return !modTime.Truncate(time.Second).After(t)
+}
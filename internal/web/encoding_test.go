@@ -0,0 +1,126 @@
+package web
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptedEncodings(t *testing.T) {
+	cases := map[string]struct {
+		headers map[string]string
+		want    []string
+	}{
+		"no header": {},
+		"single encoding": {
+			headers: map[string]string{"Accept-Encoding": "gzip"},
+			want:    []string{"gzip"},
+		},
+		"multiple encodings with quality": {
+			headers: map[string]string{"Accept-Encoding": "br;q=1.0, GZIP;q=0.5, zstd"},
+			want:    []string{"br", "gzip", "zstd"},
+		},
+		"rejected encodings": {
+			headers: map[string]string{"Accept-Encoding": "gzip;q=0, zstd; q=0.000, identity, *"},
+			want:    []string{"identity"},
+		},
+		"range request": {
+			headers: map[string]string{"Accept-Encoding": "gzip", "Range": "bytes=0-10"},
+		},
+	}
+
+	for n, c := range cases {
+		t.Run(n, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/document/foo", nil)
+			for k, v := range c.headers {
+				r.Header.Set(k, v)
+			}
+
+			require.Equal(t, c.want, acceptedEncodings(r))
+		})
+	}
+}
+
+func TestDecodeRequestBody(t *testing.T) {
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	_, err := gw.Write([]byte("foo bar"))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	cases := map[string]struct {
+		encoding    string
+		body        []byte
+		want        string
+		wantEncoded bool
+		wantStatus  int
+		wantReadErr error
+	}{
+		"no encoding": {
+			body: []byte("foo"),
+			want: "foo",
+		},
+		"identity": {
+			encoding: "identity",
+			body:     []byte("foo"),
+			want:     "foo",
+		},
+		"gzip": {
+			encoding:    "GZIP",
+			body:        gzipped.Bytes(),
+			want:        "foo bar",
+			wantEncoded: true,
+		},
+		"truncated gzip": {
+			encoding:    "gzip",
+			body:        gzipped.Bytes()[:gzipped.Len()-4],
+			wantEncoded: true,
+			wantReadErr: ErrMalformedBody,
+		},
+		"malformed gzip header": {
+			encoding:   "gzip",
+			body:       []byte("foo"),
+			wantStatus: http.StatusBadRequest,
+		},
+		"unsupported encoding": {
+			encoding:   "br",
+			body:       []byte("foo"),
+			wantStatus: http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for n, c := range cases {
+		t.Run(n, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/document/foo", bytes.NewReader(c.body))
+			if c.encoding != "" {
+				r.Header.Set("Content-Encoding", c.encoding)
+			}
+
+			body, encoded, err := decodeRequestBody(r)
+			if c.wantStatus != 0 {
+				var httpErr *echo.HTTPError
+				require.ErrorAs(t, err, &httpErr)
+				require.Equal(t, c.wantStatus, httpErr.Code)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, c.wantEncoded, encoded)
+			got, err := ioutil.ReadAll(body)
+			if c.wantReadErr != nil {
+				require.ErrorIs(t, err, c.wantReadErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, c.want, string(got))
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package web
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// expiresInParam is query parameter which contains document time to live.
+const expiresInParam = "expires_in"
+
+// parseExpiration returns document expiration time from "expires_in" query parameter or "Expires" header.
+//
+// "expires_in" accepts number of seconds or duration string like "1h30m".
+// Returns nil if expiration time is not specified.
+func parseExpiration(r *http.Request, now time.Time) (*time.Time, error) {
+	if str := r.URL.Query().Get(expiresInParam); str != "" {
+		ttl, err := parseTTL(str)
+		if err != nil || ttl <= 0 {
+			return nil, FormatHTTPError(http.StatusBadRequest,
+				"invalid %s value %q, value should be a positive number of seconds or duration", expiresInParam, str)
+		}
+
+		expiresAt := now.Add(ttl)
+		return &expiresAt, nil
+	}
+
+	str := r.Header.Get("Expires")
+	if str == "" {
+		return nil, nil
+	}
+
+	expiresAt, err := http.ParseTime(str)
+	if err != nil {
+		return nil, FormatHTTPError(http.StatusBadRequest, "invalid Expires header value %q", str)
+	}
+
+	if !expiresAt.After(now) {
+		return nil, FormatHTTPError(http.StatusBadRequest, "document expiration time is in the past")
+	}
+
+	return &expiresAt, nil
+}
+
+// maxTTLSeconds is max number of seconds which can be represented as time.Duration.
+const maxTTLSeconds = math.MaxInt64 / int64(time.Second)
+
+func parseTTL(str string) (time.Duration, error) {
+	if seconds, err := strconv.ParseInt(str, 10, 64); err == nil {
+		if seconds > maxTTLSeconds {
+			return 0, strconv.ErrRange
+		}
+
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	return time.ParseDuration(str)
+}
@@ -0,0 +1,88 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExpiration(t *testing.T) {
+	now := time.Date(2021, 11, 1, 10, 0, 0, 0, time.UTC)
+	cases := map[string]struct {
+		query   string
+		expires string
+		want    time.Time
+		wantErr string
+	}{
+		"no expiration": {},
+		"seconds": {
+			query: "expires_in=90",
+			want:  now.Add(90 * time.Second),
+		},
+		"duration": {
+			query: "expires_in=1h30m",
+			want:  now.Add(90 * time.Minute),
+		},
+		"query has priority over header": {
+			query:   "expires_in=60",
+			expires: "Mon, 01 Nov 2021 12:00:00 GMT",
+			want:    now.Add(time.Minute),
+		},
+		"header": {
+			expires: "Mon, 01 Nov 2021 12:00:00 GMT",
+			want:    now.Add(2 * time.Hour),
+		},
+		"negative ttl": {
+			query:   "expires_in=-1",
+			wantErr: `invalid expires_in value "-1", value should be a positive number of seconds or duration`,
+		},
+		"ttl overflow": {
+			query:   "expires_in=18446744074",
+			wantErr: `invalid expires_in value "18446744074", value should be a positive number of seconds or duration`,
+		},
+		"max ttl": {
+			query: "expires_in=9223372036",
+			want:  now.Add(9223372036 * time.Second),
+		},
+		"invalid ttl": {
+			query:   "expires_in=tomorrow",
+			wantErr: `invalid expires_in value "tomorrow", value should be a positive number of seconds or duration`,
+		},
+		"invalid header": {
+			expires: "tomorrow",
+			wantErr: `invalid Expires header value "tomorrow"`,
+		},
+		"past header": {
+			expires: "Mon, 01 Nov 2021 09:00:00 GMT",
+			wantErr: "document expiration time is in the past",
+		},
+	}
+
+	for n, c := range cases {
+		t.Run(n, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/document/foo?"+c.query, nil)
+			if c.expires != "" {
+				r.Header.Set("Expires", c.expires)
+			}
+
+			got, err := parseExpiration(r, now)
+			if c.wantErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			if c.want.IsZero() {
+				require.Nil(t, got)
+				return
+			}
+
+			require.NotNil(t, got)
+			require.True(t, c.want.Equal(*got), "want %s, got %s", c.want, got)
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/x1unix/docusearch/internal/models"
+)
+
+// parseLabels parses document labels from request headers.
+//
+// Header value might contain several comma-separated labels, as repeated headers can be combined.
+// Returns nil if request doesn't contain any label.
+func parseLabels(h http.Header) (map[string]string, error) {
+	values := h.Values(models.LabelHeader)
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	labels := make(map[string]string, len(values))
+	for _, val := range values {
+		// Commas in label keys and values are percent-encoded.
+		for _, pair := range strings.Split(val, ",") {
+			key, value, ok := models.ParseLabelHeader(pair)
+			if !ok {
+				return nil, FormatHTTPError(http.StatusBadRequest,
+					"invalid label %q, label should be in key=value format", pair)
+			}
+
+			// Label filters and search index use "=" as key and value separator.
+			if strings.Contains(key, "=") {
+				return nil, FormatHTTPError(http.StatusBadRequest,
+					"invalid label %q, label key can't contain \"=\"", pair)
+			}
+
+			labels[key] = value
+		}
+	}
+
+	return labels, nil
+}
+
+// parseLabelFilters parses list of "key=value" label pairs from query parameters.
+//
+// Pair is split by the first "=", as label keys can't contain "=".
+// Returns nil if list is empty.
+func parseLabelFilters(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	labels := make(map[string]string, len(values))
+	for _, pair := range values {
+		key, value, ok := cutString(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, FormatHTTPError(http.StatusBadRequest,
+				"invalid label %q, label should be in key=value format", pair)
+		}
+
+		labels[key] = strings.TrimSpace(value)
+	}
+
+	return labels, nil
+}
+
+// formatLabels writes document labels into response headers.
+func formatLabels(h http.Header, labels map[string]string) {
+	for k, v := range labels {
+		h.Add(models.LabelHeader, models.FormatLabelHeader(k, v))
+	}
+}
+
+// cutString slices s around the first instance of sep.
+//
+// Replacement for strings.Cut which is not available in Go 1.17.
+func cutString(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+
+	return s, "", false
+}
@@ -0,0 +1,84 @@
+package web
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/x1unix/docusearch/internal/models"
+)
+
+func TestParseLabels(t *testing.T) {
+	cases := map[string]struct {
+		values  []string
+		want    map[string]string
+		wantErr string
+	}{
+		"no labels": {},
+		"repeated headers": {
+			values: []string{"kind=pangram", "lang = en"},
+			want:   map[string]string{"kind": "pangram", "lang": "en"},
+		},
+		"combined headers": {
+			values: []string{"kind=pangram, lang=en"},
+			want:   map[string]string{"kind": "pangram", "lang": "en"},
+		},
+		"encoded values": {
+			values: []string{"tags=a%2Cb", "note%20key=x=y%20z", "empty="},
+			want:   map[string]string{"tags": "a,b", "note key": "x=y z", "empty": ""},
+		},
+		"missing value": {
+			values:  []string{"kind"},
+			wantErr: `invalid label "kind", label should be in key=value format`,
+		},
+		"empty key": {
+			values:  []string{"=en"},
+			wantErr: `invalid label "=en", label should be in key=value format`,
+		},
+		"key with separator": {
+			values:  []string{"a%3Db=c"},
+			wantErr: `invalid label "a%3Db=c", label key can't contain "="`,
+		},
+		"malformed encoding": {
+			values:  []string{"kind=100%"},
+			wantErr: `invalid label "kind=100%", label should be in key=value format`,
+		},
+	}
+
+	for n, c := range cases {
+		t.Run(n, func(t *testing.T) {
+			h := http.Header{}
+			for _, v := range c.values {
+				h.Add(models.LabelHeader, v)
+			}
+
+			got, err := parseLabels(h)
+			if c.wantErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), c.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, c.want, got)
+		})
+	}
+}
+
+func TestFormatLabels(t *testing.T) {
+	labels := map[string]string{
+		"tags":     "a,b",
+		"note key": "x=y z+1",
+		"lang":     "en",
+		"title":    "Über",
+	}
+
+	h := http.Header{}
+	formatLabels(h, labels)
+	require.Contains(t, h.Values(models.LabelHeader), "tags=a%2Cb")
+	require.Contains(t, h.Values(models.LabelHeader), "lang=en")
+
+	got, err := parseLabels(h)
+	require.NoError(t, err)
+	require.Equal(t, labels, got)
+}
@@ -0,0 +1,41 @@
+package web
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrDocumentTooLarge is returned when uploaded document exceeds size limit.
+var ErrDocumentTooLarge = errors.New("document is too large")
+
+// limitedReader is io.Reader which returns ErrDocumentTooLarge
+// if more than limit bytes were read from underlying reader.
+//
+// Unlike io.LimitReader, it doesn't silently truncate the input.
+type limitedReader struct {
+	r     io.Reader
+	limit int64
+	read  int64
+}
+
+// newLimitedReader returns a new limited reader.
+//
+// Returns original reader if limit is zero or negative.
+func newLimitedReader(r io.Reader, limit int64) io.Reader {
+	if limit <= 0 {
+		return r
+	}
+
+	return &limitedReader{r: r, limit: limit}
+}
+
+// Read implements io.Reader
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+	if l.read > l.limit {
+		return n, ErrDocumentTooLarge
+	}
+
+	return n, err
+}
@@ -25,13 +25,28 @@ func (h SearchHandler) SearchWord(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "empty search query")
 	}
 
-	ids, err := h.searchProvider.SearchDocumentsByWord(c.Request().Context(), query)
+	field, word := search.ParseTerm(query)
+	if word == "" || (field == "" && word != query) {
+		return echo.NewHTTPError(http.StatusBadRequest, "invalid search query, expected word or field:word")
+	}
+
+	labels, err := parseLabelFilters(c.QueryParams()["label"])
+	if err != nil {
+		return err
+	}
+
+	result, err := h.searchProvider.SearchDocuments(c.Request().Context(), search.Query{
+		Word:   word,
+		Field:  field,
+		Labels: labels,
+	})
 	if err != nil {
 		h.log.Error("failed to get search results", zap.Error(err), zap.String("query", query))
 		return err
 	}
 
 	return c.JSON(http.StatusOK, models.DocumentIDsResponse{
-		IDs: ids,
+		IDs:    result.IDs,
+		Facets: result.Facets,
 	})
 }
@@ -1,32 +1,89 @@
 package web
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/brpaz/echozap"
 	"github.com/go-redis/redis/v8"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/x1unix/docusearch/internal/config"
+	"github.com/x1unix/docusearch/internal/services/extract"
 	"github.com/x1unix/docusearch/internal/services/search"
 	"github.com/x1unix/docusearch/internal/services/store"
 	"go.uber.org/zap"
 )
 
 // NewService builds application service handler.
-func NewService(log *zap.Logger, cfg *config.Config, redisConn redis.Cmdable) *echo.Echo {
+//
+// Background jobs are running until passed context is cancelled.
+func NewService(ctx context.Context, log *zap.Logger, cfg *config.Config, redisConn redis.Cmdable) (*echo.Echo, error) {
+	locker, err := cfg.Locker(log, redisConn)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := cfg.EncryptionKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	backend, err := newStorageBackend(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	docStore, err := newDocumentStore(cfg, backend, redisConn, locker, keys)
+	if err != nil {
+		return nil, err
+	}
+
 	echo.NotFoundHandler = FancyHandleNotFound
 	e := echo.New()
 	e.Use(echozap.ZapLogger(log))
 	e.Use(middleware.Recover())
 
 	searchProvider := search.NewRedisProvider(log.Named("search.redis"), redisConn)
-	syncStore := store.NewSyncedDocumentStore(log.Named("store"), store.NewFileDocumentStore(cfg.Storage.UploadsDirectory),
-		searchProvider, store.TextIndexConfig{IgnoreCommonWords: cfg.Search.IgnoreCommonWords})
-	docHandler := NewDocumentsHandler(log.Named("handler.docs"), syncStore)
+	if cfg.Search.BoostHeadings {
+		searchProvider.WithBoost(extract.FieldTitle, extract.FieldHeading)
+	}
+
+	syncStore := store.NewSyncedDocumentStore(log.Named("store"), docStore,
+		store.NewRedisMetadataStore(redisConn), searchProvider, locker, store.TextIndexConfig{
+			IgnoreCommonWords: cfg.Search.IgnoreCommonWords,
+			FieldPaths:        cfg.Search.FieldPaths,
+			DedupContent:      cfg.Storage.Dedup,
+		})
+	go syncStore.RunExpiryReaper(ctx, cfg.Storage.ExpiryCheckInterval)
+	if trashCfg := cfg.Storage.Trash; trashCfg.Retention > 0 {
+		trashStore := encryptStore(backend.store(store.TrashDirName), keys)
+		syncStore.WithTrash(store.NewTrash(trashStore, store.NewRedisTrashMetadataStore(redisConn), trashCfg.Retention))
+		go syncStore.RunTrashPurger(ctx, trashCfg.PurgeInterval)
+	}
+
+	if quotas := cfg.Quotas(store.NewRedisUsageStore(redisConn)); quotas != nil {
+		if err := syncStore.WithQuotas(quotas).InitUsage(ctx); err != nil {
+			return nil, fmt.Errorf("failed to calculate namespace usage: %w", err)
+		}
+	}
+
+	docHandler := NewDocumentsHandler(log.Named("handler.docs"), syncStore, cfg.Storage.MaxDocumentSize)
+	archiveHandler := NewArchiveHandler(log.Named("handler.archive"), syncStore, cfg.ArchiveLimits())
 	searchHandler := NewSearchHandler(log.Named("handler.search"), searchProvider)
 
-	e.POST("/document/:id", docHandler.UploadDocument)
-	e.GET("/document/:id", docHandler.GetDocument)
-	e.DELETE("/document/:id", docHandler.DeleteDocument)
+	e.GET("/documents", docHandler.ListDocuments)
+	e.POST("/document/:id", docHandler.UploadDocument, validateDocumentID)
+	e.PUT("/document/:id", docHandler.ReplaceDocument, validateDocumentID)
+	e.GET("/document/:id", docHandler.GetDocument, validateDocumentID)
+	e.HEAD("/document/:id", docHandler.HeadDocument, validateDocumentID)
+	e.GET("/document/:id/meta", docHandler.GetMetadata, validateDocumentID)
+	e.GET("/document/:id/versions", docHandler.ListVersions, validateDocumentID)
+	e.DELETE("/document/:id", docHandler.DeleteDocument, validateDocumentID)
+	e.POST("/document/:id/restore", docHandler.RestoreDocument, validateDocumentID)
+	e.POST("/archive/:id", archiveHandler.UploadArchive, validateDocumentID)
+	e.PUT("/archive/:id", archiveHandler.UploadArchive, validateDocumentID)
+	e.GET("/usage", docHandler.GetUsage)
 	e.GET("/search", searchHandler.SearchWord)
-	return e
+	return e, nil
 }
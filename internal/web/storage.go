@@ -0,0 +1,110 @@
+package web
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/go-redis/redis/v8"
+	"github.com/x1unix/docusearch/internal/config"
+	"github.com/x1unix/docusearch/internal/services/lock"
+	"github.com/x1unix/docusearch/internal/services/store"
+)
+
+// storageBackend constructs raw document storages using configured storage backend.
+type storageBackend struct {
+	cfg      *config.Config
+	s3Client s3iface.S3API
+}
+
+func newStorageBackend(cfg *config.Config) (*storageBackend, error) {
+	if n := cfg.Storage.ShardLevels; n < 0 || n > store.MaxShardLevels {
+		return nil, fmt.Errorf("shard levels should be between 0 and %d", store.MaxShardLevels)
+	}
+
+	switch cfg.Storage.Backend {
+	case "", config.StorageBackendFile:
+		return &storageBackend{cfg: cfg}, nil
+	case config.StorageBackendS3:
+		client, err := cfg.S3Client()
+		if err != nil {
+			return nil, err
+		}
+
+		return &storageBackend{cfg: cfg, s3Client: client}, nil
+	default:
+		return nil, fmt.Errorf("unsupported storage backend %q", cfg.Storage.Backend)
+	}
+}
+
+// store returns document storage located in storage sub-directory.
+//
+// Empty sub-directory means storage root.
+func (b storageBackend) store(subDir string) store.DocumentStore {
+	if b.s3Client == nil {
+		return b.fileStore(subDir)
+	}
+
+	prefix := b.cfg.Storage.S3.Prefix
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	if subDir != "" {
+		prefix += subDir + "/"
+	}
+
+	return store.NewS3DocumentStore(b.s3Client, b.cfg.Storage.S3.Bucket, prefix)
+}
+
+// documentStore returns storage of current documents.
+//
+// Versioning is supported only by file storage backend.
+func (b storageBackend) documentStore() store.DocumentStore {
+	if b.s3Client == nil {
+		return b.fileStore("").WithVersioning(b.cfg.Storage.MaxVersions)
+	}
+
+	return b.store("")
+}
+
+func (b storageBackend) fileStore(subDir string) *store.FileDocumentStore {
+	return store.NewFileDocumentStore(filepath.Join(b.cfg.Storage.UploadsDirectory, subDir)).
+		WithSharding(b.cfg.Storage.ShardLevels)
+}
+
+// newDocumentStore returns document storage configured by storage config.
+//
+// Documents are encrypted if encryption keys are passed.
+func newDocumentStore(cfg *config.Config, backend *storageBackend, redisConn redis.Cmdable, locker lock.Locker,
+	keys *store.KeyRing) (store.DocumentStore, error) {
+	compression, err := store.ParseCompression(cfg.Storage.Compression)
+	if err != nil {
+		return nil, err
+	}
+
+	var docStore store.DocumentStore
+	if cfg.Storage.Dedup {
+		// Blobs are encrypted instead of documents as encryption output differs for the same contents.
+		blobStore := encryptStore(backend.store(store.BlobsDirName), keys)
+		docStore = store.NewDedupDocumentStore(blobStore, store.NewRedisBlobRefStore(redisConn), locker)
+	} else {
+		docStore = encryptStore(backend.documentStore(), keys)
+	}
+
+	if compression == store.CompressionNone {
+		return docStore, nil
+	}
+
+	return store.NewCompressedDocumentStore(docStore, compression), nil
+}
+
+// encryptStore wraps document store with encryption if encryption keys are passed.
+func encryptStore(s store.DocumentStore, keys *store.KeyRing) store.DocumentStore {
+	if keys == nil {
+		return s
+	}
+
+	return store.NewEncryptedDocumentStore(s, keys)
+}
@@ -0,0 +1,51 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/x1unix/docusearch/internal/models"
+	"github.com/x1unix/docusearch/internal/services/store"
+	"go.uber.org/zap"
+)
+
+// GetUsage returns storage usage and quota of a namespace passed in "namespace" query parameter.
+//
+// Empty namespace means default namespace.
+func (h DocumentsHandler) GetUsage(c echo.Context) error {
+	namespace := c.QueryParam("namespace")
+	usage, quota, err := h.documentsStore.GetUsage(c.Request().Context(), namespace)
+	if err != nil {
+		if errors.Is(err, store.ErrQuotasDisabled) {
+			return ToHTTPError(http.StatusNotFound, err)
+		}
+
+		h.log.Error("failed to get namespace usage", zap.String("namespace", namespace), zap.Error(err))
+		return err
+	}
+
+	return c.JSON(http.StatusOK, models.NamespaceUsage{
+		Namespace:    namespace,
+		Documents:    usage.Documents,
+		Bytes:        usage.Bytes,
+		MaxDocuments: quota.MaxDocuments,
+		MaxBytes:     quota.MaxBytes,
+	})
+}
+
+// quotaHTTPError returns HTTP error if error is caused by exceeded namespace quota.
+//
+// Exceeded bytes quota is reported as 507 and exceeded documents quota as 403.
+func quotaHTTPError(err error) (*echo.HTTPError, bool) {
+	var quotaErr store.QuotaError
+	if !errors.As(err, &quotaErr) {
+		return nil, false
+	}
+
+	if quotaErr.Limit == store.QuotaLimitBytes {
+		return ToHTTPError(http.StatusInsufficientStorage, quotaErr), true
+	}
+
+	return ToHTTPError(http.StatusForbidden, quotaErr), true
+}
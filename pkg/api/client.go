@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/url"
 	"path"
+	"strconv"
 
 	"github.com/x1unix/docusearch/internal/models"
 )
@@ -19,8 +20,23 @@ func NewClient(baseUrl string) *Client {
 	return &Client{baseUrl: baseUrl}
 }
 
-func (c Client) AddDocument(name string, data io.Reader) error {
-	r, err := c.newRequest(http.MethodPost, path.Join("document", name), data)
+func (c Client) AddDocument(name string, data io.Reader, opts ...RequestOption) error {
+	r, err := c.newRequest(http.MethodPost, path.Join("document", name), data, opts...)
+	if err != nil {
+		return err
+	}
+
+	rsp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+	return checkResponseError(rsp)
+}
+
+func (c Client) ReplaceDocument(name string, data io.Reader, opts ...RequestOption) error {
+	r, err := c.newRequest(http.MethodPut, path.Join("document", name), data, opts...)
 	if err != nil {
 		return err
 	}
@@ -53,8 +69,69 @@ func (c Client) GetDocument(name string) ([]byte, error) {
 	return ioutil.ReadAll(rsp.Body)
 }
 
-func (c Client) RemoveDocument(name string) error {
-	r, err := c.newRequest(http.MethodDelete, path.Join("document", name), nil)
+// GetDocumentVersion returns contents of specified document revision.
+func (c Client) GetDocumentVersion(name string, version int) ([]byte, error) {
+	r, err := c.newRequest(http.MethodGet, path.Join("document", name)+"?version="+strconv.Itoa(version), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rsp.Body.Close()
+	if err := checkResponseError(rsp); err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(rsp.Body)
+}
+
+// ListVersions returns list of kept document revisions.
+func (c Client) ListVersions(name string) (*models.DocumentVersionsResponse, error) {
+	r, err := c.newRequest(http.MethodGet, path.Join("document", name, "versions"), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rsp.Body.Close()
+	if err := checkResponseError(rsp); err != nil {
+		return nil, err
+	}
+
+	result := new(models.DocumentVersionsResponse)
+	return result, json.NewDecoder(rsp.Body).Decode(result)
+}
+
+func (c Client) GetMetadata(name string) (*models.DocumentMetadata, error) {
+	r, err := c.newRequest(http.MethodGet, path.Join("document", name, "meta"), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rsp.Body.Close()
+	if err := checkResponseError(rsp); err != nil {
+		return nil, err
+	}
+
+	meta := new(models.DocumentMetadata)
+	return meta, json.NewDecoder(rsp.Body).Decode(meta)
+}
+
+func (c Client) RemoveDocument(name string, opts ...RequestOption) error {
+	r, err := c.newRequest(http.MethodDelete, path.Join("document", name), nil, opts...)
 	if err != nil {
 		return err
 	}
@@ -68,6 +145,80 @@ func (c Client) RemoveDocument(name string) error {
 	return checkResponseError(rsp)
 }
 
+// RestoreDocument restores removed document from trash.
+func (c Client) RestoreDocument(name string) error {
+	r, err := c.newRequest(http.MethodPost, path.Join("document", name, "restore"), nil)
+	if err != nil {
+		return err
+	}
+
+	rsp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		return err
+	}
+
+	defer rsp.Body.Close()
+	return checkResponseError(rsp)
+}
+
+// ListQuery is documents list query.
+type ListQuery struct {
+	// Prefix filters documents by ID prefix.
+	Prefix string
+
+	// SortBy is sort field - "name", "time" or "size".
+	SortBy string
+
+	// Desc toggles descending sort order.
+	Desc bool
+
+	// Cursor is next page cursor from previous response.
+	Cursor string
+
+	// Limit is page size.
+	Limit int
+}
+
+func (q ListQuery) values() url.Values {
+	vals := make(url.Values)
+	if q.Prefix != "" {
+		vals.Set("prefix", q.Prefix)
+	}
+	if q.SortBy != "" {
+		vals.Set("sort", q.SortBy)
+	}
+	if q.Desc {
+		vals.Set("order", "desc")
+	}
+	if q.Cursor != "" {
+		vals.Set("cursor", q.Cursor)
+	}
+	if q.Limit > 0 {
+		vals.Set("limit", strconv.Itoa(q.Limit))
+	}
+	return vals
+}
+
+func (c Client) ListDocuments(q ListQuery) (*models.DocumentListResponse, error) {
+	r, err := c.newRequest(http.MethodGet, "documents?"+q.values().Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rsp.Body.Close()
+	if err := checkResponseError(rsp); err != nil {
+		return nil, err
+	}
+
+	result := new(models.DocumentListResponse)
+	return result, json.NewDecoder(rsp.Body).Decode(result)
+}
+
 func (c Client) SearchByWord(word string) ([]string, error) {
 	r, err := c.newRequest(http.MethodGet, "search?q="+url.QueryEscape(word), nil)
 	if err != nil {
@@ -88,7 +239,99 @@ func (c Client) SearchByWord(word string) ([]string, error) {
 	return docIDs.IDs, json.NewDecoder(rsp.Body).Decode(docIDs)
 }
 
-func (c Client) newRequest(method, path string, body io.Reader) (*http.Request, error) {
+// UploadArchive stores each file of ZIP, TAR or gzip-compressed TAR archive as a separate document.
+//
+// Existing documents are replaced if replace is true.
+func (c Client) UploadArchive(name string, data io.Reader, replace bool, opts ...RequestOption) (*models.ArchiveUploadResponse, error) {
+	method := http.MethodPost
+	if replace {
+		method = http.MethodPut
+	}
+
+	r, err := c.newRequest(method, path.Join("archive", name), data, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rsp.Body.Close()
+	if err := checkResponseError(rsp); err != nil {
+		return nil, err
+	}
+
+	result := new(models.ArchiveUploadResponse)
+	return result, json.NewDecoder(rsp.Body).Decode(result)
+}
+
+// GetUsage returns storage usage and quota of a namespace.
+func (c Client) GetUsage(namespace string) (*models.NamespaceUsage, error) {
+	r, err := c.newRequest(http.MethodGet, "usage?namespace="+url.QueryEscape(namespace), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rsp.Body.Close()
+	if err := checkResponseError(rsp); err != nil {
+		return nil, err
+	}
+
+	result := new(models.NamespaceUsage)
+	return result, json.NewDecoder(rsp.Body).Decode(result)
+}
+
+// SearchQuery is documents search query.
+type SearchQuery struct {
+	// Word is a word to search.
+	Word string
+
+	// Labels filters documents by labels.
+	Labels map[string]string
+}
+
+func (c Client) Search(q SearchQuery) (*models.DocumentIDsResponse, error) {
+	vals := url.Values{"q": []string{q.Word}}
+	for k, v := range q.Labels {
+		vals.Add("label", k+"="+v)
+	}
+
+	r, err := c.newRequest(http.MethodGet, "search?"+vals.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	rsp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rsp.Body.Close()
+	if err := checkResponseError(rsp); err != nil {
+		return nil, err
+	}
+
+	result := new(models.DocumentIDsResponse)
+	return result, json.NewDecoder(rsp.Body).Decode(result)
+}
+
+func (c Client) newRequest(method, path string, body io.Reader, opts ...RequestOption) (*http.Request, error) {
 	uri := c.baseUrl + "/" + path
-	return http.NewRequest(method, uri, body)
+	r, err := http.NewRequest(method, uri, body)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r, nil
 }
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/x1unix/docusearch/internal/models"
+)
+
+// RequestOption is optional request parameter.
+type RequestOption func(r *http.Request)
+
+// WithContentType sets document content type.
+func WithContentType(contentType string) RequestOption {
+	return func(r *http.Request) {
+		r.Header.Set("Content-Type", contentType)
+	}
+}
+
+// WithContentEncoding sets encoding of request body, e.g. "gzip".
+func WithContentEncoding(encoding string) RequestOption {
+	return func(r *http.Request) {
+		r.Header.Set("Content-Encoding", encoding)
+	}
+}
+
+// WithLabels sets document labels.
+func WithLabels(labels map[string]string) RequestOption {
+	return func(r *http.Request) {
+		for k, v := range labels {
+			r.Header.Add(models.LabelHeader, models.FormatLabelHeader(k, v))
+		}
+	}
+}
+
+// IfMatch makes request conditional and applies it only if
+// document matches passed SHA-256 checksum.
+//
+// Use "*" to require document to exist.
+func IfMatch(checksum string) RequestOption {
+	return func(r *http.Request) {
+		r.Header.Add("If-Match", formatETag(checksum))
+	}
+}
+
+// IfNoneMatch makes request conditional and applies it only if
+// document doesn't match passed SHA-256 checksum.
+//
+// Use "*" to require document to not exist.
+func IfNoneMatch(checksum string) RequestOption {
+	return func(r *http.Request) {
+		r.Header.Add("If-None-Match", formatETag(checksum))
+	}
+}
+
+// ExpiresIn sets document time to live.
+//
+// Document is removed after specified duration.
+func ExpiresIn(ttl time.Duration) RequestOption {
+	return func(r *http.Request) {
+		q := r.URL.Query()
+		q.Set("expires_in", ttl.String())
+		r.URL.RawQuery = q.Encode()
+	}
+}
+
+// WithAnalyzer sets name of search analyzer used to index document, "text" or "code".
+func WithAnalyzer(analyzer string) RequestOption {
+	return func(r *http.Request) {
+		q := r.URL.Query()
+		q.Set("analyzer", analyzer)
+		r.URL.RawQuery = q.Encode()
+	}
+}
+
+// ExpiresAt sets document expiration time.
+func ExpiresAt(t time.Time) RequestOption {
+	return func(r *http.Request) {
+		r.Header.Set("Expires", t.UTC().Format(http.TimeFormat))
+	}
+}
+
+func formatETag(checksum string) string {
+	if checksum == "*" {
+		return checksum
+	}
+
+	return strconv.Quote(checksum)
+}